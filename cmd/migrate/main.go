@@ -0,0 +1,40 @@
+// migrate lleva products.json a la version actual del formato, paso a paso.
+// Con -dry-run solo informa que cambiaria. Correrlo con el servidor detenido.
+//
+//	go run ./migrate -path ../data/products.json [-dry-run]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+func main() {
+	path := flag.String("path", "../data/products.json", "archivo json de productos")
+	dryRun := flag.Bool("dry-run", false, "informar los cambios sin escribir el archivo")
+	flag.Parse()
+
+	report, err := store.MigrateProductsFile(*path, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !report.Upgraded() {
+		fmt.Printf("%s is already at version %d\n", *path, report.To)
+		return
+	}
+	fmt.Printf("%s: version %d -> %d, %d records\n", *path, report.From, report.To, report.Records)
+	for _, step := range report.Steps {
+		fmt.Printf("  %d -> %d  %s (%d records changed)\n", step.From, step.To, step.Description, step.Changed)
+		for _, sample := range step.Samples {
+			fmt.Printf("      - %s\n      + %s\n", sample.Before, sample.After)
+		}
+	}
+	if *dryRun {
+		fmt.Println("dry run: nothing written")
+		return
+	}
+	fmt.Println("original saved as", *path+fmt.Sprintf(".v%d.bak", report.From))
+}
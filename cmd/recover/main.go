@@ -0,0 +1,59 @@
+// recover reconstruye products.json a partir del snapshot y su journal e
+// informa que mutaciones se reaplicaron. Correrlo con el servidor detenido.
+//
+//	go run ./recover -path ../data/products.json [-compact] [-v]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+func main() {
+	path := flag.String("path", "../data/products.json", "archivo json de productos")
+	compact := flag.Bool("compact", false, "escribir el resultado en el archivo y vaciar el journal")
+	verbose := flag.Bool("v", false, "listar cada entrada reaplicada")
+	flag.Parse()
+
+	report, err := store.RecoverJSON(*path, *compact)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	printReport(os.Stdout, *path, report, *verbose)
+}
+
+// printReport escribe en w lo que se reconstruyo
+func printReport(w io.Writer, path string, report *store.RecoveryReport, verbose bool) {
+	fmt.Fprintf(w, "snapshot: %d products\n", report.Snapshot)
+	if report.Migration.Upgraded() {
+		fmt.Fprintf(w, "schema:   version %d -> %d\n", report.Migration.From, report.Migration.To)
+	}
+	fmt.Fprintf(w, "journal:  %d entries replayed\n", len(report.Entries))
+	ops := make([]string, 0, len(report.Replayed))
+	for op := range report.Replayed {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		fmt.Fprintf(w, "  %-8s %d\n", op, report.Replayed[op])
+	}
+	if verbose {
+		for i, entry := range report.Entries {
+			fmt.Fprintf(w, "  #%d %s %s id=%d\n", i+1, entry.At.Format("2006-01-02T15:04:05Z"), entry.Op, entry.ID)
+		}
+	}
+	if report.TornBytes > 0 {
+		fmt.Fprintf(w, "discarded incomplete last entry (%d bytes)\n", report.TornBytes)
+	}
+	fmt.Fprintf(w, "result:   %d products\n", report.Products)
+	if report.Compacted {
+		fmt.Fprintln(w, "journal compacted into", path)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+func TestRecoverReplaysAndCompactsTornJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json")
+	seed := `[{"id":1,"name":"Oil","quantity":10,"code_value":"A1","is_published":true,"expiration":"2030-01-01","price":10}]`
+	if err := os.WriteFile(path, []byte(seed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Buy("A1", 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	journal, err := os.OpenFile(path+".journal", os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := journal.WriteString(`{"op":"buy","id":1,"prod`); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	report, err := store.RecoverJSON(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	printReport(&out, path, report, true)
+	for _, line := range []string{
+		"snapshot: 1 products",
+		"journal:  2 entries replayed",
+		"  buy      2",
+		"discarded incomplete last entry (24 bytes)",
+		"result:   1 products",
+		"journal compacted into " + path,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("output is missing %q:\n%s", line, out.String())
+		}
+	}
+
+	recovered, err := store.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := recovered.GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Quantity != 6 {
+		t.Fatalf("quantity %d after recovering two buys of 2, want 6", p.Quantity)
+	}
+	if info, err := os.Stat(path + ".journal"); err != nil || info.Size() != 0 {
+		t.Fatalf("journal not emptied by -compact: %v", err)
+	}
+}
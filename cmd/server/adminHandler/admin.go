@@ -0,0 +1,72 @@
+package adminHandler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/internal/currency"
+	"github.com/mceciabate/web-server/pkg/backup"
+	"github.com/mceciabate/web-server/pkg/web"
+)
+
+type adminHandler struct {
+	backups *backup.Manager
+	rates   currency.Service
+}
+
+// NewAdminHandler crea el controller de tareas administrativas
+func NewAdminHandler(backups *backup.Manager, rates currency.Service) *adminHandler {
+	return &adminHandler{
+		backups: backups,
+		rates:   rates,
+	}
+}
+
+// ListSnapshots lista los snapshots guardados
+func (h *adminHandler) ListSnapshots() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		infos, err := h.backups.List()
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, infos)
+	}
+}
+
+// CreateSnapshot toma un snapshot de todos los stores
+func (h *adminHandler) CreateSnapshot() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		info, err := h.backups.Create()
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 201, info)
+	}
+}
+
+// RestoreSnapshot restaura un snapshot validandolo antes
+func (h *adminHandler) RestoreSnapshot() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		info, err := h.backups.Restore(c.Param("name"))
+		if errors.Is(err, backup.ErrNotFound) {
+			web.Failure(c, 404, err)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 422, err)
+			return
+		}
+		web.Success(c, 200, info)
+	}
+}
@@ -0,0 +1,91 @@
+package adminHandler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/internal/currency"
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/web"
+)
+
+// ListRates devuelve las cotizaciones, la moneda en la que estan expresadas y
+// la regla de redondeo de las conversiones
+func (h *adminHandler) ListRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		rates, err := h.rates.List()
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, gin.H{
+			"base":     domain.BaseCurrency,
+			"rounding": h.rates.Rounding().String(),
+			"rates":    rates,
+		})
+	}
+}
+
+// SetRate carga o actualiza la cotizacion de una moneda: cuanto vale una
+// unidad en la moneda base, ej. {"rate": "1050.25"}
+func (h *adminHandler) SetRate() gin.HandlerFunc {
+	type Request struct {
+		Rate domain.Rate `json:"rate" binding:"required"`
+	}
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		code, err := domain.ParseCurrency(c.Param("currency"))
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		var r Request
+		if err := c.ShouldBindJSON(&r); err != nil {
+			web.Failure(c, 400, errors.New("invalid request, rate must be a positive decimal number"))
+			return
+		}
+		rate, err := h.rates.Set(code, r.Rate)
+		if errors.Is(err, currency.ErrBaseCurrency) {
+			web.Failure(c, 422, err)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, rate)
+	}
+}
+
+// DeleteRate borra la cotizacion de una moneda
+func (h *adminHandler) DeleteRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		code, err := domain.ParseCurrency(c.Param("currency"))
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		err = h.rates.Delete(code)
+		if errors.Is(err, currency.ErrBaseCurrency) {
+			web.Failure(c, 422, err)
+			return
+		}
+		if errors.Is(err, currency.ErrNoRate) {
+			web.Failure(c, 404, err)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		c.Status(204)
+	}
+}
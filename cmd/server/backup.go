@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mceciabate/web-server/pkg/backup"
+)
+
+const backupUsage = `usage: server backup <command>
+
+commands:
+  create          toma un snapshot de todos los stores
+  list            lista los snapshots guardados
+  restore <name>  valida y restaura un snapshot (con el servidor detenido)
+  prune           borra los snapshots que exceden la retencion
+
+create, list y prune solo leen los datos y se pueden usar con el servidor
+corriendo.
+`
+
+// backupCommand devuelve el subcomando de "server backup <command>", o ""
+// si no se pidio el cli de snapshots
+func backupCommand(args []string) string {
+	if len(args) < 3 || args[1] != "backup" {
+		return ""
+	}
+	return args[2]
+}
+
+// runBackup ejecuta los subcomandos de snapshots y devuelve el codigo de salida
+func runBackup(m *backup.Manager, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, backupUsage)
+		return 2
+	}
+	switch args[0] {
+	case "create":
+		info, err := m.Create()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("created %s (%d bytes)\n", info.Name, info.Size)
+	case "list":
+		infos, err := m.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, info := range infos {
+			fmt.Printf("%s\t%s\t%d bytes\t%s\n", info.Name, info.CreatedAt.Format("2006-01-02 15:04:05"), info.Size, info.Reason)
+		}
+	case "restore":
+		if len(args) != 2 {
+			fmt.Fprint(os.Stderr, backupUsage)
+			return 2
+		}
+		info, err := m.Restore(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("restored %s\n", info.Name)
+	case "prune":
+		removed, err := m.Prune()
+		for _, name := range removed {
+			fmt.Printf("removed %s\n", name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	default:
+		fmt.Fprint(os.Stderr, backupUsage)
+		return 2
+	}
+	return 0
+}
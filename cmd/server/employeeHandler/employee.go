@@ -3,13 +3,13 @@ package employeeHandler
 import (
 	"errors"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mceciabate/web-server/internal/domain"
 	"github.com/mceciabate/web-server/internal/employee"
+	"github.com/mceciabate/web-server/pkg/web"
 )
 
 type employeeHandler struct {
@@ -127,11 +127,7 @@ func (h *employeeHandler) Put() gin.HandlerFunc {
 // Delete elimina un empleado
 func (h *employeeHandler) Delete() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token := ctx.GetHeader("TOKEN")
-		if token != os.Getenv("TOKEN") {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error": "token invalido",
-			})
+		if !web.Authorized(ctx) {
 			return
 		}
 		idParam := ctx.Param("id")
@@ -149,6 +145,45 @@ func (h *employeeHandler) Delete() gin.HandlerFunc {
 	}
 }
 
+// GetTrash lista los empleados borrados
+func (h *employeeHandler) GetTrash() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !web.Authorized(ctx) {
+			return
+		}
+		employees, err := h.s.Trash()
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(200, employees)
+	}
+}
+
+// Restore devuelve un empleado de la papelera
+func (h *employeeHandler) Restore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !web.Authorized(ctx) {
+			return
+		}
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "invalid id"})
+			return
+		}
+		e, err := h.s.Restore(id)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				ctx.JSON(404, gin.H{"error": err.Error()})
+				return
+			}
+			ctx.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(200, e)
+	}
+}
+
 // Patch update selected fields of a product WIP
 func (h *employeeHandler) Patch() gin.HandlerFunc {
 	type Request struct {
@@ -156,11 +191,7 @@ func (h *employeeHandler) Patch() gin.HandlerFunc {
 		Active bool   `json:"is_active,omitempty"`
 	}
 	return func(ctx *gin.Context) {
-		token := ctx.GetHeader("TOKEN")
-		if token != os.Getenv("TOKEN") {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error": "token invalido",
-			})
+		if !web.Authorized(ctx) {
 			return
 		}
 		var r Request
@@ -5,15 +5,25 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/mceciabate/web-server/cmd/server/adminHandler"
 	"github.com/mceciabate/web-server/cmd/server/employeeHandler"
 	"github.com/mceciabate/web-server/cmd/server/productHandler"
+	"github.com/mceciabate/web-server/cmd/server/purchaseHandler"
+	"github.com/mceciabate/web-server/internal/currency"
 	"github.com/mceciabate/web-server/internal/domain"
 	"github.com/mceciabate/web-server/internal/employee"
 	"github.com/mceciabate/web-server/internal/product"
+	"github.com/mceciabate/web-server/internal/purchase"
+	"github.com/mceciabate/web-server/internal/reservation"
+	"github.com/mceciabate/web-server/pkg/backup"
+	"github.com/mceciabate/web-server/pkg/csvcodec"
 	"github.com/mceciabate/web-server/pkg/store"
+	"github.com/mceciabate/web-server/pkg/web"
 )
 
 func main() {
@@ -21,30 +31,135 @@ func main() {
 	if err := godotenv.Load(".env"); err != nil {
 		log.Fatal(err)
 	}
-	storage := store.NewStore("../data/products.json")
+	// backup create, list y prune leen los productos sin abrir el store, que
+	// recortaria el journal de un servidor corriendo; restore lo necesita abierto
+	command := backupCommand(os.Args)
+	var storage store.ProductStore
+	var snapshotter store.Snapshotter
+	var err error
+	if command == "" || command == "restore" {
+		if storage, err = newStorage(); err != nil {
+			log.Fatal(err)
+		}
+		snapshotter, _ = storage.(store.Snapshotter)
+	} else if snapshotter, err = readOnlyProducts(); err != nil {
+		log.Fatal(err)
+	}
+	employeesPath := envOr("EMPLOYEES_PATH", "../data/employees.csv")
+	employeesCodec, err := store.CodecByExtension(employeesPath, map[string]store.Codec[domain.Employee]{
+		".csv":  employee.NewCSVCodec(csvcodec.Braces),
+		".json": store.JSONCodec[domain.Employee]{},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	storageE := store.NewFileStore[domain.Employee](
+		employeesPath,
+		employeesCodec,
+		func(e *domain.Employee) *int { return &e.Id },
+		store.WithSoftDelete(func(e *domain.Employee) **time.Time { return &e.DeletedAt }),
+	)
+	if _, err := storageE.GetAll(); err != nil {
+		log.Fatalf("loading employees: %v", err)
+	}
 
 	/* 	var productsList = []domain.Product{}
 	   	Consigna imprimir productos
 	   	fmt.Println(productsList)
 	   	loadProducts("../data/products.json", &productsList) */
 
-	var employeesList = []domain.Employee{}
-	//Consigna imprimir empleados
-	fmt.Println(employeesList)
-	loadEmployees("../data/employees.csv", &employeesList)
+	//Cotizaciones para ver precios y compras en otra moneda
+	rounding, err := currency.ParseRounding(envOr("CURRENCY_ROUNDING", "half_up"))
+	if err != nil {
+		log.Fatalf("invalid CURRENCY_ROUNDING: %v", err)
+	}
+	storageR := store.NewFileStore[domain.ExchangeRate](
+		envOr("RATES_PATH", "../data/rates.json"),
+		store.JSONCodec[domain.ExchangeRate]{},
+		func(r *domain.ExchangeRate) *int { return &r.Id },
+	)
+	if _, err := storageR.GetAll(); err != nil {
+		log.Fatalf("loading exchange rates: %v", err)
+	}
+	serviceR := currency.NewService(currency.NewRepository(storageR), rounding)
+
+	//Instancio el repo y el service para compras
+	storagePu := store.NewFileStore[domain.Purchase](
+		envOr("PURCHASES_PATH", "../data/purchases.json"),
+		store.JSONCodec[domain.Purchase]{},
+		func(p *domain.Purchase) *int { return &p.Id },
+	)
+	if _, err := storagePu.GetAll(); err != nil {
+		log.Fatalf("loading purchases: %v", err)
+	}
+	servicePu := purchase.NewService(purchase.NewRepository(storagePu))
+	purchaseHandler := purchaseHandler.NewPurchaseHandler(servicePu)
+
+	//Reservas de stock
+	reservationTTL, err := time.ParseDuration(envOr("RESERVATION_TTL", "15m"))
+	if err != nil {
+		log.Fatalf("invalid RESERVATION_TTL: %v", err)
+	}
+	maxReservationTTL, err := time.ParseDuration(envOr("RESERVATION_MAX_TTL", "2h"))
+	if err != nil {
+		log.Fatalf("invalid RESERVATION_MAX_TTL: %v", err)
+	}
+	storageRe := store.NewFileStore[domain.Reservation](
+		envOr("RESERVATIONS_PATH", "../data/reservations.json"),
+		store.JSONCodec[domain.Reservation]{},
+		func(r *domain.Reservation) *int { return &r.Id },
+	)
+	if _, err := storageRe.GetAll(); err != nil {
+		log.Fatalf("loading reservations: %v", err)
+	}
+	repoRe := reservation.NewRepository(storageRe)
+	serviceRe := reservation.NewService(repoRe)
+
+	backups, err := newBackupManager(snapshotter, storageE, storageR, storagePu, repoRe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		os.Exit(runBackup(backups, os.Args[2:]))
+	}
+	if os.Getenv("STORE_LOG") == "true" {
+		storage = store.Decorate(storage, store.WithLogging(log.Default()))
+	}
+	if err := startPurge(map[string]purger{"products": storage, "employees": storageE}); err != nil {
+		log.Fatal(err)
+	}
 
 	//Instancio el repo y el service para productos
 	repoP := product.NewRepository(storage)
-	serviceP := product.NewService(repoP)
+	serviceP := product.NewService(repoP,
+		product.RefuseExpired(os.Getenv("BUY_REFUSE_EXPIRED") == "true"),
+		product.WithConverter(serviceR),
+		product.WithPurchases(servicePu),
+		product.WithReservations(serviceRe, reservationTTL, maxReservationTTL),
+	)
+	if err := startReservationSweeper(serviceP); err != nil {
+		log.Fatal(err)
+	}
 	productHandler := productHandler.NewProductHandler(serviceP)
 
-	//TODO STORAGE PARA EMPLEADOS
 	//Instancio el repo y el service para employees
-	repoE := employee.NewRepository(employeesList)
+	repoE := employee.NewRepository(storageE)
 	serviceE := employee.NewService(repoE)
 	employeeHandler := employeeHandler.NewEmployeeHandler(serviceE)
 
+	adminHandler := adminHandler.NewAdminHandler(backups, serviceR)
+
+	idempotencyTTL, err := time.ParseDuration(envOr("IDEMPOTENCY_TTL", "24h"))
+	if err != nil {
+		log.Fatalf("invalid IDEMPOTENCY_TTL: %v", err)
+	}
+	keys, err := store.NewIdempotencyStore(envOr("IDEMPOTENCY_PATH", "../data/idempotency.json"), idempotencyTTL)
+	if err != nil {
+		log.Fatalf("loading idempotency keys: %v", err)
+	}
+
 	r := gin.Default()
+	r.Use(web.Idempotent(keys, web.UnsafeMethods))
 
 	r.GET("/ping", func(c *gin.Context) { c.String(200, "pong") })
 	r.GET("", func(c *gin.Context) { c.String(200, "Bienvenido a la empresa Gophers") })
@@ -54,39 +169,148 @@ func main() {
 		products.GET("", productHandler.GetAll())
 		products.GET(":id", productHandler.GetByID())
 		products.GET("/search", productHandler.Search())
+		products.GET("/expiring", productHandler.Expiring())
+		products.GET("/export", productHandler.Export())
+		products.GET("/trash", productHandler.GetTrash())
+		products.POST(":id/restore", productHandler.Restore())
+		products.GET(":id/history", productHandler.History())
+		products.GET(":id/purchases", purchaseHandler.GetByProduct())
+		products.GET("/code/:code_value", productHandler.GetByCodeValue())
+		products.POST(":id/rename", productHandler.Rename())
+		products.POST("/import", productHandler.Import())
 		products.POST("", productHandler.Post())
 		products.PUT(":id", productHandler.Put())
 		products.DELETE(":id", productHandler.Delete())
 		products.PATCH(":id", productHandler.Patch())
-		products.GET("/buy", productHandler.Buy())
+		if os.Getenv("LEGACY_BUY") == "true" {
+			products.GET("/buy", web.Idempotent(keys, web.AllRequests), productHandler.Buy())
+		} else {
+			products.GET("/buy", productHandler.BuyGone())
+		}
+		products.POST("/transfer", productHandler.Transfer())
 	}
 	employees := r.Group("/employees")
 	{
 		employees.GET("", employeeHandler.GetAll())
 		employees.GET(":id", employeeHandler.GetByID())
 		employees.GET("/actives", employeeHandler.GetActives())
+		employees.GET("/trash", employeeHandler.GetTrash())
+		employees.POST(":id/restore", employeeHandler.Restore())
 		employees.POST("", employeeHandler.Post())
 		employees.PUT(":id", employeeHandler.Put())
 		employees.DELETE(":id", employeeHandler.Delete())
 	}
+	r.POST("/orders", productHandler.Order())
+	reservations := r.Group("/reservations")
+	{
+		reservations.POST("", productHandler.Reserve())
+		reservations.GET(":id", productHandler.GetReservation())
+		reservations.POST(":id/confirm", productHandler.ConfirmReservation())
+		reservations.POST(":id/cancel", productHandler.CancelReservation())
+	}
+	purchases := r.Group("/purchases")
+	{
+		purchases.GET("", purchaseHandler.GetAll())
+		purchases.GET(":id", purchaseHandler.GetByID())
+	}
+	admin := r.Group("/admin")
+	{
+		admin.GET("/snapshots", adminHandler.ListSnapshots())
+		admin.POST("/snapshots", adminHandler.CreateSnapshot())
+		admin.POST("/snapshots/:name/restore", adminHandler.RestoreSnapshot())
+		admin.GET("/rates", adminHandler.ListRates())
+		admin.PUT("/rates/:currency", adminHandler.SetRate())
+		admin.DELETE("/rates/:currency", adminHandler.DeleteRate())
+	}
 
 	r.Run(":8080")
 }
 
-// loadProducts carga los productos desde un archivo json
-func loadProducts(path string, list *[]domain.Product) {
-	file, err := os.ReadFile(path)
+// newStorage elige el backend de productos segun STORE_DRIVER (json por defecto o sqlite)
+func newStorage() (store.ProductStore, error) {
+	jsonPath := envOr("PRODUCTS_JSON_PATH", "../data/products.json")
+	switch driver := envOr("STORE_DRIVER", "json"); driver {
+	case "json":
+		every, err := strconv.Atoi(envOr("JOURNAL_COMPACT_EVERY", "1000"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JOURNAL_COMPACT_EVERY: %w", err)
+		}
+		interval, err := time.ParseDuration(envOr("JOURNAL_COMPACT_INTERVAL", "0s"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JOURNAL_COMPACT_INTERVAL: %w", err)
+		}
+		return store.NewStore(jsonPath, store.WithCompaction(every, interval))
+	case "sqlite":
+		return store.NewSqliteStore(envOr("SQLITE_PATH", "../data/products.db"), jsonPath)
+	default:
+		return nil, fmt.Errorf("unknown STORE_DRIVER %q", driver)
+	}
+}
+
+// readOnlyProducts devuelve los productos del backend elegido por
+// STORE_DRIVER para respaldarlos sin abrir el store
+func readOnlyProducts() (store.Snapshotter, error) {
+	switch driver := envOr("STORE_DRIVER", "json"); driver {
+	case "json":
+		return store.ReadOnlyJSON(envOr("PRODUCTS_JSON_PATH", "../data/products.json")), nil
+	case "sqlite":
+		return store.ReadOnlySqlite(envOr("SQLITE_PATH", "../data/products.db")), nil
+	default:
+		return nil, fmt.Errorf("unknown STORE_DRIVER %q", driver)
+	}
+}
+
+// newBackupManager registra en el manager de snapshots todos los stores.
+// Productos va primero porque sus transacciones toman su lock antes que el de
+// los otros stores. Cotizaciones, compras y reservas se agregaron despues de
+// los primeros snapshots: al restaurar uno de esos quedan vacias. Las
+// reservas se respaldan a traves de su repositorio, que al restaurar recarga
+// su indice.
+func newBackupManager(products store.Snapshotter, employees store.Store[domain.Employee], rates store.Store[domain.ExchangeRate], purchases store.Store[domain.Purchase], reservations store.Snapshotter) (*backup.Manager, error) {
+	keep, err := strconv.Atoi(envOr("SNAPSHOT_KEEP", "10"))
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("invalid SNAPSHOT_KEEP: %w", err)
 	}
-	err = json.Unmarshal([]byte(file), &list)
+	maxAge, err := time.ParseDuration(envOr("SNAPSHOT_MAX_AGE", "720h"))
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("invalid SNAPSHOT_MAX_AGE: %w", err)
 	}
+	manager := backup.NewManager(envOr("SNAPSHOT_DIR", "../data/snapshots"), backup.Retention{Keep: keep, MaxAge: maxAge})
+	stores := []struct {
+		name  string
+		store any
+		added bool
+	}{
+		{"products.json", products, false},
+		{"employees.csv", employees, false},
+		{"rates.json", rates, true},
+		{"purchases.json", purchases, true},
+		{"reservations.json", reservations, true},
+	}
+	for _, s := range stores {
+		snapshotter, ok := s.store.(store.Snapshotter)
+		if !ok {
+			return nil, fmt.Errorf("%s store does not support snapshots", s.name)
+		}
+		if s.added {
+			manager.RegisterAdded(s.name, snapshotter, []byte("[]"))
+		} else {
+			manager.Register(s.name, snapshotter)
+		}
+	}
+	return manager, nil
 }
 
-// loadEmployess carga los employees desde un archivo csv
-func loadEmployees(path string, list *[]domain.Employee) {
+// envOr devuelve la variable de entorno key o def si no esta definida
+func envOr(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// loadProducts carga los productos desde un archivo json
+func loadProducts(path string, list *[]domain.Product) {
 	file, err := os.ReadFile(path)
 	if err != nil {
 		panic(err)
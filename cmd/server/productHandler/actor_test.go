@@ -0,0 +1,39 @@
+package productHandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mceciabate/web-server/pkg/web"
+)
+
+func TestChangesAreRecordedForTheTokenNotXActor(t *testing.T) {
+	r, storage := newTestServer(t, orderProducts())
+
+	req := httptest.NewRequest(http.MethodPatch, "/products/1", strings.NewReader(`{"quantity":7}`))
+	req.Header.Set("TOKEN", testToken)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("X-Actor", "mallory")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("patch: %d %s", w.Code, w.Body)
+	}
+
+	page, err := storage.History(1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 {
+		t.Fatalf("history: %+v", page)
+	}
+	actor := page.Items[0].Actor
+	if actor == "mallory" || actor == web.AnonymousActor || !strings.HasPrefix(actor, "token:") {
+		t.Fatalf("actor %q, want the one derived from the token", actor)
+	}
+	if strings.Contains(actor, testToken) {
+		t.Fatalf("actor %q exposes the token", actor)
+	}
+}
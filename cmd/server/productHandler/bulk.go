@@ -0,0 +1,165 @@
+package productHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/internal/product"
+	"github.com/mceciabate/web-server/pkg/csvcodec"
+	"github.com/mceciabate/web-server/pkg/web"
+)
+
+// maxImportSize es el tamaño maximo del cuerpo de una importacion
+const maxImportSize = 32 << 20
+
+// Import crea o actualiza productos a partir de un csv (text/csv) o de un
+// array json (application/json). Cada fila pasa las mismas validaciones que
+// un alta y se informa si se creo, se actualizo o se rechazo y por que. Con
+// ?atomic=true, si alguna fila se rechaza no se guarda ninguna.
+func (h *productHandler) Import() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		atomic, err := strconv.ParseBool(c.DefaultQuery("atomic", "false"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid atomic"))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+		data, err := c.GetRawData()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			web.Failure(c, 413, errors.New("import body too large"))
+			return
+		}
+		if err != nil {
+			web.Failure(c, 400, errors.New("can't read request body"))
+			return
+		}
+		var rows []product.ImportRow
+		switch c.ContentType() {
+		case "text/csv":
+			rows, err = csvImportRows(data)
+		case "application/json":
+			rows, err = jsonImportRows(data)
+		default:
+			web.Failure(c, 415, errors.New("content type must be text/csv or application/json"))
+			return
+		}
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		for i := range rows {
+			if rows[i].Err == nil {
+				rows[i].Err = validateImported(&rows[i].Product)
+			}
+		}
+		report, err := h.as(c).Import(rows, atomic)
+		if errors.Is(err, product.ErrImportRejected) {
+			c.JSON(422, report)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, report)
+	}
+}
+
+// Export devuelve el catalogo completo en csv o json segun ?format=
+func (h *productHandler) Export() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "csv" {
+			web.Failure(c, 400, errors.New("format must be json or csv"))
+			return
+		}
+		products, err := h.s.GetAll()
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="products.`+format+`"`)
+		if format == "csv" {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			err = product.NewCSVCodec().EncodeTo(c.Writer, products)
+		} else {
+			c.Header("Content-Type", "application/json; charset=utf-8")
+			err = writeJSONArray(c.Writer, products)
+		}
+		if err != nil {
+			// la respuesta ya empezo, solo queda registrar el error
+			c.Error(err)
+		}
+	}
+}
+
+// csvImportRows lee las filas de un csv de productos
+func csvImportRows(data []byte) ([]product.ImportRow, error) {
+	records, err := product.NewImportCSVCodec().Rows(data)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]product.ImportRow, len(records))
+	for i, record := range records {
+		rows[i] = product.ImportRow{Line: record.Line, Product: record.Item}
+		// la linea ya va en el reporte, solo se guarda el motivo
+		var parseErr *csvcodec.ParseError
+		if errors.As(record.Err, &parseErr) {
+			rows[i].Err = parseErr.Err
+		}
+	}
+	return rows, nil
+}
+
+// jsonImportRows lee un array json de productos; la linea de cada fila es su
+// posicion en el array, empezando en 1
+func jsonImportRows(data []byte) ([]product.ImportRow, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.New("body must be a json array of products")
+	}
+	rows := make([]product.ImportRow, len(raw))
+	for i, item := range raw {
+		rows[i].Line = i + 1
+		rows[i].Err = json.Unmarshal(item, &rows[i].Product)
+	}
+	return rows, nil
+}
+
+// validateImported aplica a una fila importada las validaciones de un alta
+func validateImported(p *domain.Product) error {
+	if valid, err := validateEmptys(p); !valid {
+		return err
+	}
+	return nil
+}
+
+// writeJSONArray escribe los productos de a uno para no armar toda la
+// respuesta en memoria
+func writeJSONArray(w io.Writer, products []domain.Product) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	for i, p := range products {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(p); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
@@ -0,0 +1,143 @@
+package productHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/internal/product"
+)
+
+// importCSV tiene una fila que actualiza A1, una que crea D4 y una con una
+// cantidad invalida
+const importCSV = `name,quantity,code_value,is_published,expiration,price
+Olive oil,20,A1,true,2030-01-01,12.50
+Salt,4,D4,true,2031-05-01,3
+Sugar,many,E5,true,2031-05-01,2
+`
+
+func decodeReport(t *testing.T, w *httptest.ResponseRecorder) product.ImportReport {
+	t.Helper()
+	var report product.ImportReport
+	data := w.Body.Bytes()
+	var envelope struct {
+		Data *product.ImportReport `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Data != nil {
+		return *envelope.Data
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestImportReportsEachRow(t *testing.T) {
+	r, storage := newTestServer(t, orderProducts())
+
+	w := send(r, http.MethodPost, "/products/import", "", "text/csv", importCSV)
+	if w.Code != 200 {
+		t.Fatalf("import: %d %s", w.Code, w.Body)
+	}
+	report := decodeReport(t, w)
+	if report.Created != 1 || report.Updated != 1 || report.Rejected != 1 || !report.Committed {
+		t.Fatalf("report: %+v", report)
+	}
+	want := []struct {
+		line   int
+		status string
+	}{{2, product.ImportUpdated}, {3, product.ImportCreated}, {4, product.ImportRejected}}
+	for i, row := range report.Rows {
+		if row.Line != want[i].line || row.Status != want[i].status {
+			t.Fatalf("row %d: %+v, want line %d %s", i, row, want[i].line, want[i].status)
+		}
+	}
+	if report.Rows[2].Reason == "" {
+		t.Fatal("a rejected row must have a reason")
+	}
+	if p, _ := storage.GetByCodeValue("A1"); p.Quantity != 20 || p.Price != 1250 {
+		t.Fatalf("updated product: %+v", p)
+	}
+	if _, err := storage.GetByCodeValue("D4"); err != nil {
+		t.Fatalf("created product: %v", err)
+	}
+}
+
+func TestAtomicImportSavesNothingOnRejection(t *testing.T) {
+	r, storage := newTestServer(t, orderProducts())
+
+	w := send(r, http.MethodPost, "/products/import?atomic=true", "", "text/csv", importCSV)
+	if w.Code != 422 {
+		t.Fatalf("atomic import: %d %s, want 422", w.Code, w.Body)
+	}
+	if report := decodeReport(t, w); report.Committed || report.Rejected != 1 {
+		t.Fatalf("report: %+v", report)
+	}
+	if p, _ := storage.GetByCodeValue("A1"); p.Quantity != 10 || p.Version != 1 {
+		t.Fatalf("A1 changed by a rejected import: %+v", p)
+	}
+	if _, err := storage.GetByCodeValue("D4"); err == nil {
+		t.Fatal("a rejected import must not create products")
+	}
+	if page, err := storage.History(1, 0, 0); err != nil || page.Total != 0 {
+		t.Fatalf("a rejected import must not record history: %+v %v", page, err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for format, contentType := range map[string]string{"json": "application/json", "csv": "text/csv"} {
+		t.Run(format, func(t *testing.T) {
+			source, _ := newTestServer(t, orderProducts())
+			exported := send(source, http.MethodGet, "/products/export?format="+format, "", "", "")
+			if exported.Code != 200 || !strings.HasPrefix(exported.Header().Get("Content-Type"), contentType) {
+				t.Fatalf("export: %d %s", exported.Code, exported.Header().Get("Content-Type"))
+			}
+
+			target, storage := newTestServer(t, []domain.Product{})
+			w := send(target, http.MethodPost, "/products/import?atomic=true", "", contentType, exported.Body.String())
+			if w.Code != 200 {
+				t.Fatalf("import: %d %s", w.Code, w.Body)
+			}
+			if report := decodeReport(t, w); report.Created != 3 {
+				t.Fatalf("report: %+v", report)
+			}
+			for _, want := range orderProducts() {
+				got, err := storage.GetByCodeValue(want.CodeValue)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Name != want.Name || got.Quantity != want.Quantity || got.Price != want.Price ||
+					got.IsPublished != want.IsPublished || got.Expiration != want.Expiration || got.Currency != domain.BaseCurrency {
+					t.Fatalf("imported %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportBodyErrors(t *testing.T) {
+	r, _ := newTestServer(t, orderProducts())
+
+	huge := strings.Repeat("x", maxImportSize+1)
+	if w := send(r, http.MethodPost, "/products/import", "", "text/csv", huge); w.Code != 413 {
+		t.Fatalf("body over the limit: %d, want 413", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/products/import", iotest.ErrReader(errors.New("connection reset")))
+	req.Header.Set("TOKEN", testToken)
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 400 {
+		t.Fatalf("unreadable body: %d, want 400", w.Code)
+	}
+
+	if w := send(r, http.MethodPost, "/products/import", "", "text/plain", "a"); w.Code != 415 {
+		t.Fatalf("unsupported content type: %d, want 415", w.Code)
+	}
+}
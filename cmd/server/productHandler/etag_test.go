@@ -0,0 +1,102 @@
+package productHandler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// send hace un request autenticado con If-Match si ifMatch no esta vacio
+func send(r *gin.Engine, method, target, ifMatch, contentType, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("TOKEN", testToken)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestETagAndIfMatch(t *testing.T) {
+	r, storage := newTestServer(t, []domain.Product{
+		{Id: 1, Name: "Oil", Quantity: 10, CodeValue: "S82254D", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 1), Price: 10, Version: 1},
+	})
+	const put = `{"name":"Olive oil","quantity":10,"code_value":"S82254D","is_published":true,"expiration":"2030-01-01","price":10}`
+	const patch = `{"name":"Sunflower oil"}`
+
+	get := send(r, http.MethodGet, "/products/1", "", "", "")
+	if get.Code != 200 || get.Header().Get("ETag") != `"1"` {
+		t.Fatalf("GET: %d with ETag %q, want 200 and \"1\"", get.Code, get.Header().Get("ETag"))
+	}
+
+	steps := []struct {
+		name        string
+		method      string
+		target      string
+		ifMatch     string
+		contentType string
+		body        string
+		status      int
+		etag        string
+	}{
+		{"put with current etag", http.MethodPut, "/products/1", `"1"`, "application/json", put, 200, `"2"`},
+		{"put with stale etag", http.MethodPut, "/products/1", `"1"`, "application/json", put, 412, ""},
+		{"patch with stale etag", http.MethodPatch, "/products/1", `"1"`, "application/merge-patch+json", patch, 412, ""},
+		{"patch with weak etag", http.MethodPatch, "/products/1", `W/"2"`, "application/merge-patch+json", patch, 412, ""},
+		{"patch with current etag", http.MethodPatch, "/products/1", `"2"`, "application/merge-patch+json", patch, 200, `"3"`},
+		{"buy with stale etag", http.MethodGet, "/products/buy?code_value=S82254D&quantity=1", `"2"`, "", "", 412, ""},
+		{"buy with current etag", http.MethodGet, "/products/buy?code_value=S82254D&quantity=1", `"3"`, "", "", 201, ""},
+		{"delete with stale etag", http.MethodDelete, "/products/1", `"3"`, "", "", 412, ""},
+		{"delete with current etag", http.MethodDelete, "/products/1", `"4"`, "", "", 204, ""},
+	}
+	for _, step := range steps {
+		w := send(r, step.method, step.target, step.ifMatch, step.contentType, step.body)
+		if w.Code != step.status {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, w.Code, step.status, w.Body)
+		}
+		if step.etag != "" && w.Header().Get("ETag") != step.etag {
+			t.Fatalf("%s: ETag %q, want %s", step.name, w.Header().Get("ETag"), step.etag)
+		}
+	}
+
+	deleted, err := storage.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Name != "Sunflower oil" || deleted[0].Quantity != 9 || deleted[0].Version != 4 {
+		t.Fatalf("product after the rejected writes: %+v", deleted)
+	}
+}
+
+func TestWritesWithoutIfMatchAreUnconditional(t *testing.T) {
+	r, storage := newTestServer(t, []domain.Product{
+		{Id: 1, Name: "Oil", Quantity: 10, CodeValue: "S82254D", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 1), Price: 10, Version: 3},
+	})
+	if w := send(r, http.MethodPatch, "/products/1", "", "application/merge-patch+json", `{"quantity":7}`); w.Code != 200 {
+		t.Fatalf("PATCH without If-Match: %d %s", w.Code, w.Body)
+	}
+	if w := send(r, http.MethodPatch, "/products/1", "*", "application/merge-patch+json", `{"quantity":6}`); w.Code != 200 {
+		t.Fatalf("PATCH with If-Match *: %d %s", w.Code, w.Body)
+	}
+	p, err := storage.GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Quantity != 6 || p.Version != 5 {
+		t.Fatalf("product: quantity %d version %d, want 6 and 5", p.Quantity, p.Version)
+	}
+}
@@ -0,0 +1,57 @@
+package productHandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+func TestExpiringQuery(t *testing.T) {
+	today := domain.DateOf(time.Now())
+	r, _ := newTestServer(t, []domain.Product{
+		{Id: 1, Name: "Milk", Quantity: 1, CodeValue: "M1", Expiration: today.AddDays(20), Price: 100, Version: 1},
+		{Id: 2, Name: "Bread", Quantity: 1, CodeValue: "B1", Expiration: today.AddDays(3), Price: 100, Version: 1},
+		{Id: 3, Name: "Jam", Quantity: 1, CodeValue: "J1", Expiration: today.AddDays(60), Price: 100, Version: 1},
+		{Id: 4, Name: "Cheese", Quantity: 1, CodeValue: "C1", Expiration: today.AddDays(-2), Price: 100, Version: 1},
+	})
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"B1", "M1"}},
+		{"?within=7", []string{"B1"}},
+		{"?within=7d", []string{"B1"}},
+		{"?within=3w", []string{"B1", "M1"}},
+		{"?within=7d&include_expired=true", []string{"C1", "B1"}},
+	}
+	for _, c := range cases {
+		w := send(r, http.MethodGet, "/products/expiring"+c.query, "", "", "")
+		if w.Code != 200 {
+			t.Fatalf("%s: %d %s", c.query, w.Code, w.Body)
+		}
+		var body struct {
+			Data []domain.Product `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data) != len(c.want) {
+			t.Fatalf("%s: got %d products, want %v", c.query, len(body.Data), c.want)
+		}
+		for i, p := range body.Data {
+			if p.CodeValue != c.want[i] {
+				t.Fatalf("%s: product %d is %s, want %v", c.query, i, p.CodeValue, c.want)
+			}
+		}
+	}
+
+	for _, query := range []string{"?within=soon", "?within=-1d", "?within=2m", "?include_expired=maybe"} {
+		if w := send(r, http.MethodGet, "/products/expiring"+query, "", "", ""); w.Code != 400 {
+			t.Fatalf("%s: %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package productHandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/web"
+)
+
+func TestGetAllPage(t *testing.T) {
+	r, _ := newTestServer(t, orderProducts())
+
+	w := send(r, http.MethodGet, "/products?sort=-price&limit=2&offset=1", "", "", "")
+	if w.Code != 200 {
+		t.Fatalf("list: %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		Data []domain.Product `json:"data"`
+		Meta web.PageMeta     `json:"meta"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Meta != (web.PageMeta{Total: 3, Count: 2, Limit: 2, Offset: 1}) {
+		t.Fatalf("meta: %+v", body.Meta)
+	}
+	if len(body.Data) != 2 || body.Data[0].CodeValue != "A1" || body.Data[1].CodeValue != "C3" {
+		t.Fatalf("page: %+v", body.Data)
+	}
+
+	for _, query := range []string{"?limit=ten", "?offset=-1", "?color=red", "?sort=color", "?quantity=many"} {
+		if w := send(r, http.MethodGet, "/products"+query, "", "", ""); w.Code != 400 {
+			t.Fatalf("%s: %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package productHandler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/internal/product"
+	"github.com/mceciabate/web-server/pkg/web"
+)
+
+// Order compra varios productos juntos: todas las lineas o ninguna. Con
+// ?currency= las lineas y el total se valuan en esa moneda.
+func (h *productHandler) Order() gin.HandlerFunc {
+	type Request struct {
+		Lines []domain.PurchaseItem `json:"lines" binding:"required"`
+	}
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		currency, err := currencyParam(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		var r Request
+		if err := c.ShouldBindJSON(&r); err != nil {
+			web.Failure(c, 400, errors.New("invalid request, must be {\"lines\": [{\"code_value\", \"quantity\"}]}"))
+			return
+		}
+		order, err := h.as(c).BuyMany(r.Lines, currency)
+		var rejection *product.OrderRejection
+		if errors.As(err, &rejection) {
+			// se informan todas las lineas rechazadas, no solo un mensaje
+			c.JSON(409, gin.H{"status": 409, "code": "Conflict", "message": product.ErrOrderRejected.Error(), "lines": rejection.Lines})
+			return
+		}
+		if errors.Is(err, product.ErrEmptyOrder) {
+			web.Failure(c, 400, err)
+			return
+		}
+		if errors.Is(err, product.ErrCurrency) {
+			web.Failure(c, 422, err)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 201, order)
+	}
+}
+
+// BuyGone responde la compra deprecada GET /products/buy cuando esta
+// deshabilitada
+func (h *productHandler) BuyGone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Link", `</orders>; rel="successor-version"`)
+		web.Failure(c, 410, errors.New("GET /products/buy was removed, use POST /orders"))
+	}
+}
@@ -0,0 +1,123 @@
+package productHandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/internal/product"
+	"github.com/mceciabate/web-server/internal/purchase"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// orderProducts son tres productos con stock 10, 5 y 1
+func orderProducts() []domain.Product {
+	expiration := domain.NewDate(2030, time.January, 1)
+	return []domain.Product{
+		{Id: 1, Name: "Oil", Quantity: 10, CodeValue: "A1", IsPublished: true, Expiration: expiration, Price: 1000, Version: 1},
+		{Id: 2, Name: "Wine", Quantity: 5, CodeValue: "B2", IsPublished: true, Expiration: expiration, Price: 2500, Version: 1},
+		{Id: 3, Name: "Cake", Quantity: 1, CodeValue: "C3", IsPublished: true, Expiration: expiration, Price: 500, Version: 1},
+	}
+}
+
+func newPurchases(t *testing.T) purchase.Service {
+	t.Helper()
+	return purchase.NewService(purchase.NewRepository(store.NewFileStore[domain.Purchase](
+		filepath.Join(t.TempDir(), "purchases.json"), store.JSONCodec[domain.Purchase]{},
+		func(p *domain.Purchase) *int { return &p.Id },
+	)))
+}
+
+func TestOrderBuysEveryLine(t *testing.T) {
+	purchases := newPurchases(t)
+	r, storage := newTestServer(t, orderProducts(), product.WithPurchases(purchases))
+
+	w := send(r, http.MethodPost, "/orders", "", "application/json", `{"lines":[{"code_value":"A1","quantity":2},{"code_value":"B2","quantity":1},{"code_value":"A1","quantity":3}]}`)
+	if w.Code != 201 {
+		t.Fatalf("order: %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		Data domain.Order `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Data.Lines) != 3 || body.Data.Total != 7500 {
+		t.Fatalf("order: %+v, want 3 lines totalling 75.00", body.Data)
+	}
+	for id, want := range map[int]int{1: 5, 2: 4, 3: 1} {
+		if p, _ := storage.GetByID(id); p.Quantity != want {
+			t.Fatalf("product %d: quantity %d, want %d", id, p.Quantity, want)
+		}
+	}
+	if page, _ := purchases.List(purchase.Filter{}, 0, 0); page.Total != 3 {
+		t.Fatalf("recorded %d purchases, want 3", page.Total)
+	}
+}
+
+func TestOrderIsAllOrNothing(t *testing.T) {
+	purchases := newPurchases(t)
+	r, storage := newTestServer(t, orderProducts(), product.WithPurchases(purchases))
+
+	// A1 alcanza, C3 no tiene stock, X9 no existe y las dos lineas de B2
+	// juntas superan su stock
+	w := send(r, http.MethodPost, "/orders", "", "application/json", `{"lines":[
+		{"code_value":"A1","quantity":2},
+		{"code_value":"C3","quantity":2},
+		{"code_value":"X9","quantity":1},
+		{"code_value":"B2","quantity":3},
+		{"code_value":"B2","quantity":3}
+	]}`)
+	if w.Code != 409 {
+		t.Fatalf("order: %d %s, want 409", w.Code, w.Body)
+	}
+	var body struct {
+		Message string                 `json:"message"`
+		Lines   []product.RejectedLine `json:"lines"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Message != product.ErrOrderRejected.Error() {
+		t.Fatalf("message %q, want %q", body.Message, product.ErrOrderRejected)
+	}
+	want := map[int]string{2: "C3", 3: "X9", 5: "B2"}
+	if len(body.Lines) != len(want) {
+		t.Fatalf("rejected lines: %+v, want lines 2, 3 and 5", body.Lines)
+	}
+	for _, line := range body.Lines {
+		if want[line.Line] != line.CodeValue || line.Reason == "" {
+			t.Fatalf("rejected line %+v, want lines 2, 3 and 5 with a reason", line)
+		}
+	}
+
+	for _, before := range orderProducts() {
+		p, err := storage.GetByID(before.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Quantity != before.Quantity || p.Version != before.Version {
+			t.Fatalf("product %d changed: quantity %d version %d", p.Id, p.Quantity, p.Version)
+		}
+	}
+	if page, _ := purchases.List(purchase.Filter{}, 0, 0); page.Total != 0 {
+		t.Fatalf("a rejected order recorded %d purchases", page.Total)
+	}
+}
+
+func TestOrderRejectsEmptyAndInvalidRequests(t *testing.T) {
+	r, _ := newTestServer(t, orderProducts())
+	for body, status := range map[string]int{
+		`{"lines":[]}`:   400,
+		`{"lines":[{}]}`: 409,
+		`not json`:       400,
+		`{"lines":[{"code_value":"A1","quantity":-1}]}`: 409,
+	} {
+		if w := send(r, http.MethodPost, "/orders", "", "application/json", body); w.Code != status {
+			t.Fatalf("%s: status %d, want %d: %s", body, w.Code, status, w.Body)
+		}
+	}
+}
@@ -1,15 +1,19 @@
 package productHandler
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mceciabate/web-server/internal/domain"
 	"github.com/mceciabate/web-server/internal/product"
+	"github.com/mceciabate/web-server/pkg/jsonpatch"
+	"github.com/mceciabate/web-server/pkg/store"
 	"github.com/mceciabate/web-server/pkg/web"
 )
 
@@ -24,15 +28,96 @@ func NewProductHandler(s product.Service) *productHandler {
 	}
 }
 
-// GetAll obtiene todos los productos
+// as devuelve el servicio registrando los cambios a nombre del actor
+// autenticado del request
+func (h *productHandler) as(c *gin.Context) product.Service {
+	return h.s.As(web.Actor(c))
+}
+
+// currencyParam lee la moneda pedida en ?currency=; vacia si no se pide
+func currencyParam(c *gin.Context) (domain.Currency, error) {
+	value := c.Query("currency")
+	if value == "" {
+		return "", nil
+	}
+	return domain.ParseCurrency(value)
+}
+
+// inCurrency pasa los precios de los productos a la moneda de ?currency=. Si
+// falla escribe la respuesta de error y devuelve false.
+func (h *productHandler) inCurrency(c *gin.Context, products []domain.Product) bool {
+	currency, err := currencyParam(c)
+	if err != nil {
+		web.Failure(c, 400, err)
+		return false
+	}
+	for i := range products {
+		if products[i], err = h.s.InCurrency(products[i], currency); err != nil {
+			web.Failure(c, 422, err)
+			return false
+		}
+	}
+	return true
+}
+
+// GetAll obtiene los productos. Acepta limit y offset para paginar, sort con
+// una lista de campos separados por coma (con - para orden descendente),
+// currency para ver los precios en otra moneda y cualquier otro parametro
+// como filtro por igualdad, ej. ?is_published=true
 func (h *productHandler) GetAll() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		products, _ := h.s.GetAll()
-		web.Success(c, 200, products)
+		var q store.Query
+		for key, values := range c.Request.URL.Query() {
+			value := values[len(values)-1]
+			var err error
+			switch key {
+			case "currency":
+				// no filtra, se aplica al responder
+			case "limit":
+				q.Limit, err = strconv.Atoi(value)
+			case "offset":
+				q.Offset, err = strconv.Atoi(value)
+			case "sort":
+				q.Sort = store.ParseSort(value)
+			default:
+				q.Filters = append(q.Filters, store.Filter{Field: key, Value: value})
+			}
+			if err != nil {
+				web.Failure(c, 400, errors.New("invalid "+key))
+				return
+			}
+		}
+		page, err := h.s.List(q)
+		if errors.Is(err, store.ErrInvalidQuery) {
+			web.Failure(c, 400, err)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		if !h.inCurrency(c, page.Items) {
+			return
+		}
+		web.SuccessPage(c, 200, page.Items, web.PageMeta{
+			Total:  page.Total,
+			Count:  len(page.Items),
+			Limit:  q.Limit,
+			Offset: q.Offset,
+		})
 	}
 }
 
-// GetByID obtiene un producto por su id
+// productStock es un producto con su stock separado en lo disponible y lo
+// retenido por reservas
+type productStock struct {
+	domain.Product
+	Available int `json:"available"`
+	Reserved  int `json:"reserved"`
+}
+
+// GetByID obtiene un producto por su id, con ?currency= en otra moneda, y
+// cuanto de su stock esta disponible y cuanto reservado
 func (h *productHandler) GetByID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idParam := c.Param("id")
@@ -46,32 +131,204 @@ func (h *productHandler) GetByID() gin.HandlerFunc {
 			c.JSON(404, gin.H{"error": "product not found"})
 			return
 		}
-		web.Success(c, 200, product)
+		products := []domain.Product{product}
+		if !h.inCurrency(c, products) {
+			return
+		}
+		reserved, err := h.s.Reserved(product.Id)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		available := product.Quantity - reserved
+		if available < 0 {
+			available = 0
+		}
+		web.SetETag(c, product.Version)
+		web.Success(c, 200, productStock{Product: products[0], Available: available, Reserved: reserved})
+	}
+}
+
+// GetByCodeValue obtiene un producto por su code_value o por un code_value
+// anterior a un renombre, con ?currency= en otra moneda
+func (h *productHandler) GetByCodeValue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := h.s.GetByCodeValue(c.Param("code_value"))
+		if err != nil {
+			web.Failure(c, 404, errors.New("product not found"))
+			return
+		}
+		products := []domain.Product{p}
+		if !h.inCurrency(c, products) {
+			return
+		}
+		web.SetETag(c, p.Version)
+		web.Success(c, 200, products[0])
+	}
+}
+
+// History devuelve el historial de cambios de un producto, el mas reciente
+// primero. Acepta limit y offset para paginar.
+func (h *productHandler) History() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid id"))
+			return
+		}
+		limit, offset := 0, 0
+		for key, target := range map[string]*int{"limit": &limit, "offset": &offset} {
+			value := c.Query(key)
+			if value == "" {
+				continue
+			}
+			if *target, err = strconv.Atoi(value); err != nil || *target < 0 {
+				web.Failure(c, 400, errors.New("invalid "+key))
+				return
+			}
+		}
+		page, err := h.s.History(id, limit, offset)
+		if err != nil {
+			web.Failure(c, 404, err)
+			return
+		}
+		web.SuccessPage(c, 200, page.Items, web.PageMeta{
+			Total:  page.Total,
+			Count:  len(page.Items),
+			Limit:  limit,
+			Offset: offset,
+		})
 	}
 }
 
-// Search busca un producto por precio mayor a un valor
+// Search busca productos que cumplan todos los criterios recibidos:
+// name (subcadena), code_prefix, price_min, price_max, quantity_min,
+// quantity_max, is_published, expires_before y expires_after (dd/mm/yyyy o
+// yyyy-mm-dd). Se mantiene priceGt por compatibilidad. Los precios se
+// comparan en la moneda de cada producto; currency solo cambia la moneda en
+// la que se devuelven.
 func (h *productHandler) Search() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		priceParam := c.Query("priceGt")
-		price, err := strconv.ParseFloat(priceParam, 64)
+		criteria, err := parseSearchCriteria(c)
 		if err != nil {
-			c.JSON(400, gin.H{"error": "invalid price"})
+			web.Failure(c, 400, err)
 			return
 		}
-		products, err := h.s.SearchPriceGt(price)
+		products, err := h.s.Search(criteria)
 		if err != nil {
 			c.JSON(404, gin.H{"error": "no products found"})
 			return
 		}
+		if !h.inCurrency(c, products) {
+			return
+		}
+		web.Success(c, 200, products)
+	}
+}
+
+// Expiring devuelve los productos que vencen dentro del plazo within (por
+// defecto 30d), expresado en dias (30d o 30) o semanas (4w). Con
+// include_expired=true incluye los ya vencidos y con currency devuelve los
+// precios en otra moneda.
+func (h *productHandler) Expiring() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		days, err := parseDays(c.DefaultQuery("within", "30d"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid within, must be like 30d or 4w"))
+			return
+		}
+		includeExpired, err := strconv.ParseBool(c.DefaultQuery("include_expired", "false"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid include_expired"))
+			return
+		}
+		products, err := h.s.Expiring(days, includeExpired)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		if !h.inCurrency(c, products) {
+			return
+		}
 		web.Success(c, 200, products)
 	}
 }
 
+// parseDays interpreta un plazo en dias (30d o 30) o semanas (4w)
+func parseDays(value string) (int, error) {
+	unit := 1
+	switch {
+	case strings.HasSuffix(value, "d"):
+		value = strings.TrimSuffix(value, "d")
+	case strings.HasSuffix(value, "w"):
+		value, unit = strings.TrimSuffix(value, "w"), 7
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid number of days")
+	}
+	return n * unit, nil
+}
+
+// parseSearchCriteria arma los criterios de busqueda desde la query string
+func parseSearchCriteria(c *gin.Context) (store.SearchCriteria, error) {
+	criteria := store.SearchCriteria{
+		NameContains: c.Query("name"),
+		CodePrefix:   c.Query("code_prefix"),
+	}
+	amounts := map[string]**domain.Amount{"price_min": &criteria.PriceMin, "price_max": &criteria.PriceMax}
+	for key, target := range amounts {
+		if value, ok := c.GetQuery(key); ok {
+			amount, err := domain.ParseAmount(value)
+			if err != nil {
+				return criteria, errors.New("invalid " + key)
+			}
+			*target = &amount
+		}
+	}
+	if value, ok := c.GetQuery("priceGt"); ok {
+		price, err := domain.ParseAmount(value)
+		if err != nil {
+			return criteria, errors.New("invalid price")
+		}
+		// los precios tienen centavos: mayor que price es desde un centavo mas
+		min := price + 1
+		criteria.PriceMin = &min
+	}
+	ints := map[string]**int{"quantity_min": &criteria.QuantityMin, "quantity_max": &criteria.QuantityMax}
+	for key, target := range ints {
+		if value, ok := c.GetQuery(key); ok {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return criteria, errors.New("invalid " + key)
+			}
+			*target = &number
+		}
+	}
+	if value, ok := c.GetQuery("is_published"); ok {
+		published, err := strconv.ParseBool(value)
+		if err != nil {
+			return criteria, errors.New("invalid is_published")
+		}
+		criteria.IsPublished = &published
+	}
+	dates := map[string]**domain.Date{"expires_before": &criteria.ExpiresBefore, "expires_after": &criteria.ExpiresAfter}
+	for key, target := range dates {
+		if value, ok := c.GetQuery(key); ok {
+			date, err := domain.ParseDate(value)
+			if err != nil {
+				return criteria, errors.New("invalid " + key + ", must be in format: dd/mm/yyyy or yyyy-mm-dd")
+			}
+			*target = &date
+		}
+	}
+	return criteria, nil
+}
+
 // validateEmptys valida que los campos no esten vacios
 func validateEmptys(product *domain.Product) (bool, error) {
 	switch {
-	case product.Name == "" || product.CodeValue == "" || product.Expiration == "":
+	case product.Name == "" || product.CodeValue == "" || product.Expiration.IsZero():
 		return false, errors.New("fields can't be empty")
 	case product.Quantity <= 0 || product.Price <= 0:
 		if product.Quantity <= 0 {
@@ -84,42 +341,19 @@ func validateEmptys(product *domain.Product) (bool, error) {
 	return true, nil
 }
 
-// validateExpiration valida que la fecha de expiracion sea valida
-func validateExpiration(product *domain.Product) (bool, error) {
-	dates := strings.Split(product.Expiration, "/")
-	list := []int{}
-	if len(dates) != 3 {
-		return false, errors.New("invalid expiration date, must be in format: dd/mm/yyyy")
-	}
-	for value := range dates {
-		number, err := strconv.Atoi(dates[value])
-		if err != nil {
-			return false, errors.New("invalid expiration date, must be numbers")
-		}
-		list = append(list, number)
-	}
-	condition := (list[0] < 1 || list[0] > 31) && (list[1] < 1 || list[1] > 12) && (list[2] < 1 || list[2] > 9999)
-	if condition {
-		return false, errors.New("invalid expiration date, date must be between 1 and 31/12/9999")
-	}
-	return true, nil
-}
-
 // TODO is_active: Ningún dato puede estar vacío, exceptuando is_published (vacío indica un valor false).
 // Post crear un producto nuevo
 func (h *productHandler) Post() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("TOKEN")
-		if token == "" {
-			web.Failure(c, 401, errors.New("token not found"))
-			return
-		}
-		if token != os.Getenv("TOKEN") {
-			web.Failure(c, 401, errors.New("invalid token"))
+		if !web.Authorized(c) {
 			return
 		}
 		var product domain.Product
 		err := c.ShouldBindJSON(&product)
+		if errors.Is(err, domain.ErrInvalidDate) {
+			web.Failure(c, 400, err)
+			return
+		}
 		if err != nil {
 			web.Failure(c, 400, errors.New("invalid product"))
 			return
@@ -129,28 +363,18 @@ func (h *productHandler) Post() gin.HandlerFunc {
 			web.Failure(c, 400, err)
 			return
 		}
-		valid, err = validateExpiration(&product)
-		if !valid {
-			web.Failure(c, 400, err)
-			return
-		}
-		p, err := h.s.Create(product)
+		p, err := h.as(c).Create(product)
 		if err != nil {
 			web.Failure(c, 400, err)
 			return
 		}
+		web.SetETag(c, p.Version)
 		web.Success(c, 201, p)
 	}
 }
 func (h *productHandler) Put() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("TOKEN")
-		if token == "" {
-			web.Failure(c, 401, errors.New("token not found"))
-			return
-		}
-		if token != os.Getenv("TOKEN") {
-			web.Failure(c, 401, errors.New("invalid token"))
+		if !web.Authorized(c) {
 			return
 		}
 		idParam := c.Param("id")
@@ -164,42 +388,42 @@ func (h *productHandler) Put() gin.HandlerFunc {
 			web.Failure(c, 404, errors.New("product not found"))
 			return
 		}
-		if err != nil {
-			web.Failure(c, 409, err)
+		var body domain.Product
+		err = c.ShouldBindJSON(&body)
+		if errors.Is(err, domain.ErrInvalidDate) {
+			web.Failure(c, 400, err)
 			return
 		}
-		var product domain.Product
-		err = c.ShouldBindJSON(&product)
 		if err != nil {
 			web.Failure(c, 400, errors.New("invalid body"))
 			return
 		}
-		valid, err := validateEmptys(&product)
+		valid, err := validateEmptys(&body)
 		if !valid {
 			web.Failure(c, 400, err)
 			return
 		}
-		valid, err = validateExpiration(&product)
-		if !valid {
-			web.Failure(c, 400, err)
+		version, ok := web.IfMatch(c)
+		if !ok {
+			web.Failure(c, 412, errors.New("If-Match doesn't match the current version"))
 			return
 		}
+		body.Version = version
 
-		p, err := h.s.Update(id, product)
-		// if err != nil {
-		// 	if strings.Contains(err.Error(), "not found") {
-		// 		c.JSON(http.StatusNotFound, gin.H{
-		// 			"error": err,
-		// 		})
-		// 		return
-		// 	}
-
-		// 	c.JSON(http.StatusInternalServerError, gin.H{
-		// 		"error": err,
-		// 	})
-		// 	return
-		// }
-
+		p, err := h.as(c).Update(id, body)
+		if errors.Is(err, store.ErrVersionConflict) {
+			web.Failure(c, 412, err)
+			return
+		}
+		if errors.Is(err, store.ErrDuplicateCodeValue) || errors.Is(err, product.ErrReservedStock) {
+			web.Failure(c, 409, err)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.SetETag(c, p.Version)
 		web.Success(c, 200, p)
 	}
 }
@@ -207,13 +431,7 @@ func (h *productHandler) Put() gin.HandlerFunc {
 // Delete elimina un producto
 func (h *productHandler) Delete() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token := ctx.GetHeader("TOKEN")
-		if token == "" {
-			web.Failure(ctx, 401, errors.New("token not found"))
-			return
-		}
-		if token != os.Getenv("TOKEN") {
-			web.Failure(ctx, 401, errors.New("invalid token"))
+		if !web.Authorized(ctx) {
 			return
 		}
 		idParam := ctx.Param("id")
@@ -222,7 +440,16 @@ func (h *productHandler) Delete() gin.HandlerFunc {
 			ctx.JSON(400, gin.H{"error": "invalid id"})
 			return
 		}
-		err = h.s.Delete(id)
+		version, ok := web.IfMatch(ctx)
+		if !ok {
+			web.Failure(ctx, 412, errors.New("If-Match doesn't match the current version"))
+			return
+		}
+		err = h.as(ctx).Delete(id, version)
+		if errors.Is(err, store.ErrVersionConflict) {
+			web.Failure(ctx, 412, err)
+			return
+		}
 		if err != nil {
 			web.Failure(ctx, 404, err)
 			return
@@ -231,67 +458,204 @@ func (h *productHandler) Delete() gin.HandlerFunc {
 	}
 }
 
-// Patch update selected fields of a product WIP
-func (h *productHandler) Patch() gin.HandlerFunc {
-	type Request struct {
-		Name        string  `json:"name,omitempty"`
-		Quantity    int     `json:"quantity,omitempty"`
-		CodeValue   string  `json:"code_value,omitempty"`
-		IsPublished bool    `json:"is_published,omitempty"`
-		Expiration  string  `json:"expiration,omitempty"`
-		Price       float64 `json:"price,omitempty"`
+// GetTrash lista los productos borrados, el mas reciente primero
+func (h *productHandler) GetTrash() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !web.Authorized(ctx) {
+			return
+		}
+		products, err := h.s.Trash()
+		if err != nil {
+			web.Failure(ctx, 500, err)
+			return
+		}
+		web.Success(ctx, 200, products)
 	}
+}
+
+// Restore devuelve un producto de la papelera al catalogo
+func (h *productHandler) Restore() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token := ctx.GetHeader("TOKEN")
-		if token != os.Getenv("TOKEN") {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error": "token invalido",
-			})
+		if !web.Authorized(ctx) {
+			return
+		}
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			web.Failure(ctx, 400, errors.New("invalid id"))
+			return
+		}
+		p, err := h.as(ctx).Restore(id)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			web.Failure(ctx, 404, err)
+		case errors.Is(err, store.ErrDuplicateCodeValue):
+			web.Failure(ctx, 409, err)
+		case err != nil:
+			web.Failure(ctx, 500, err)
+		default:
+			web.SetETag(ctx, p.Version)
+			web.Success(ctx, 200, p)
+		}
+	}
+}
+
+// errInvalidPatched indica que el producto resultante de un parche no es valido
+var errInvalidPatched = errors.New("patched product is invalid")
+
+// Patch actualiza parte de un producto. Acepta JSON Merge Patch
+// (application/merge-patch+json, o application/json) y JSON Patch
+// (application/json-patch+json); el parche se aplica sobre el producto
+// guardado y el resultado se valida antes de guardarlo.
+func (h *productHandler) Patch() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !web.Authorized(ctx) {
 			return
 		}
-		var r Request
 		idParam := ctx.Param("id")
 		id, err := strconv.Atoi(idParam)
 		if err != nil {
-			ctx.JSON(400, gin.H{"error": "invalid id"})
+			web.Failure(ctx, 400, errors.New("invalid id"))
 			return
 		}
-		if err := ctx.ShouldBindJSON(&r); err != nil {
-			ctx.JSON(400, gin.H{"error": "invalid request"})
+		var apply func(doc, patch []byte) ([]byte, error)
+		switch ctx.ContentType() {
+		case jsonpatch.MergePatchType, "application/json":
+			apply = jsonpatch.MergePatch
+		case jsonpatch.JSONPatchType:
+			apply = jsonpatch.Apply
+		default:
+			web.Failure(ctx, 415, errors.New("content type must be "+jsonpatch.MergePatchType+" or "+jsonpatch.JSONPatchType))
 			return
 		}
-		update := domain.Product{
-			Name:        r.Name,
-			Quantity:    r.Quantity,
-			CodeValue:   r.CodeValue,
-			IsPublished: r.IsPublished,
-			Expiration:  r.Expiration,
-			Price:       r.Price,
+		patch, err := ctx.GetRawData()
+		if err != nil {
+			web.Failure(ctx, 400, errors.New("invalid request"))
+			return
 		}
-		if update.Expiration != "" {
-			valid, err := validateExpiration(&update)
-			if !valid {
-				ctx.JSON(400, gin.H{"error": err.Error()})
-				return
-			}
+		version, ok := web.IfMatch(ctx)
+		if !ok {
+			web.Failure(ctx, 412, errors.New("If-Match doesn't match the current version"))
+			return
+		}
+		if _, err := h.s.GetByID(id); err != nil {
+			web.Failure(ctx, 404, errors.New("product not found"))
+			return
+		}
+		p, err := h.as(ctx).Patch(id, version, func(current domain.Product) (domain.Product, error) {
+			return patchProduct(current, patch, apply)
+		})
+		switch {
+		case errors.Is(err, jsonpatch.ErrInvalidPatch):
+			web.Failure(ctx, 400, err)
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			web.Failure(ctx, 409, err)
+		case errors.Is(err, errInvalidPatched):
+			web.Failure(ctx, 422, err)
+		case errors.Is(err, store.ErrVersionConflict):
+			web.Failure(ctx, 412, err)
+		case errors.Is(err, store.ErrDuplicateCodeValue), errors.Is(err, product.ErrReservedStock):
+			web.Failure(ctx, 409, err)
+		case err != nil:
+			web.Failure(ctx, 500, err)
+		default:
+			web.SetETag(ctx, p.Version)
+			web.Success(ctx, 200, p)
+		}
+	}
+}
+
+// Rename cambia el code_value de un producto; el anterior queda como alias y
+// se sigue resolviendo en GET /products/code/:code_value y en las compras.
+// Acepta If-Match.
+func (h *productHandler) Rename() gin.HandlerFunc {
+	type request struct {
+		CodeValue string `json:"code_value" binding:"required"`
+	}
+	return func(ctx *gin.Context) {
+		if !web.Authorized(ctx) {
+			return
 		}
-		p, err := h.s.Update(id, update)
+		id, err := strconv.Atoi(ctx.Param("id"))
 		if err != nil {
-			ctx.JSON(500, gin.H{"error": err.Error()})
+			web.Failure(ctx, 400, errors.New("invalid id"))
+			return
+		}
+		var r request
+		if err := ctx.ShouldBindJSON(&r); err != nil || strings.TrimSpace(r.CodeValue) == "" {
+			web.Failure(ctx, 400, errors.New("code_value is required"))
 			return
 		}
-		ctx.JSON(200, p)
+		version, ok := web.IfMatch(ctx)
+		if !ok {
+			web.Failure(ctx, 412, errors.New("If-Match doesn't match the current version"))
+			return
+		}
+		p, err := h.as(ctx).Rename(id, strings.TrimSpace(r.CodeValue), version)
+		switch {
+		case errors.Is(err, store.ErrVersionConflict):
+			web.Failure(ctx, 412, err)
+		case errors.Is(err, store.ErrDuplicateCodeValue):
+			web.Failure(ctx, 409, err)
+		case err != nil:
+			web.Failure(ctx, 404, err)
+		default:
+			web.SetETag(ctx, p.Version)
+			web.Success(ctx, 200, p)
+		}
+	}
+}
+
+// patchProduct aplica el parche al producto y valida el resultado
+func patchProduct(current domain.Product, patch []byte, apply func(doc, patch []byte) ([]byte, error)) (domain.Product, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	if doc, err = apply(doc, patch); err != nil {
+		return domain.Product{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	var patched domain.Product
+	if err := decoder.Decode(&patched); err != nil {
+		return domain.Product{}, fmt.Errorf("%w: %v", errInvalidPatched, err)
+	}
+	if err := validatePatched(current, &patched); err != nil {
+		return domain.Product{}, fmt.Errorf("%w: %v", errInvalidPatched, err)
+	}
+	return patched, nil
+}
+
+// validatePatched valida un producto parcheado. A diferencia de un alta,
+// la cantidad puede quedar en 0.
+func validatePatched(current domain.Product, product *domain.Product) error {
+	switch {
+	case product.Id != current.Id:
+		return errors.New("id can't be changed")
+	case product.Version != current.Version:
+		return errors.New("version can't be changed, use If-Match")
+	case strings.Join(product.Aliases, "\x00") != strings.Join(current.Aliases, "\x00"):
+		return errors.New("aliases can't be changed, use rename")
+	case product.DeletedAt != nil:
+		return errors.New("deleted_at can't be changed, use DELETE or restore")
+	case product.Name == "" || product.CodeValue == "" || product.Expiration.IsZero():
+		return errors.New("fields can't be empty")
+	case product.Quantity < 0:
+		return errors.New("quantity can't be negative")
+	case product.Price <= 0:
+		return errors.New("price must be greater than 0")
 	}
+	return nil
 }
 
-// Buy comprar producto
+// Buy comprar producto. Con ?currency= el total se informa en esa moneda.
+// Esta deprecada: cambia el estado en un GET y compra un solo producto; se
+// reemplaza por POST /orders.
 func (h *productHandler) Buy() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("TOKEN")
-		if token != os.Getenv("TOKEN") {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "token invalido",
-			})
+		c.Header("Deprecation", "true")
+		c.Header("Link", `</orders>; rel="successor-version"`)
+		if !web.Authorized(c) {
 			return
 		}
 		code := c.Query("code_value")
@@ -302,24 +666,54 @@ func (h *productHandler) Buy() gin.HandlerFunc {
 			})
 			return
 		}
-		err = h.s.Buy(code, int(cant))
+		currency, err := currencyParam(c)
 		if err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+			web.Failure(c, 400, err)
 			return
 		}
-		p, e := h.s.GetByCodeValue(code)
-		if e != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": e,
-			})
+		version, ok := web.IfMatch(c)
+		if !ok {
+			web.Failure(c, 412, errors.New("If-Match doesn't match the current version"))
+			return
+		}
+		purchase, err := h.as(c).Buy(code, int(cant), version, currency)
+		if errors.Is(err, store.ErrVersionConflict) {
+			web.Failure(c, 412, err)
 			return
 		}
-		response := domain.Purchase{
-			CodeValue:  code,
-			Quantity:   int(cant),
-			TotalPrice: p.Price * float64(cant),
+		if errors.Is(err, product.ErrExpired) {
+			web.Failure(c, 409, err)
+			return
 		}
-		c.JSON(201, response)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(201, purchase)
 	}
 
 }
+
+// Transfer mueve stock de un producto a otro
+func (h *productHandler) Transfer() gin.HandlerFunc {
+	type Request struct {
+		From     string `json:"from" binding:"required"`
+		To       string `json:"to" binding:"required"`
+		Quantity int    `json:"quantity" binding:"required"`
+	}
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		var r Request
+		if err := c.ShouldBindJSON(&r); err != nil {
+			web.Failure(c, 400, errors.New("invalid request"))
+			return
+		}
+		if err := h.as(c).MoveStock(r.From, r.To, r.Quantity); err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		web.Success(c, 200, gin.H{"from": r.From, "to": r.To, "quantity": r.Quantity})
+	}
+}
@@ -0,0 +1,147 @@
+package productHandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/internal/product"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+const testToken = "test-token"
+
+// newTestServer arma el router de productos sobre un products.json temporal
+func newTestServer(t *testing.T, products []domain.Product, opts ...product.Option) (*gin.Engine, store.ProductStore) {
+	t.Helper()
+	t.Setenv("TOKEN", testToken)
+	gin.SetMode(gin.TestMode)
+
+	path := filepath.Join(t.TempDir(), "products.json")
+	data, err := json.Marshal(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	storage, err := store.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewProductHandler(product.NewService(product.NewRepository(storage), opts...))
+
+	r := gin.New()
+	r.GET("/products", h.GetAll())
+	r.GET("/products/buy", h.Buy())
+	r.GET("/products/expiring", h.Expiring())
+	r.GET("/products/:id", h.GetByID())
+	r.PUT("/products/:id", h.Put())
+	r.PATCH("/products/:id", h.Patch())
+	r.DELETE("/products/:id", h.Delete())
+	r.POST("/orders", h.Order())
+	r.POST("/products/import", h.Import())
+	r.GET("/products/export", h.Export())
+	return r, storage
+}
+
+func TestBuyConcurrentNeverOversells(t *testing.T) {
+	const (
+		stock    = 100
+		buyers   = 40
+		perBuyer = 5
+		quantity = 3
+	)
+	r, storage := newTestServer(t, []domain.Product{
+		{Id: 1, Name: "Oil", Quantity: stock, CodeValue: "S82254D", IsPublished: true, Expiration: domain.NewDate(2021, time.December, 15), Price: 10},
+	})
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		bought   int
+		rejected int
+	)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perBuyer; j++ {
+				req := httptest.NewRequest(http.MethodGet, "/products/buy?code_value=S82254D&quantity=3", nil)
+				req.Header.Set("TOKEN", testToken)
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, req)
+
+				mu.Lock()
+				switch rec.Code {
+				case http.StatusCreated:
+					bought += quantity
+				case http.StatusBadRequest:
+					rejected++
+				default:
+					t.Errorf("unexpected status %d: %s", rec.Code, rec.Body.String())
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	p, err := storage.GetByCodeValue("S82254D")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bought > stock {
+		t.Fatalf("oversold: bought %d of %d", bought, stock)
+	}
+	if p.Quantity != stock-bought {
+		t.Fatalf("lost update: quantity %d, want %d", p.Quantity, stock-bought)
+	}
+	if bought != stock-stock%quantity {
+		t.Fatalf("bought %d, want every available unit sold (%d)", bought, stock-stock%quantity)
+	}
+	if rejected == 0 {
+		t.Fatal("expected some purchases to be rejected once stock ran out")
+	}
+}
+
+func TestPatchPersistsFalseAndZero(t *testing.T) {
+	patches := map[string]string{
+		"application/merge-patch+json": `{"is_published":false,"quantity":0}`,
+		"application/json-patch+json":  `[{"op":"replace","path":"/is_published","value":false},{"op":"replace","path":"/quantity","value":0}]`,
+	}
+	for contentType, patch := range patches {
+		t.Run(contentType, func(t *testing.T) {
+			r, storage := newTestServer(t, []domain.Product{
+				{Id: 1, Name: "Oil", Quantity: 10, CodeValue: "S82254D", IsPublished: true, Expiration: domain.NewDate(2021, time.December, 15), Price: 10, Version: 1},
+			})
+			req := httptest.NewRequest(http.MethodPatch, "/products/1", strings.NewReader(patch))
+			req.Header.Set("TOKEN", testToken)
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+
+			p, err := storage.GetByID(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.IsPublished || p.Quantity != 0 {
+				t.Fatalf("is_published %v, quantity %d; want false and 0 persisted", p.IsPublished, p.Quantity)
+			}
+			if p.Name != "Oil" || p.Price != 10 || p.Version != 2 {
+				t.Fatalf("patch changed other fields: %+v", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package productHandler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/internal/product"
+	"github.com/mceciabate/web-server/internal/reservation"
+	"github.com/mceciabate/web-server/pkg/web"
+)
+
+// Reserve retiene stock de un producto mientras el cliente paga. ttl es el
+// plazo de la reserva (ej. 10m); sin ttl se usa el plazo por defecto.
+func (h *productHandler) Reserve() gin.HandlerFunc {
+	type Request struct {
+		CodeValue string `json:"code_value" binding:"required"`
+		Quantity  int    `json:"quantity" binding:"required"`
+		TTL       string `json:"ttl"`
+	}
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		var r Request
+		if err := c.ShouldBindJSON(&r); err != nil {
+			web.Failure(c, 400, errors.New("invalid request"))
+			return
+		}
+		var ttl time.Duration
+		if r.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(r.TTL); err != nil {
+				web.Failure(c, 400, errors.New("invalid ttl, must be like 15m"))
+				return
+			}
+		}
+		res, err := h.as(c).Reserve(r.CodeValue, r.Quantity, ttl)
+		if errors.Is(err, product.ErrInsufficientStock) || errors.Is(err, product.ErrExpired) {
+			web.Failure(c, 409, err)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		web.Success(c, 201, res)
+	}
+}
+
+// GetReservation obtiene una reserva por su id
+func (h *productHandler) GetReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid id"))
+			return
+		}
+		res, err := h.s.GetReservation(id)
+		if err != nil {
+			reservationFailure(c, err)
+			return
+		}
+		web.Success(c, 200, res)
+	}
+}
+
+// ConfirmReservation compra lo reservado. Con ?currency= la compra se
+// informa en esa moneda.
+func (h *productHandler) ConfirmReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid id"))
+			return
+		}
+		currency, err := currencyParam(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		purchase, err := h.as(c).ConfirmReservation(id, currency)
+		if err != nil {
+			reservationFailure(c, err)
+			return
+		}
+		web.Success(c, 201, purchase)
+	}
+}
+
+// CancelReservation libera lo reservado
+func (h *productHandler) CancelReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid id"))
+			return
+		}
+		res, err := h.as(c).CancelReservation(id)
+		if err != nil {
+			reservationFailure(c, err)
+			return
+		}
+		web.Success(c, 200, res)
+	}
+}
+
+// reservationFailure responde el error de una operacion sobre una reserva
+func reservationFailure(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, reservation.ErrNotFound):
+		web.Failure(c, 404, err)
+	case errors.Is(err, product.ErrReservationExpired):
+		web.Failure(c, 410, err)
+	case errors.Is(err, product.ErrReservationClosed), errors.Is(err, product.ErrExpired):
+		web.Failure(c, 409, err)
+	case errors.Is(err, product.ErrCurrency):
+		web.Failure(c, 422, err)
+	default:
+		web.Failure(c, 500, err)
+	}
+}
@@ -0,0 +1,143 @@
+package purchaseHandler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/internal/purchase"
+	"github.com/mceciabate/web-server/pkg/web"
+)
+
+type purchaseHandler struct {
+	s purchase.Service
+}
+
+// NewPurchaseHandler crea el controller de compras
+func NewPurchaseHandler(s purchase.Service) *purchaseHandler {
+	return &purchaseHandler{
+		s: s,
+	}
+}
+
+// GetAll devuelve las compras, la mas reciente primero. Acepta from y to
+// (fecha o fecha y hora ISO 8601; una fecha sola en to incluye todo ese dia),
+// limit y offset.
+func (h *purchaseHandler) GetAll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.list(c, 0)
+	}
+}
+
+// GetByProduct devuelve las compras de un producto, con los mismos
+// parametros que GetAll
+func (h *purchaseHandler) GetByProduct() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid id"))
+			return
+		}
+		h.list(c, id)
+	}
+}
+
+// GetByID obtiene una compra por su id
+func (h *purchaseHandler) GetByID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !web.Authorized(c) {
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("invalid id"))
+			return
+		}
+		p, err := h.s.GetByID(id)
+		if errors.Is(err, purchase.ErrNotFound) {
+			web.Failure(c, 404, err)
+			return
+		}
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, p)
+	}
+}
+
+// list responde una pagina de compras; productID 0 no filtra por producto
+func (h *purchaseHandler) list(c *gin.Context, productID int) {
+	if !web.Authorized(c) {
+		return
+	}
+	filter, err := parseFilter(c)
+	if err != nil {
+		web.Failure(c, 400, err)
+		return
+	}
+	filter.ProductID = productID
+	limit, offset := 0, 0
+	for key, target := range map[string]*int{"limit": &limit, "offset": &offset} {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		if *target, err = strconv.Atoi(value); err != nil || *target < 0 {
+			web.Failure(c, 400, errors.New("invalid "+key))
+			return
+		}
+	}
+	page, err := h.s.List(filter, limit, offset)
+	if err != nil {
+		web.Failure(c, 500, err)
+		return
+	}
+	web.SuccessPage(c, 200, page.Items, web.PageMeta{
+		Total:  page.Total,
+		Count:  len(page.Items),
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// parseFilter arma el rango de fechas desde la query string
+func parseFilter(c *gin.Context) (purchase.Filter, error) {
+	var filter purchase.Filter
+	if value := c.Query("from"); value != "" {
+		from, _, err := parseInstant(value)
+		if err != nil {
+			return filter, errors.New("invalid from, must be a date or an ISO 8601 date and time")
+		}
+		filter.From = from
+	}
+	if value := c.Query("to"); value != "" {
+		to, dateOnly, err := parseInstant(value)
+		if err != nil {
+			return filter, errors.New("invalid to, must be a date or an ISO 8601 date and time")
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = to
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, errors.New("from must be before to")
+	}
+	return filter, nil
+}
+
+// parseInstant lee una fecha y hora RFC 3339 o una fecha sola, que se toma
+// desde el comienzo del dia en UTC
+func parseInstant(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	date, err := domain.ParseDate(value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return date.Time, true, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// purger es un store con papelera que se puede purgar
+type purger interface {
+	Purge(before time.Time) (int, error)
+}
+
+// startPurge purga la papelera de los stores al arrancar y despues cada
+// TRASH_PURGE_INTERVAL, eliminando lo borrado hace mas de TRASH_RETENTION.
+// Un intervalo de 0 desactiva la purga.
+func startPurge(stores map[string]purger) error {
+	retention, err := time.ParseDuration(envOr("TRASH_RETENTION", "720h"))
+	if err != nil {
+		return fmt.Errorf("invalid TRASH_RETENTION: %w", err)
+	}
+	interval, err := time.ParseDuration(envOr("TRASH_PURGE_INTERVAL", "1h"))
+	if err != nil {
+		return fmt.Errorf("invalid TRASH_PURGE_INTERVAL: %w", err)
+	}
+	if interval <= 0 {
+		return nil
+	}
+	purgeTrash(stores, retention)
+	go func() {
+		for range time.Tick(interval) {
+			purgeTrash(stores, retention)
+		}
+	}()
+	return nil
+}
+
+// purgeTrash elimina de cada store lo que esta en la papelera hace mas de retention
+func purgeTrash(stores map[string]purger, retention time.Duration) {
+	before := time.Now().Add(-retention)
+	for name, s := range stores {
+		purged, err := s.Purge(before)
+		if err != nil {
+			log.Printf("purging %s trash: %v", name, err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("purged %d %s from the trash", purged, name)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// reservationExpirer libera las reservas vencidas
+type reservationExpirer interface {
+	ExpireReservations() (int, error)
+}
+
+// startReservationSweeper libera las reservas vencidas cada
+// RESERVATION_SWEEP_INTERVAL. Un intervalo de 0 lo desactiva.
+func startReservationSweeper(s reservationExpirer) error {
+	interval, err := time.ParseDuration(envOr("RESERVATION_SWEEP_INTERVAL", "1m"))
+	if err != nil {
+		return fmt.Errorf("invalid RESERVATION_SWEEP_INTERVAL: %w", err)
+	}
+	if interval <= 0 {
+		return nil
+	}
+	sweepReservations(s)
+	go func() {
+		for range time.Tick(interval) {
+			sweepReservations(s)
+		}
+	}()
+	return nil
+}
+
+// sweepReservations libera las reservas vencidas y registra cuantas libero
+func sweepReservations(s reservationExpirer) {
+	expired, err := s.ExpireReservations()
+	if err != nil {
+		log.Printf("releasing expired reservations: %v", err)
+		return
+	}
+	if expired > 0 {
+		log.Printf("released %d expired reservations", expired)
+	}
+}
@@ -0,0 +1,69 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+type Repository interface {
+	GetAll() ([]domain.ExchangeRate, error)
+	Get(c domain.Currency) (domain.ExchangeRate, error)
+	Save(rate domain.ExchangeRate) (domain.ExchangeRate, error)
+	Delete(c domain.Currency) error
+}
+
+// ErrNoRate indica una moneda sin cotizacion cargada
+var ErrNoRate = errors.New("no exchange rate")
+
+type repository struct {
+	storage store.Store[domain.ExchangeRate]
+}
+
+// NewRepository crea el repositorio de cotizaciones
+func NewRepository(storage store.Store[domain.ExchangeRate]) Repository {
+	return &repository{storage}
+}
+
+// GetAll devuelve todas las cotizaciones
+func (r *repository) GetAll() ([]domain.ExchangeRate, error) {
+	return r.storage.GetAll()
+}
+
+// Get devuelve la cotizacion de una moneda
+func (r *repository) Get(c domain.Currency) (domain.ExchangeRate, error) {
+	rates, err := r.storage.GetAll()
+	if err != nil {
+		return domain.ExchangeRate{}, err
+	}
+	for _, rate := range rates {
+		if rate.Currency == c {
+			return rate, nil
+		}
+	}
+	return domain.ExchangeRate{}, fmt.Errorf("%w for %s", ErrNoRate, c)
+}
+
+// Save crea o reemplaza la cotizacion de rate.Currency
+func (r *repository) Save(rate domain.ExchangeRate) (domain.ExchangeRate, error) {
+	current, err := r.Get(rate.Currency)
+	if errors.Is(err, ErrNoRate) {
+		return r.storage.Create(rate)
+	}
+	if err != nil {
+		return domain.ExchangeRate{}, err
+	}
+	rate.Id = current.Id
+	return rate, r.storage.Update(rate)
+}
+
+// Delete borra la cotizacion de una moneda
+func (r *repository) Delete(c domain.Currency) error {
+	current, err := r.Get(c)
+	if err != nil {
+		return err
+	}
+	return r.storage.Delete(current.Id)
+}
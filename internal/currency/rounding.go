@@ -0,0 +1,62 @@
+package currency
+
+import (
+	"errors"
+	"math/big"
+)
+
+// Rounding es la regla con la que se lleva un importe convertido a centavos
+type Rounding int
+
+const (
+	// HalfUp redondea al centavo mas cercano y, en el medio, se aleja del
+	// cero: 0.125 -> 0.13
+	HalfUp Rounding = iota
+	// HalfEven redondea al centavo mas cercano y, en el medio, al par:
+	// 0.125 -> 0.12, 0.135 -> 0.14
+	HalfEven
+	// Down descarta lo que pasa del centavo: 0.129 -> 0.12
+	Down
+)
+
+var roundingNames = map[Rounding]string{HalfUp: "half_up", HalfEven: "half_even", Down: "down"}
+
+// ErrInvalidRounding indica una regla de redondeo desconocida
+var ErrInvalidRounding = errors.New("invalid rounding, must be half_up, half_even or down")
+
+// ParseRounding lee una regla por su nombre: half_up, half_even o down
+func ParseRounding(name string) (Rounding, error) {
+	for r, n := range roundingNames {
+		if n == name {
+			return r, nil
+		}
+	}
+	return 0, ErrInvalidRounding
+}
+
+// String devuelve el nombre de la regla
+func (r Rounding) String() string {
+	return roundingNames[r]
+}
+
+// divide devuelve num / den (den > 0) redondeado con la regla
+func (r Rounding) divide(num, den *big.Int) *big.Int {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 || r == Down {
+		return quo
+	}
+	// compara el doble del resto con el divisor para saber si pasa del medio
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	away := false
+	switch cmp := twice.Cmp(den); {
+	case cmp > 0:
+		away = true
+	case cmp == 0:
+		away = r == HalfUp || quo.Bit(0) == 1
+	}
+	if away {
+		quo.Add(quo, big.NewInt(int64(num.Sign())))
+	}
+	return quo
+}
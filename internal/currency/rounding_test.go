@@ -0,0 +1,56 @@
+package currency
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRoundingDivide(t *testing.T) {
+	// los importes estan en decimas de centavo: 125 / 10 es 0.125
+	cases := []struct {
+		num, den int64
+		rounding Rounding
+		want     int64
+	}{
+		{125, 10, HalfUp, 13},
+		{125, 10, HalfEven, 12},
+		{125, 10, Down, 12},
+		{135, 10, HalfUp, 14},
+		{135, 10, HalfEven, 14},
+		{135, 10, Down, 13},
+		{129, 10, HalfUp, 13},
+		{129, 10, HalfEven, 13},
+		{129, 10, Down, 12},
+		{121, 10, HalfUp, 12},
+		{121, 10, HalfEven, 12},
+		{120, 10, HalfUp, 12},
+		{-125, 10, HalfUp, -13},
+		{-125, 10, HalfEven, -12},
+		{-125, 10, Down, -12},
+		{-135, 10, HalfEven, -14},
+		{-129, 10, HalfUp, -13},
+		{-129, 10, Down, -12},
+		{-121, 10, HalfUp, -12},
+		{1, 3, HalfUp, 0},
+		{2, 3, HalfUp, 1},
+		{-2, 3, HalfEven, -1},
+	}
+	for _, c := range cases {
+		got := c.rounding.divide(big.NewInt(c.num), big.NewInt(c.den))
+		if got.Int64() != c.want {
+			t.Fatalf("%d / %d with %s: got %s, want %d", c.num, c.den, c.rounding, got, c.want)
+		}
+	}
+}
+
+func TestParseRounding(t *testing.T) {
+	for _, r := range []Rounding{HalfUp, HalfEven, Down} {
+		parsed, err := ParseRounding(r.String())
+		if err != nil || parsed != r {
+			t.Fatalf("ParseRounding(%q): got %v %v, want %v", r.String(), parsed, err, r)
+		}
+	}
+	if _, err := ParseRounding("ceil"); err != ErrInvalidRounding {
+		t.Fatalf("unknown rounding: got %v, want ErrInvalidRounding", err)
+	}
+}
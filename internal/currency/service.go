@@ -0,0 +1,106 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+type Service interface {
+	List() ([]domain.ExchangeRate, error)
+	Set(c domain.Currency, rate domain.Rate) (domain.ExchangeRate, error)
+	Delete(c domain.Currency) error
+	// Convert pasa un importe de una moneda a otra con una sola division,
+	// a traves de BaseCurrency, y redondea el resultado a centavos
+	Convert(amount domain.Amount, from, to domain.Currency) (domain.Amount, error)
+	Rounding() Rounding
+}
+
+// ErrBaseCurrency indica un intento de cambiar la cotizacion de BaseCurrency
+var ErrBaseCurrency = errors.New("the base currency always has rate 1")
+
+// ErrOverflow indica un importe convertido que no entra en un Amount
+var ErrOverflow = errors.New("converted amount is out of range")
+
+type service struct {
+	r        Repository
+	rounding Rounding
+	now      func() time.Time
+	// mu serializa los cambios para que dos altas de la misma moneda no
+	// creen dos cotizaciones
+	mu sync.Mutex
+}
+
+// NewService crea el servicio de cotizaciones, que redondea las
+// conversiones con rounding
+func NewService(r Repository, rounding Rounding) Service {
+	return &service{r: r, rounding: rounding, now: time.Now}
+}
+
+// List devuelve las cotizaciones cargadas
+func (s *service) List() ([]domain.ExchangeRate, error) {
+	return s.r.GetAll()
+}
+
+// Set carga o actualiza la cotizacion de una moneda
+func (s *service) Set(c domain.Currency, rate domain.Rate) (domain.ExchangeRate, error) {
+	if c == domain.BaseCurrency {
+		return domain.ExchangeRate{}, fmt.Errorf("%w: %s", ErrBaseCurrency, c)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.r.Save(domain.ExchangeRate{Currency: c, Rate: rate, UpdatedAt: s.now().UTC()})
+}
+
+// Delete borra la cotizacion de una moneda
+func (s *service) Delete(c domain.Currency) error {
+	if c == domain.BaseCurrency {
+		return fmt.Errorf("%w: %s", ErrBaseCurrency, c)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.r.Delete(c)
+}
+
+// Rounding devuelve la regla con la que se redondean las conversiones
+func (s *service) Rounding() Rounding {
+	return s.rounding
+}
+
+// rate devuelve cuanto vale una unidad de c en BaseCurrency
+func (s *service) rate(c domain.Currency) (domain.Rate, error) {
+	if c.OrBase() == domain.BaseCurrency {
+		return domain.OneRate, nil
+	}
+	rate, err := s.r.Get(c)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Rate, nil
+}
+
+// Convert pasa amount de from a to. El calculo es exacto hasta el final:
+// amount * cotizacion(from) / cotizacion(to), redondeado una sola vez.
+func (s *service) Convert(amount domain.Amount, from, to domain.Currency) (domain.Amount, error) {
+	if from.OrBase() == to.OrBase() {
+		return amount, nil
+	}
+	fromRate, err := s.rate(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := s.rate(to)
+	if err != nil {
+		return 0, err
+	}
+	num := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(fromRate)))
+	converted := s.rounding.divide(num, big.NewInt(int64(toRate)))
+	if !converted.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return domain.Amount(converted.Int64()), nil
+}
@@ -0,0 +1,73 @@
+package currency
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// fixedRates es un repositorio con cotizaciones fijas
+type fixedRates map[domain.Currency]domain.Rate
+
+func (f fixedRates) GetAll() ([]domain.ExchangeRate, error) { return nil, nil }
+
+func (f fixedRates) Get(c domain.Currency) (domain.ExchangeRate, error) {
+	rate, ok := f[c]
+	if !ok {
+		return domain.ExchangeRate{}, ErrNoRate
+	}
+	return domain.ExchangeRate{Currency: c, Rate: rate}, nil
+}
+
+func (f fixedRates) Save(rate domain.ExchangeRate) (domain.ExchangeRate, error) { return rate, nil }
+
+func (f fixedRates) Delete(c domain.Currency) error { return nil }
+
+func TestConvert(t *testing.T) {
+	// 1 USD = 1000.5 ARS
+	rates := fixedRates{domain.USD: 1000500000}
+	cases := []struct {
+		amount   domain.Amount
+		from, to domain.Currency
+		rounding Rounding
+		want     domain.Amount
+	}{
+		{100, domain.USD, domain.ARS, HalfUp, 100050},
+		{100, domain.ARS, domain.USD, HalfUp, 0},
+		{1000500, domain.ARS, domain.USD, HalfUp, 1000},
+		{25, domain.USD, domain.ARS, HalfUp, 25013},
+		{25, domain.USD, domain.ARS, HalfEven, 25012},
+		{25, domain.USD, domain.ARS, Down, 25012},
+		{-25, domain.USD, domain.ARS, HalfUp, -25013},
+		{-25, domain.USD, domain.ARS, Down, -25012},
+		{700, domain.ARS, domain.ARS, HalfUp, 700},
+	}
+	for _, c := range cases {
+		got, err := NewService(rates, c.rounding).Convert(c.amount, c.from, c.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Fatalf("%s %s -> %s with %s: got %s, want %s", c.amount, c.from, c.to, c.rounding, got, c.want)
+		}
+	}
+}
+
+func TestConvertOverflow(t *testing.T) {
+	s := NewService(fixedRates{domain.USD: 1000500000}, HalfUp)
+	if _, err := s.Convert(math.MaxInt64, domain.USD, domain.ARS); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("got %v, want ErrOverflow", err)
+	}
+	if _, err := s.Convert(math.MinInt64, domain.USD, domain.ARS); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("negative: got %v, want ErrOverflow", err)
+	}
+}
+
+func TestConvertWithoutRate(t *testing.T) {
+	s := NewService(fixedRates{}, HalfUp)
+	if _, err := s.Convert(100, domain.USD, domain.ARS); !errors.Is(err, ErrNoRate) {
+		t.Fatalf("got %v, want ErrNoRate", err)
+	}
+}
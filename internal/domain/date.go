@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DateLayout es el formato con el que se escriben las fechas (ISO 8601)
+const DateLayout = "2006-01-02"
+
+// dateLayouts son los formatos que se aceptan al leer una fecha
+var dateLayouts = []string{DateLayout, "02/01/2006", time.RFC3339}
+
+// ErrInvalidDate indica una fecha que no existe o en un formato no soportado
+var ErrInvalidDate = errors.New("invalid date, must be in format dd/mm/yyyy or yyyy-mm-dd")
+
+// Date es un dia del calendario, sin hora. Se escribe como yyyy-mm-dd y se
+// lee en ese formato, en dd/mm/yyyy o como fecha y hora ISO 8601.
+type Date struct {
+	time.Time
+}
+
+// NewDate crea la fecha del dia dado
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// DateOf devuelve el dia de t, en su propia zona horaria
+func DateOf(t time.Time) Date {
+	return NewDate(t.Date())
+}
+
+// ParseDate lee una fecha de forma estricta: el dia y el mes tienen que
+// existir, ej. 31/02/2021 no es valida
+func ParseDate(value string) (Date, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return DateOf(t), nil
+		}
+	}
+	return Date{}, fmt.Errorf("%w: %q", ErrInvalidDate, value)
+}
+
+// String devuelve la fecha como yyyy-mm-dd, o "" si no tiene valor
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+// Before indica si la fecha es anterior a other
+func (d Date) Before(other Date) bool {
+	return d.Time.Before(other.Time)
+}
+
+// After indica si la fecha es posterior a other
+func (d Date) After(other Date) bool {
+	return d.Time.After(other.Time)
+}
+
+// AddDays devuelve la fecha n dias despues
+func (d Date) AddDays(n int) Date {
+	return Date{d.Time.AddDate(0, 0, n)}
+}
+
+// MarshalJSON escribe la fecha como "yyyy-mm-dd"
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON lee la fecha en cualquiera de los formatos aceptados. Una
+// cadena vacia deja la fecha sin valor.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	value, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDate, data)
+	}
+	if value == "" {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value guarda la fecha en la base como yyyy-mm-dd
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan lee una fecha guardada en la base
+func (d *Date) Scan(src any) error {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	case time.Time:
+		*d = DateOf(v)
+		return nil
+	case nil:
+		*d = Date{}
+		return nil
+	default:
+		return fmt.Errorf("can't scan %T into a date", src)
+	}
+	if value == "" {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	cases := []struct {
+		value string
+		want  Date
+	}{
+		{"2021-03-04", NewDate(2021, time.March, 4)},
+		{"04/03/2021", NewDate(2021, time.March, 4)},
+		{"29/02/2024", NewDate(2024, time.February, 29)},
+		{"2021-03-04T10:00:00Z", NewDate(2021, time.March, 4)},
+		{"2021-03-04T23:30:00-03:00", NewDate(2021, time.March, 4)},
+	}
+	for _, c := range cases {
+		got, err := ParseDate(c.value)
+		if err != nil {
+			t.Fatalf("ParseDate(%q): %v", c.value, err)
+		}
+		if !got.Equal(c.want.Time) {
+			t.Fatalf("ParseDate(%q): got %s, want %s", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseDateRejects(t *testing.T) {
+	for _, value := range []string{"", "45/13/2021", "31/02/2021", "29/02/2023", "2021-13-01", "2021-02-30", "4/3/21", "03-04-2021", "today"} {
+		if _, err := ParseDate(value); !errors.Is(err, ErrInvalidDate) {
+			t.Fatalf("ParseDate(%q): got %v, want ErrInvalidDate", value, err)
+		}
+	}
+}
+
+func TestDateRoundTrip(t *testing.T) {
+	for _, value := range []string{"04/03/2021", "2021-03-04", "2021-03-04T10:00:00Z"} {
+		d, err := ParseDate(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.String() != "2021-03-04" {
+			t.Fatalf("ParseDate(%q).String(): got %q", value, d.String())
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Date
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !back.Equal(d.Time) {
+			t.Fatalf("%s after a JSON round trip: got %s", data, back)
+		}
+	}
+}
+
+func TestDateJSONEmptyAndInvalid(t *testing.T) {
+	var d Date
+	if err := json.Unmarshal([]byte(`""`), &d); err != nil || !d.IsZero() {
+		t.Fatalf("empty date: %s %v", d, err)
+	}
+	if data, _ := json.Marshal(Date{}); string(data) != `""` {
+		t.Fatalf("zero date marshals as %s", data)
+	}
+	if err := json.Unmarshal([]byte(`"31/02/2021"`), &d); !errors.Is(err, ErrInvalidDate) {
+		t.Fatalf("invalid date: got %v, want ErrInvalidDate", err)
+	}
+}
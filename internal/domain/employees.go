@@ -1,7 +1,11 @@
 package domain
 
+import "time"
+
 type Employee struct {
-	Id     int    `json:"is"`
+	Id     int    `json:"id"`
 	Name   string `json:"name" binding:"required"`
 	Active bool   `json:"is_active" binding:"required"`
+	// DeletedAt es el momento de la baja; nil si el empleado no esta borrado
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
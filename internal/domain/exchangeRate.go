@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// ExchangeRate es la cotizacion de una moneda en BaseCurrency
+type ExchangeRate struct {
+	Id        int       `json:"id"`
+	Currency  Currency  `json:"currency"`
+	Rate      Rate      `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
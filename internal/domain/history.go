@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProductChange es una entrada del historial de un producto: que operacion
+// lo cambio, quien, cuando y como quedaron los campos que cambiaron
+type ProductChange struct {
+	ID        int                    `json:"id"`
+	ProductID int                    `json:"product_id"`
+	Op        string                 `json:"op"`
+	Version   int                    `json:"version"`
+	At        time.Time              `json:"at"`
+	Actor     string                 `json:"actor"`
+	Diff      map[string]FieldChange `json:"diff"`
+}
+
+// FieldChange es el valor json de un campo antes y despues del cambio; null
+// si el campo no tenia valor
+type FieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
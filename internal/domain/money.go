@@ -0,0 +1,242 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Currency es el codigo ISO 4217 de una moneda
+type Currency string
+
+const (
+	ARS Currency = "ARS"
+	USD Currency = "USD"
+)
+
+// BaseCurrency es la moneda en la que se expresan las cotizaciones y la de
+// los productos que no indican moneda
+const BaseCurrency = ARS
+
+// Currencies son las monedas en las que se vende
+var Currencies = []Currency{ARS, USD}
+
+// ErrInvalidCurrency indica una moneda en la que no se vende
+var ErrInvalidCurrency = errors.New("invalid currency, must be ARS or USD")
+
+// ParseCurrency lee un codigo de moneda, sin distinguir mayusculas
+func ParseCurrency(value string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(value)))
+	for _, known := range Currencies {
+		if c == known {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, value)
+}
+
+// UnmarshalJSON acepta solo las monedas en las que se vende, o una cadena
+// vacia que deja la moneda sin valor
+func (c *Currency) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCurrency, data)
+	}
+	if value == "" {
+		*c = ""
+		return nil
+	}
+	parsed, err := ParseCurrency(value)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// OrBase devuelve la moneda, o BaseCurrency si no tiene valor
+func (c Currency) OrBase() Currency {
+	if c == "" {
+		return BaseCurrency
+	}
+	return c
+}
+
+// AmountDecimals es la cantidad de decimales de un importe
+const AmountDecimals = 2
+
+// ErrInvalidAmount indica un importe mal escrito o con mas de dos decimales
+var ErrInvalidAmount = errors.New("invalid amount, must be a decimal number with at most 2 decimals")
+
+// Amount es un importe exacto, en centavos. Se escribe y se lee como un
+// numero decimal con dos decimales, ej. 71.42, sin pasar por float64.
+type Amount int64
+
+// ParseAmount lee un importe decimal como 71.42, -3 o 0.5. Falla si tiene
+// mas de dos decimales en lugar de redondearlo.
+func ParseAmount(value string) (Amount, error) {
+	a, err := parseFixed(value, AmountDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	return Amount(a), nil
+}
+
+// Mul devuelve el importe multiplicado por n
+func (a Amount) Mul(n int) Amount {
+	return a * Amount(n)
+}
+
+// Float64 devuelve el importe como float64; es aproximado, no se usa para
+// hacer cuentas
+func (a Amount) Float64() float64 {
+	return float64(a) / 100
+}
+
+// String devuelve el importe con dos decimales, ej. 71.42
+func (a Amount) String() string {
+	return formatFixed(int64(a), AmountDecimals)
+}
+
+// MarshalJSON escribe el importe como un numero con dos decimales
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON lee el importe como numero o como cadena
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, err := ParseAmount(unquoteNumber(data))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value guarda el importe en la base en centavos
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// Scan lee un importe guardado en centavos
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*a = Amount(v)
+	case nil:
+		*a = 0
+	default:
+		return fmt.Errorf("can't scan %T into an amount", src)
+	}
+	return nil
+}
+
+// RateDecimals es la cantidad de decimales de una cotizacion
+const RateDecimals = 6
+
+// rateUnit es la cotizacion 1, con RateDecimals decimales
+const rateUnit = 1000000
+
+// ErrInvalidRate indica una cotizacion mal escrita, no positiva o con mas de
+// seis decimales
+var ErrInvalidRate = errors.New("invalid rate, must be a positive decimal number with at most 6 decimals")
+
+// Rate es una cotizacion exacta con seis decimales: cuantas unidades de
+// BaseCurrency vale una unidad de otra moneda
+type Rate int64
+
+// OneRate es la cotizacion de BaseCurrency contra si misma
+const OneRate Rate = rateUnit
+
+// ParseRate lee una cotizacion decimal positiva como 1050.25
+func ParseRate(value string) (Rate, error) {
+	r, err := parseFixed(value, RateDecimals)
+	if err != nil || r <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidRate, value)
+	}
+	return Rate(r), nil
+}
+
+// String devuelve la cotizacion sin ceros de mas, ej. 1050.25
+func (r Rate) String() string {
+	s := formatFixed(int64(r), RateDecimals)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// MarshalJSON escribe la cotizacion como un numero
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON lee la cotizacion como numero o como cadena
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, err := ParseRate(unquoteNumber(data))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// unquoteNumber devuelve el texto de un numero json, aceptando que venga
+// entre comillas
+func unquoteNumber(data []byte) string {
+	if value, err := strconv.Unquote(string(data)); err == nil {
+		return value
+	}
+	return string(data)
+}
+
+// parseFixed lee un decimal como un entero escalado en 10^decimals, sin
+// redondear: mas decimales que los permitidos es un error
+func parseFixed(value string, decimals int) (int64, error) {
+	s := strings.TrimSpace(value)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, errors.New("empty number")
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > decimals {
+		return 0, errors.New("too many decimals")
+	}
+	digits := whole + fraction + strings.Repeat("0", decimals-len(fraction))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, errors.New("not a decimal number")
+		}
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, errors.New("number out of range")
+	}
+	if negative {
+		n = -n
+	}
+	return n, nil
+}
+
+// formatFixed escribe un entero escalado en 10^decimals como decimal
+func formatFixed(n int64, decimals int) string {
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	digits := strconv.FormatInt(n, 10)
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	cut := len(digits) - decimals
+	return sign + digits[:cut] + "." + digits[cut:]
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		value string
+		want  Amount
+	}{
+		{"71.42", 7142},
+		{"-3", -300},
+		{"0.5", 50},
+		{".5", 50},
+		{"+2.10", 210},
+		{"1.500", 150},
+		{" 7 ", 700},
+		{"0", 0},
+	}
+	for _, c := range cases {
+		got, err := ParseAmount(c.value)
+		if err != nil {
+			t.Fatalf("ParseAmount(%q): %v", c.value, err)
+		}
+		if got != c.want {
+			t.Fatalf("ParseAmount(%q): got %d, want %d", c.value, got, c.want)
+		}
+	}
+}
+
+func TestParseAmountRejects(t *testing.T) {
+	for _, value := range []string{"", "-", ".", "1.234", "0.001", "abc", "1,50", "1.2.3", "1e3", "--1", "92233720368547758.08"} {
+		if _, err := ParseAmount(value); !errors.Is(err, ErrInvalidAmount) {
+			t.Fatalf("ParseAmount(%q): got %v, want ErrInvalidAmount", value, err)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	got, err := ParseRate("1050.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1050250000 || got.String() != "1050.25" {
+		t.Fatalf("ParseRate(1050.25): got %d (%s)", got, got)
+	}
+	for _, value := range []string{"0", "0.000000", "-1", "", "1.0000001", "x", "9223372036854.775808"} {
+		if _, err := ParseRate(value); !errors.Is(err, ErrInvalidRate) {
+			t.Fatalf("ParseRate(%q): got %v, want ErrInvalidRate", value, err)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	cases := map[Amount]string{7142: "71.42", -300: "-3.00", 5: "0.05", -5: "-0.05", 0: "0.00"}
+	for amount, want := range cases {
+		if got := amount.String(); got != want {
+			t.Fatalf("Amount(%d).String(): got %q, want %q", int64(amount), got, want)
+		}
+	}
+}
@@ -1,11 +1,22 @@
 package domain
 
+import "time"
+
 type Product struct {
-	Id          int     `json:"id"`
-	Name        string  `json:"name" binding:"required"`
-	Quantity    int     `json:"quantity" binding:"required"`
-	CodeValue   string  `json:"code_value" binding:"required"`
-	IsPublished bool    `json:"is_published"`
-	Expiration  string  `json:"expiration" binding:"required"`
-	Price       float64 `json:"price" binding:"required"`
+	Id          int    `json:"id"`
+	Name        string `json:"name" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required"`
+	CodeValue   string `json:"code_value" binding:"required"`
+	IsPublished bool   `json:"is_published"`
+	Expiration  Date   `json:"expiration" binding:"required"`
+	Price       Amount `json:"price" binding:"required"`
+	// Currency es la moneda de Price; vacia es BaseCurrency
+	Currency Currency `json:"currency"`
+	// Version se incrementa con cada cambio; la asigna el store
+	Version int `json:"version"`
+	// Aliases son los code_value anteriores a un renombre, que se siguen
+	// resolviendo al buscar por code_value; los asigna el store
+	Aliases []string `json:"aliases,omitempty"`
+	// DeletedAt es el momento de la baja; nil si el producto no esta borrado
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
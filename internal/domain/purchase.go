@@ -1,7 +1,33 @@
 package domain
 
+import "time"
+
 type Purchase struct {
-	CodeValue  string  `json:"code_value" binding:"required"`
-	Quantity   int     `json:"quantity" binding:"required"`
-	TotalPrice float64 `json:"total_price" binding:"required"`
+	// Id, ProductID, At y Actor se asignan al registrar la compra
+	Id        int    `json:"id,omitempty"`
+	ProductID int    `json:"product_id,omitempty"`
+	CodeValue string `json:"code_value" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required"`
+	// UnitPrice y TotalPrice estan en Currency. Al convertir se redondea cada
+	// uno por separado, asi que TotalPrice puede no ser UnitPrice * Quantity.
+	UnitPrice  Amount    `json:"unit_price"`
+	TotalPrice Amount    `json:"total_price" binding:"required"`
+	Currency   Currency  `json:"currency"`
+	At         time.Time `json:"at"`
+	// Actor es a nombre de quien se hizo la compra
+	Actor string `json:"actor,omitempty"`
+}
+
+// PurchaseItem es un producto y la cantidad a comprar
+type PurchaseItem struct {
+	CodeValue string `json:"code_value" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required"`
+}
+
+// Order es un pedido de varios productos comprados juntos. Cada linea es la
+// compra de un producto, valuada en Currency; Total es la suma de las lineas.
+type Order struct {
+	Lines    []Purchase `json:"lines"`
+	Total    Amount     `json:"total"`
+	Currency Currency   `json:"currency"`
 }
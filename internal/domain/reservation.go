@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// Estados de una reserva. Solo una reserva activa y sin vencer retiene stock.
+const (
+	ReservationActive    = "active"
+	ReservationConfirmed = "confirmed"
+	ReservationCancelled = "cancelled"
+	ReservationExpired   = "expired"
+)
+
+// Reservation retiene Quantity unidades de un producto hasta ExpiresAt,
+// mientras el cliente paga
+type Reservation struct {
+	Id        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	CodeValue string    `json:"code_value"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// ClosedAt es cuando se confirmo, cancelo o libero por vencida
+	ClosedAt *time.Time `json:"closed_at,omitempty"`
+	// PurchaseID es la compra en la que se confirmo
+	PurchaseID int    `json:"purchase_id,omitempty"`
+	Actor      string `json:"actor,omitempty"`
+}
+
+// Holds indica si la reserva retiene stock en el instante now
+func (r Reservation) Holds(now time.Time) bool {
+	return r.Status == ReservationActive && now.Before(r.ExpiresAt)
+}
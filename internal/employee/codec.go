@@ -0,0 +1,50 @@
+package employee
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/csvcodec"
+)
+
+// csvColumns son las columnas de employees.csv, en orden. deleted_at es
+// opcional: solo la tienen los empleados que estan en la papelera.
+var csvColumns = []string{"id", "name", "is_active", "deleted_at"}
+
+// NewCSVCodec crea el codec de empleados; lee ambos dialectos y escribe en dialect
+func NewCSVCodec(dialect csvcodec.Dialect) *csvcodec.Codec[domain.Employee] {
+	return csvcodec.New(dialect, csvColumns, decodeEmployee, encodeEmployee).Optional(1)
+}
+
+func decodeEmployee(record []string) (domain.Employee, error) {
+	id, err := strconv.Atoi(record[0])
+	if err != nil {
+		return domain.Employee{}, errors.New("invalid id " + strconv.Quote(record[0]))
+	}
+	if record[1] == "" {
+		return domain.Employee{}, errors.New("name field can't be empty")
+	}
+	active, err := strconv.ParseBool(record[2])
+	if err != nil {
+		return domain.Employee{}, errors.New("invalid is_active " + strconv.Quote(record[2]))
+	}
+	e := domain.Employee{Id: id, Name: record[1], Active: active}
+	if record[3] != "" {
+		deletedAt, err := time.Parse(time.RFC3339Nano, record[3])
+		if err != nil {
+			return domain.Employee{}, errors.New("invalid deleted_at " + strconv.Quote(record[3]))
+		}
+		e.DeletedAt = &deletedAt
+	}
+	return e, nil
+}
+
+func encodeEmployee(e domain.Employee) ([]string, error) {
+	deletedAt := ""
+	if e.DeletedAt != nil {
+		deletedAt = e.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return []string{strconv.Itoa(e.Id), e.Name, strconv.FormatBool(e.Active), deletedAt}, nil
+}
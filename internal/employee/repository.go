@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
 )
 
 type RepositoryE interface {
@@ -13,66 +14,74 @@ type RepositoryE interface {
 	Update(p domain.Employee) error
 	Delete(id int) error
 	FilterActive() ([]domain.Employee, error)
+	Trash() ([]domain.Employee, error)
+	Undelete(id int) (domain.Employee, error)
 }
 
 type repositoryE struct {
-	listEmployee []domain.Employee
+	storage store.TrashStore[domain.Employee]
 }
 
 // NewRepository crea un nuevo repositorio
-func NewRepository(list []domain.Employee) RepositoryE {
-	return &repositoryE{list}
+func NewRepository(storage store.TrashStore[domain.Employee]) RepositoryE {
+	return &repositoryE{storage}
 }
 
 // GetAll devuelve todos los empleados
 func (r *repositoryE) GetAll() []domain.Employee {
-	return r.listEmployee
+	employees, err := r.storage.GetAll()
+	if err != nil {
+		return []domain.Employee{}
+	}
+	return employees
 }
 
 // GetByID busca un empleado por su id
 func (r *repositoryE) GetByID(id int) (domain.Employee, error) {
-	for _, e := range r.listEmployee {
-		if e.Id == id {
-			return e, nil
-		}
+	e, err := r.storage.GetByID(id)
+	if err != nil {
+		return domain.Employee{}, notFound(err)
 	}
-	return domain.Employee{}, errors.New("employee not found")
-
+	return e, nil
 }
 
 // Create agrega un nuevo empleado
 func (r *repositoryE) Create(e domain.Employee) (domain.Employee, error) {
-	e.Id = len(r.listEmployee) + 1
-	r.listEmployee = append(r.listEmployee, e)
+	e, err := r.storage.Create(e)
+	if err != nil {
+		return domain.Employee{}, errors.New("error creating employee")
+	}
 	return e, nil
 }
 
 // Actualizar un empleado
 func (r *repositoryE) Update(e domain.Employee) error {
-	for i, e := range r.listEmployee {
-		if e.Id == e.Id {
-			r.listEmployee[i] = e
-			return nil
-		}
-	}
-	return errors.New("employee not found")
+	return notFound(r.storage.Update(e))
 }
 
-// Delete elimina un employee
+// Delete manda un empleado a la papelera
 func (r *repositoryE) Delete(id int) error {
-	for i, e := range r.listEmployee {
-		if e.Id == id {
-			r.listEmployee = append(r.listEmployee[:i], r.listEmployee[i+1:]...)
-			return nil
-		}
+	return notFound(r.storage.Delete(id))
+}
+
+// Trash devuelve los empleados borrados, el mas reciente primero
+func (r *repositoryE) Trash() ([]domain.Employee, error) {
+	return r.storage.Trash()
+}
+
+// Undelete devuelve un empleado de la papelera
+func (r *repositoryE) Undelete(id int) (domain.Employee, error) {
+	e, err := r.storage.Undelete(id)
+	if err != nil {
+		return domain.Employee{}, notFound(err)
 	}
-	return errors.New("product not found")
+	return e, nil
 }
 
 func (r repositoryE) FilterActive() ([]domain.Employee, error) {
 	var employees []domain.Employee
-	for _, e := range r.listEmployee {
-		if e.Active == true {
+	for _, e := range r.GetAll() {
+		if e.Active {
 			employees = append(employees, e)
 		}
 
@@ -82,3 +91,11 @@ func (r repositoryE) FilterActive() ([]domain.Employee, error) {
 	}
 	return employees, nil
 }
+
+// notFound traduce el error generico del store al mensaje de empleados
+func notFound(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return errors.New("employee not found")
+	}
+	return err
+}
@@ -9,6 +9,8 @@ type ServiceE interface {
 	Update(id int, e domain.Employee) (domain.Employee, error)
 	Delete(id int) error
 	FilterActive() ([]domain.Employee, error)
+	Trash() ([]domain.Employee, error)
+	Restore(id int) (domain.Employee, error)
 }
 
 type serviceE struct {
@@ -58,7 +60,7 @@ func (s *serviceE) Update(id int, e domain.Employee) (domain.Employee, error) {
 	return e, nil
 }
 
-// Delete elimina un producto
+// Delete manda un empleado a la papelera
 func (s *serviceE) Delete(id int) error {
 	err := s.r.Delete(id)
 	if err != nil {
@@ -74,3 +76,13 @@ func (s serviceE) FilterActive() ([]domain.Employee, error) {
 	}
 	return lE, nil
 }
+
+// Trash devuelve los empleados borrados, el mas reciente primero
+func (s *serviceE) Trash() ([]domain.Employee, error) {
+	return s.r.Trash()
+}
+
+// Restore devuelve un empleado de la papelera
+func (s *serviceE) Restore(id int) (domain.Employee, error) {
+	return s.r.Undelete(id)
+}
@@ -0,0 +1,96 @@
+package employee
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/csvcodec"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// openEmployees abre el csv de empleados con baja logica, como lo arma el
+// servidor
+func openEmployees(path string) store.TrashStore[domain.Employee] {
+	return store.NewFileStore[domain.Employee](path, NewCSVCodec(csvcodec.Braces),
+		func(e *domain.Employee) *int { return &e.Id },
+		store.WithSoftDelete(func(e *domain.Employee) **time.Time { return &e.DeletedAt }),
+	)
+}
+
+// newTrashFixture crea un servicio de empleados con dos empleados cargados
+func newTrashFixture(t *testing.T) (ServiceE, store.TrashStore[domain.Employee], string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "employees.csv")
+	storage := openEmployees(path)
+	s := NewService(NewRepository(storage))
+	for _, name := range []string{"Ana", "Luis"} {
+		if _, err := s.Create(domain.Employee{Name: name, Active: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, storage, path
+}
+
+func TestEmployeeTrashAndRestore(t *testing.T) {
+	s, _, path := newTrashFixture(t)
+
+	if err := s.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetByID(1); err == nil {
+		t.Fatal("a deleted employee must not be found")
+	}
+	if all, err := s.GetAll(); err != nil || len(all) != 1 || all[0].Name != "Luis" {
+		t.Fatalf("GetAll after deleting: %+v %v", all, err)
+	}
+	trash, err := s.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Id != 1 || trash[0].DeletedAt == nil {
+		t.Fatalf("trash: %+v", trash)
+	}
+	reopened := openEmployees(path)
+	if trash, err := reopened.Trash(); err != nil || len(trash) != 1 || trash[0].Id != 1 {
+		t.Fatalf("the deletion must be saved in the file: %+v %v", trash, err)
+	}
+
+	restored, err := s.Restore(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil || restored.Name != "Ana" {
+		t.Fatalf("restored employee: %+v", restored)
+	}
+	if _, err := s.GetByID(1); err != nil {
+		t.Fatalf("a restored employee must be found: %v", err)
+	}
+	if _, err := s.Restore(2); err == nil || err.Error() != "employee not found" {
+		t.Fatalf("restore of an employee outside the trash: got %v", err)
+	}
+}
+
+func TestEmployeePurge(t *testing.T) {
+	s, storage, _ := newTrashFixture(t)
+	if err := s.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if purged, err := storage.Purge(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Fatalf("purge before the deletion: %d %v, want 0", purged, err)
+	}
+	if purged, err := storage.Purge(time.Now().Add(time.Second)); err != nil || purged != 1 {
+		t.Fatalf("purge after the deletion: %d %v, want 1", purged, err)
+	}
+	if trash, err := s.Trash(); err != nil || len(trash) != 0 {
+		t.Fatalf("trash after purge: %+v %v", trash, err)
+	}
+	if _, err := s.Restore(2); err == nil {
+		t.Fatal("a purged employee can't be restored")
+	}
+	if all, err := s.GetAll(); err != nil || len(all) != 1 || all[0].Id != 1 {
+		t.Fatalf("purge must keep the other employees: %+v %v", all, err)
+	}
+}
@@ -0,0 +1,93 @@
+package product
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/csvcodec"
+)
+
+// csvColumns son las columnas del csv de productos, en orden
+var csvColumns = []string{"id", "name", "quantity", "code_value", "is_published", "expiration", "price", "currency", "version"}
+
+// importColumns son las columnas que se leen al importar: el id y la version
+// los asigna el store, asi que un export se puede volver a importar. La
+// moneda es opcional; sin ella el precio esta en domain.BaseCurrency.
+var importColumns = csvColumns[1:8]
+
+// NewCSVCodec crea el codec con el que se exporta el catalogo
+func NewCSVCodec() *csvcodec.Codec[domain.Product] {
+	return csvcodec.New(csvcodec.RFC4180, csvColumns, decodeProduct, encodeProduct)
+}
+
+// NewImportCSVCodec crea el codec con el que se leen los productos a importar
+func NewImportCSVCodec() *csvcodec.Codec[domain.Product] {
+	return csvcodec.New(csvcodec.RFC4180, importColumns, decodeImportedProduct, func(p domain.Product) ([]string, error) {
+		record, err := encodeProduct(p)
+		return record[1:8], err
+	}).Optional(1)
+}
+
+func decodeProduct(record []string) (domain.Product, error) {
+	id, err := strconv.Atoi(record[0])
+	if err != nil {
+		return domain.Product{}, errors.New("invalid id " + strconv.Quote(record[0]))
+	}
+	version, err := strconv.Atoi(record[8])
+	if err != nil {
+		return domain.Product{}, errors.New("invalid version " + strconv.Quote(record[8]))
+	}
+	p, err := decodeImportedProduct(record[1:8])
+	p.Id = id
+	p.Version = version
+	return p, err
+}
+
+func decodeImportedProduct(record []string) (domain.Product, error) {
+	quantity, err := strconv.Atoi(record[1])
+	if err != nil {
+		return domain.Product{}, errors.New("invalid quantity " + strconv.Quote(record[1]))
+	}
+	published, err := strconv.ParseBool(record[3])
+	if err != nil {
+		return domain.Product{}, errors.New("invalid is_published " + strconv.Quote(record[3]))
+	}
+	expiration, err := domain.ParseDate(record[4])
+	if err != nil {
+		return domain.Product{}, err
+	}
+	price, err := domain.ParseAmount(record[5])
+	if err != nil {
+		return domain.Product{}, err
+	}
+	var currency domain.Currency
+	if record[6] != "" {
+		if currency, err = domain.ParseCurrency(record[6]); err != nil {
+			return domain.Product{}, err
+		}
+	}
+	return domain.Product{
+		Name:        record[0],
+		Quantity:    quantity,
+		CodeValue:   record[2],
+		IsPublished: published,
+		Expiration:  expiration,
+		Price:       price,
+		Currency:    currency,
+	}, nil
+}
+
+func encodeProduct(p domain.Product) ([]string, error) {
+	return []string{
+		strconv.Itoa(p.Id),
+		p.Name,
+		strconv.Itoa(p.Quantity),
+		p.CodeValue,
+		strconv.FormatBool(p.IsPublished),
+		p.Expiration.String(),
+		p.Price.String(),
+		string(p.Currency.OrBase()),
+		strconv.Itoa(p.Version),
+	}, nil
+}
@@ -0,0 +1,74 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// ErrCurrency indica que no se pudo valuar una compra en la moneda pedida
+var ErrCurrency = errors.New("can't value the purchase in the requested currency")
+
+// Converter pasa importes de una moneda a otra
+type Converter interface {
+	Convert(amount domain.Amount, from, to domain.Currency) (domain.Amount, error)
+}
+
+// WithConverter hace que el servicio pueda valuar los productos y las
+// compras en otra moneda. Sin converter solo se puede pedir la moneda del
+// producto.
+func WithConverter(c Converter) Option {
+	return func(s *service) {
+		s.converter = c
+	}
+}
+
+// sameCurrency es el converter por defecto: no tiene cotizaciones
+type sameCurrency struct{}
+
+func (sameCurrency) Convert(amount domain.Amount, from, to domain.Currency) (domain.Amount, error) {
+	if from.OrBase() != to.OrBase() {
+		return 0, fmt.Errorf("can't convert %s to %s, there are no exchange rates", from.OrBase(), to.OrBase())
+	}
+	return amount, nil
+}
+
+// InCurrency devuelve el producto con el precio en currency. Vacia deja el
+// producto como esta.
+func (s *service) InCurrency(p domain.Product, currency domain.Currency) (domain.Product, error) {
+	if currency == "" {
+		return p, nil
+	}
+	price, err := s.converter.Convert(p.Price, p.Currency, currency)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	p.Price, p.Currency = price, currency
+	return p, nil
+}
+
+// quote valua la compra de quantity unidades de p en currency (vacia es la
+// moneda del producto). El total se calcula exacto en la moneda del producto
+// y se convierte una sola vez, asi el redondeo no se multiplica por la
+// cantidad.
+func (s *service) quote(code string, p domain.Product, quantity int, currency domain.Currency) (domain.Purchase, error) {
+	if currency == "" {
+		currency = p.Currency.OrBase()
+	}
+	unit, err := s.converter.Convert(p.Price, p.Currency, currency)
+	if err != nil {
+		return domain.Purchase{}, fmt.Errorf("%w: %v", ErrCurrency, err)
+	}
+	total, err := s.converter.Convert(p.Price.Mul(quantity), p.Currency, currency)
+	if err != nil {
+		return domain.Purchase{}, fmt.Errorf("%w: %v", ErrCurrency, err)
+	}
+	return domain.Purchase{
+		CodeValue:  code,
+		Quantity:   quantity,
+		UnitPrice:  unit,
+		TotalPrice: total,
+		Currency:   currency,
+	}, nil
+}
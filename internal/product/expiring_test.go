@@ -0,0 +1,54 @@
+package product
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+func TestExpiring(t *testing.T) {
+	f := newProductFixture(t)
+	f.s.now = func() time.Time { return time.Date(2026, time.June, 10, 15, 0, 0, 0, time.UTC) }
+	for code, expiration := range map[string]domain.Date{
+		"LAST":      domain.NewDate(2026, time.July, 10),
+		"BEYOND":    domain.NewDate(2026, time.July, 11),
+		"SOON":      domain.NewDate(2026, time.June, 20),
+		"TODAY":     domain.NewDate(2026, time.June, 10),
+		"YESTERDAY": domain.NewDate(2026, time.June, 9),
+	} {
+		p := domain.Product{Name: code, Quantity: 1, CodeValue: code, Expiration: expiration, Price: 100}
+		if _, err := f.s.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		within         int
+		includeExpired bool
+		want           []string
+	}{
+		{30, false, []string{"TODAY", "SOON", "LAST"}},
+		{30, true, []string{"YESTERDAY", "TODAY", "SOON", "LAST"}},
+		{0, false, []string{"TODAY"}},
+		{10, false, []string{"TODAY", "SOON"}},
+	}
+	for _, c := range cases {
+		products, err := f.s.Expiring(c.within, c.includeExpired)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range products {
+			got = append(got, p.CodeValue)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("Expiring(%d, %t): got %v, want %v", c.within, c.includeExpired, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("Expiring(%d, %t): got %v, want %v", c.within, c.includeExpired, got, c.want)
+			}
+		}
+	}
+}
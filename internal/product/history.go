@@ -0,0 +1,116 @@
+package product
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// Operaciones que se registran en el historial de un producto
+const (
+	ChangeCreate   = "create"
+	ChangeUpdate   = "update"
+	ChangePatch    = "patch"
+	ChangeRename   = "rename"
+	ChangeDelete   = "delete"
+	ChangeRestore  = "restore"
+	ChangeBuy      = "buy"
+	ChangeTransfer = "transfer"
+	ChangeImport   = "import"
+)
+
+// SystemActor es el autor de los cambios hechos sin un actor asignado
+const SystemActor = "system"
+
+// As devuelve el mismo servicio pero registrando los cambios a nombre de actor
+func (s *service) As(actor string) Service {
+	scoped := *s
+	scoped.actor = actor
+	return &scoped
+}
+
+// History devuelve una pagina del historial de un producto, el cambio mas
+// reciente primero. El historial se conserva aunque el producto se purgue;
+// un producto sin cambios registrados devuelve una pagina vacia.
+func (s *service) History(id, limit, offset int) (store.Page[domain.ProductChange], error) {
+	page, err := s.r.History(id, limit, offset)
+	if err != nil {
+		return store.Page[domain.ProductChange]{}, err
+	}
+	if page.Total == 0 && !s.exists(id) {
+		return page, errors.New("product not found")
+	}
+	return page, nil
+}
+
+// exists indica si el producto esta en el catalogo o en la papelera
+func (s *service) exists(id int) bool {
+	if _, err := s.r.GetByID(id); err == nil {
+		return true
+	}
+	err := s.r.Transaction(func(tx store.Tx) error {
+		_, err := tx.GetDeleted(id)
+		return err
+	})
+	return err == nil
+}
+
+// record agrega al historial el cambio de before a after dentro de la
+// transaccion tx, asi se guarda junto con el cambio o no se guarda. before es
+// nil si el producto es nuevo.
+func (s *service) record(tx store.Tx, op string, before *domain.Product, after domain.Product) error {
+	diff, err := diffProducts(before, after)
+	if err != nil {
+		return err
+	}
+	return tx.AppendHistory(domain.ProductChange{
+		ProductID: after.Id,
+		Op:        op,
+		Version:   after.Version,
+		At:        s.now().UTC(),
+		Actor:     s.actor,
+		Diff:      diff,
+	})
+}
+
+// diffProducts compara los campos json de los dos productos y devuelve los
+// que cambiaron. La version no se incluye: ya esta en la entrada.
+func diffProducts(before *domain.Product, after domain.Product) (map[string]domain.FieldChange, error) {
+	old := map[string]json.RawMessage{}
+	if before != nil {
+		var err error
+		if old, err = productFields(*before); err != nil {
+			return nil, err
+		}
+	}
+	current, err := productFields(after)
+	if err != nil {
+		return nil, err
+	}
+	diff := map[string]domain.FieldChange{}
+	add := func(name string) {
+		if name != "version" && !bytes.Equal(old[name], current[name]) {
+			diff[name] = domain.FieldChange{Before: old[name], After: current[name]}
+		}
+	}
+	for name := range old {
+		add(name)
+	}
+	for name := range current {
+		add(name)
+	}
+	return diff, nil
+}
+
+// productFields devuelve cada campo del producto con su valor json
+func productFields(p domain.Product) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	return fields, json.Unmarshal(data, &fields)
+}
@@ -0,0 +1,39 @@
+package product
+
+import "testing"
+
+func TestHistoryOfProductWithoutChanges(t *testing.T) {
+	f := newProductFixture(t)
+
+	page, err := f.s.History(2, 0, 0)
+	if err != nil {
+		t.Fatalf("a product without changes must have an empty history: %v", err)
+	}
+	if page.Total != 0 || len(page.Items) != 0 {
+		t.Fatalf("history of an untouched product: %+v, want empty", page)
+	}
+	if _, err := f.s.History(99, 0, 0); err == nil {
+		t.Fatal("history of an unknown product must fail")
+	}
+}
+
+func TestHistoryRecordsChanges(t *testing.T) {
+	f := newProductFixture(t)
+	if _, err := f.s.Buy("A1", 2, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.s.Delete(1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := f.s.History(1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Items[0].Op != ChangeDelete || page.Items[1].Op != ChangeBuy {
+		t.Fatalf("history: %+v, want delete then buy", page.Items)
+	}
+	if diff := page.Items[1].Diff["quantity"]; string(diff.Before) != "10" || string(diff.After) != "8" {
+		t.Fatalf("buy diff: %s -> %s, want 10 -> 8", diff.Before, diff.After)
+	}
+}
@@ -0,0 +1,127 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// Estados de una fila importada
+const (
+	ImportCreated  = "created"
+	ImportUpdated  = "updated"
+	ImportRejected = "rejected"
+)
+
+// ErrImportRejected indica que una importacion todo-o-nada tuvo filas
+// rechazadas y no se guardo ninguna
+var ErrImportRejected = errors.New("import has rejected rows, nothing was saved")
+
+// ImportRow es un producto a importar. Err tiene el motivo si la fila ya se
+// rechazo al leerla o validarla.
+type ImportRow struct {
+	Line    int
+	Product domain.Product
+	Err     error
+}
+
+// ImportResult es lo que paso con una fila
+type ImportResult struct {
+	Line      int    `json:"line"`
+	CodeValue string `json:"code_value,omitempty"`
+	Status    string `json:"status"`
+	ID        int    `json:"id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// ImportReport resume una importacion
+type ImportReport struct {
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Rejected  int            `json:"rejected"`
+	Committed bool           `json:"committed"`
+	Rows      []ImportResult `json:"rows"`
+}
+
+// Import guarda los productos en una sola transaccion. Un producto cuyo
+// code_value ya existe se actualiza; si no, se crea. Un code_value repetido
+// dentro de la importacion se rechaza. Con atomic, si alguna fila se rechaza
+// no se guarda ninguna y se devuelve ErrImportRejected junto con el reporte.
+func (s *service) Import(rows []ImportRow, atomic bool) (ImportReport, error) {
+	report := ImportReport{Rows: make([]ImportResult, 0, len(rows))}
+	err := s.r.Transaction(func(tx store.Tx) error {
+		seen := map[string]int{}
+		for _, row := range rows {
+			result, err := s.importRow(tx, row, seen)
+			if err != nil {
+				return err
+			}
+			switch result.Status {
+			case ImportCreated:
+				report.Created++
+			case ImportUpdated:
+				report.Updated++
+			default:
+				report.Rejected++
+			}
+			report.Rows = append(report.Rows, result)
+		}
+		if atomic && report.Rejected > 0 {
+			return ErrImportRejected
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Committed = true
+	return report, nil
+}
+
+// importRow crea o actualiza el producto de una fila y lo registra en su
+// historial. Solo devuelve error si falla el historial, que cancela la
+// importacion.
+func (s *service) importRow(tx store.Tx, row ImportRow, seen map[string]int) (ImportResult, error) {
+	p := row.Product
+	p.Currency = p.Currency.OrBase()
+	result := ImportResult{Line: row.Line, CodeValue: p.CodeValue, Status: ImportRejected}
+	if row.Err != nil {
+		result.Reason = row.Err.Error()
+		return result, nil
+	}
+	if line, ok := seen[p.CodeValue]; ok {
+		result.Reason = fmt.Sprintf("code value already imported at line %d", line)
+		return result, nil
+	}
+	seen[p.CodeValue] = row.Line
+	existing, err := tx.GetByCodeValue(p.CodeValue)
+	if err != nil {
+		created, err := tx.Create(p)
+		if err != nil {
+			result.Reason = err.Error()
+			return result, nil
+		}
+		result.Status, result.ID = ImportCreated, created.Id
+		return result, s.record(tx, ChangeImport, nil, created)
+	}
+	// si la fila usa un codigo anterior a un renombre se conserva el actual
+	p.Id = existing.Id
+	p.CodeValue = existing.CodeValue
+	p.Version = 0
+	if err := s.checkReserved(existing, p); err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	if err := tx.Update(p); err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	updated, err := tx.GetByID(p.Id)
+	if err != nil {
+		return result, err
+	}
+	result.Status, result.ID = ImportUpdated, existing.Id
+	return result, s.record(tx, ChangeImport, &existing, updated)
+}
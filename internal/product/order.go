@@ -0,0 +1,120 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// ErrEmptyOrder indica un pedido sin lineas
+var ErrEmptyOrder = errors.New("order has no lines")
+
+// ErrOrderRejected indica un pedido con lineas que no se pueden comprar
+var ErrOrderRejected = errors.New("order rejected, nothing was bought")
+
+// RejectedLine es una linea de un pedido que no se puede comprar y por que
+type RejectedLine struct {
+	Line      int    `json:"line"`
+	CodeValue string `json:"code_value"`
+	Reason    string `json:"reason"`
+}
+
+// OrderRejection es el error de un pedido rechazado: tiene todas las lineas
+// con problemas, no solo la primera
+type OrderRejection struct {
+	Lines []RejectedLine `json:"lines"`
+}
+
+func (e *OrderRejection) Error() string {
+	reasons := make([]string, len(e.Lines))
+	for i, line := range e.Lines {
+		reasons[i] = fmt.Sprintf("line %d (%s): %s", line.Line, line.CodeValue, line.Reason)
+	}
+	return ErrOrderRejected.Error() + ": " + strings.Join(reasons, "; ")
+}
+
+// Is hace que errors.Is(err, ErrOrderRejected) reconozca el rechazo
+func (e *OrderRejection) Is(target error) bool {
+	return target == ErrOrderRejected
+}
+
+// BuyMany compra todas las lineas en una sola transaccion. Primero revisa
+// todas: si alguna no existe, esta vencida o no tiene stock sin reservar
+// (contando las otras lineas del mismo producto) devuelve un *OrderRejection y no compra
+// ninguna ni registra ninguna compra. Cada linea se valua en currency
+// convirtiendo su total exacto una sola vez; el total del pedido es la suma
+// de las lineas ya convertidas.
+func (s *service) BuyMany(items []domain.PurchaseItem, currency domain.Currency) (domain.Order, error) {
+	if len(items) == 0 {
+		return domain.Order{}, ErrEmptyOrder
+	}
+	order := domain.Order{Lines: make([]domain.Purchase, len(items)), Currency: currency.OrBase()}
+	var sales []domain.Purchase
+	err := s.r.Transaction(func(tx store.Tx) error {
+		rejection := &OrderRejection{}
+		requested := map[int]int{}
+		for i, item := range items {
+			p, reason := s.checkLine(tx, item, requested)
+			if reason != "" {
+				rejection.Lines = append(rejection.Lines, RejectedLine{Line: i + 1, CodeValue: item.CodeValue, Reason: reason})
+				continue
+			}
+			var err error
+			if order.Lines[i], err = s.quote(item.CodeValue, p, item.Quantity, order.Currency); err != nil {
+				return err
+			}
+		}
+		if len(rejection.Lines) > 0 {
+			return rejection
+		}
+		for i, item := range items {
+			// se vuelve a leer porque otra linea del mismo producto pudo
+			// cambiarlo
+			p, err := tx.GetByCodeValue(item.CodeValue)
+			if err != nil {
+				return fmt.Errorf("%s: %w", item.CodeValue, err)
+			}
+			if err := s.buy(tx, p, item.Quantity); err != nil {
+				return fmt.Errorf("%s: %w", item.CodeValue, err)
+			}
+			sale, err := s.sell(item.CodeValue, p, item.Quantity)
+			if err != nil {
+				return err
+			}
+			sales = append(sales, sale)
+			line := &order.Lines[i]
+			line.Id, line.ProductID, line.At, line.Actor = sale.Id, sale.ProductID, sale.At, sale.Actor
+			order.Total += line.TotalPrice
+		}
+		return nil
+	})
+	if err != nil {
+		s.discard(sales)
+		return domain.Order{}, err
+	}
+	return order, nil
+}
+
+// checkLine busca el producto de una linea y devuelve por que no se puede
+// comprar, o "" si se puede. requested lleva lo que ya piden las lineas
+// anteriores de cada producto.
+func (s *service) checkLine(tx store.Tx, item domain.PurchaseItem, requested map[int]int) (domain.Product, string) {
+	if item.Quantity <= 0 {
+		return domain.Product{}, "quantity must be greater than 0"
+	}
+	p, err := tx.GetByCodeValue(item.CodeValue)
+	if err != nil {
+		return domain.Product{}, "product not found"
+	}
+	if err := s.checkExpired(p); err != nil {
+		return domain.Product{}, ErrExpired.Error()
+	}
+	if err := s.checkStock(p, item.Quantity, requested[p.Id]); err != nil {
+		return domain.Product{}, err.Error()
+	}
+	requested[p.Id] += item.Quantity
+	return p, ""
+}
@@ -0,0 +1,52 @@
+package product
+
+import (
+	"log"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// PurchaseLog guarda las compras hechas
+type PurchaseLog interface {
+	Record(p domain.Purchase) (domain.Purchase, error)
+	Discard(id int) error
+}
+
+// WithPurchases hace que cada compra quede registrada en purchases. Si no se
+// puede registrar, la compra no se hace.
+func WithPurchases(purchases PurchaseLog) Option {
+	return func(s *service) {
+		s.purchases = purchases
+	}
+}
+
+// noPurchases es el registro por defecto: no guarda nada
+type noPurchases struct{}
+
+func (noPurchases) Record(p domain.Purchase) (domain.Purchase, error) { return p, nil }
+func (noPurchases) Discard(id int) error                              { return nil }
+
+// sell registra la venta de quantity unidades de p, al precio de ese momento
+// y en la moneda del producto
+func (s *service) sell(code string, p domain.Product, quantity int) (domain.Purchase, error) {
+	sale, err := s.quote(code, p, quantity, "")
+	if err != nil {
+		return domain.Purchase{}, err
+	}
+	sale.ProductID = p.Id
+	sale.At = s.now().UTC()
+	sale.Actor = s.actor
+	return s.purchases.Record(sale)
+}
+
+// discard borra las compras registradas de una operacion que no se confirmo
+func (s *service) discard(sales []domain.Purchase) {
+	for _, sale := range sales {
+		if sale.Id == 0 {
+			continue
+		}
+		if err := s.purchases.Discard(sale.Id); err != nil {
+			log.Printf("discarding purchase %d of a failed buy: %v", sale.Id, err)
+		}
+	}
+}
@@ -9,21 +9,25 @@ import (
 
 type Repository interface {
 	GetAll() []domain.Product
+	List(q store.Query) (store.Page[domain.Product], error)
 	GetByID(id int) (domain.Product, error)
-	SearchPriceGt(price float64) []domain.Product
+	Search(criteria store.SearchCriteria) ([]domain.Product, error)
 	Create(p domain.Product) (domain.Product, error)
 	Update(p domain.Product) error
 	Delete(id int) error
+	Trash() ([]domain.Product, error)
 	GetByCodeValue(code string) (domain.Product, error)
 	Buy(code string, quantity int) error
+	History(productID, limit, offset int) (store.Page[domain.ProductChange], error)
+	Transaction(fn func(tx store.Tx) error) error
 }
 
 type repository struct {
-	storage store.Store
+	storage store.ProductStore
 }
 
 // NewRepository crea un nuevo repositorio
-func NewRepository(storage store.Store) Repository {
+func NewRepository(storage store.ProductStore) Repository {
 	return &repository{storage}
 }
 
@@ -36,6 +40,11 @@ func (r *repository) GetAll() []domain.Product {
 	return products
 }
 
+// List devuelve una pagina de productos filtrada y ordenada por el store
+func (r *repository) List(q store.Query) (store.Page[domain.Product], error) {
+	return r.storage.List(q)
+}
+
 // GetByID busca un producto por su id
 func (r *repository) GetByID(id int) (domain.Product, error) {
 	product, err := r.storage.GetByID(id)
@@ -54,18 +63,17 @@ func (r *repository) GetByCodeValue(code string) (domain.Product, error) {
 	return product, nil
 }
 
-// SearchPriceGt busca productos por precio mayor o igual que el precio dado
-func (r *repository) SearchPriceGt(price float64) []domain.Product {
-	products := r.storage.SearchPriceGt(price)
-	return products
+// Search busca productos que cumplan todos los criterios
+func (r *repository) Search(criteria store.SearchCriteria) ([]domain.Product, error) {
+	return r.storage.Search(criteria)
 }
 
 // Create agrega un nuevo producto
 func (r *repository) Create(p domain.Product) (domain.Product, error) {
-	if !r.validateCodeValue(p.CodeValue) {
-		return domain.Product{}, errors.New("code value already exists")
+	p, err := r.storage.Create(p)
+	if errors.Is(err, store.ErrDuplicateCodeValue) {
+		return domain.Product{}, err
 	}
-	err := r.storage.Create(p)
 	if err != nil {
 		return domain.Product{}, errors.New("error creating product")
 	}
@@ -74,28 +82,14 @@ func (r *repository) Create(p domain.Product) (domain.Product, error) {
 
 // Actualizar un producto
 func (r *repository) Update(p domain.Product) error {
-	if !r.validateCodeValue(p.CodeValue) {
-		errors.New("code value already exists")
-	}
 	err := r.storage.Update(p)
-	if err != nil {
-		errors.New("error updating product")
+	if errors.Is(err, store.ErrDuplicateCodeValue) {
+		return err
 	}
-	return nil
-}
-
-// validateCodeValue valida que el codigo no exista en la lista de productos
-func (r *repository) validateCodeValue(codeValue string) bool {
-	list, err := r.storage.GetAll()
 	if err != nil {
-		return false
-	}
-	for _, product := range list {
-		if product.CodeValue == codeValue {
-			return false
-		}
+		return errors.New("error updating product")
 	}
-	return true
+	return nil
 }
 
 // Delete elimina un producto
@@ -107,6 +101,11 @@ func (r *repository) Delete(id int) error {
 	return nil
 }
 
+// Trash devuelve los productos borrados, el mas reciente primero
+func (r *repository) Trash() ([]domain.Product, error) {
+	return r.storage.Trash()
+}
+
 // Setea la cantidad de prodcuto según la compra
 func (r *repository) Buy(code string, quantity int) error {
 	err := r.storage.Buy(code, quantity)
@@ -115,3 +114,22 @@ func (r *repository) Buy(code string, quantity int) error {
 	}
 	return nil
 }
+
+// History devuelve una pagina del historial de un producto
+func (r *repository) History(productID, limit, offset int) (store.Page[domain.ProductChange], error) {
+	return r.storage.History(productID, limit, offset)
+}
+
+// Transaction ejecuta fn en una transaccion del store: si fn devuelve error
+// se descartan todos sus cambios, si no se confirman juntos
+func (r *repository) Transaction(fn func(tx store.Tx) error) error {
+	tx, err := r.storage.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
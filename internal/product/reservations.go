@@ -0,0 +1,308 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// ErrInsufficientStock indica que no hay stock disponible para una compra o
+// una reserva; lo reservado por otros no esta disponible
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrReservationClosed indica una reserva ya confirmada, cancelada o liberada
+var ErrReservationClosed = errors.New("reservation is not active")
+
+// ErrReservationExpired indica una reserva que vencio antes de confirmarse
+var ErrReservationExpired = errors.New("reservation expired")
+
+// ErrReservedStock indica un cambio que deja el stock de un producto por
+// debajo de lo reservado
+var ErrReservedStock = errors.New("quantity is below the reserved stock")
+
+// ErrInvalidTTL indica un plazo de reserva no positivo o mayor al maximo
+var ErrInvalidTTL = errors.New("invalid reservation ttl")
+
+// ReservationLog guarda las reservas de stock
+type ReservationLog interface {
+	GetByID(id int) (domain.Reservation, error)
+	Active() ([]domain.Reservation, error)
+	// ActiveFor devuelve las reservas activas de un producto sin leer todas
+	ActiveFor(productID int) ([]domain.Reservation, error)
+	Create(r domain.Reservation) (domain.Reservation, error)
+	Update(r domain.Reservation) error
+	UpdateAll(reservations []domain.Reservation) error
+}
+
+// WithReservations habilita las reservas de stock, guardadas en log. Una
+// reserva sin plazo dura ttl y ninguna puede durar mas de maxTTL.
+func WithReservations(log ReservationLog, ttl, maxTTL time.Duration) Option {
+	return func(s *service) {
+		s.reservations = log
+		s.reservationTTL = ttl
+		s.maxReservationTTL = maxTTL
+	}
+}
+
+// noReservations es el registro por defecto: no hay reservas
+type noReservations struct{}
+
+func (noReservations) GetByID(id int) (domain.Reservation, error) {
+	return domain.Reservation{}, errors.New("reservations are disabled")
+}
+
+func (noReservations) Active() ([]domain.Reservation, error) { return nil, nil }
+
+func (noReservations) ActiveFor(productID int) ([]domain.Reservation, error) { return nil, nil }
+
+func (noReservations) Create(r domain.Reservation) (domain.Reservation, error) {
+	return domain.Reservation{}, errors.New("reservations are disabled")
+}
+
+func (noReservations) Update(r domain.Reservation) error {
+	return errors.New("reservations are disabled")
+}
+
+func (noReservations) UpdateAll(reservations []domain.Reservation) error {
+	return errors.New("reservations are disabled")
+}
+
+// Reserved devuelve cuantas unidades del producto retienen las reservas
+// vigentes
+func (s *service) Reserved(productID int) (int, error) {
+	active, err := s.reservations.ActiveFor(productID)
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	reserved := 0
+	for _, r := range active {
+		if r.Holds(now) {
+			reserved += r.Quantity
+		}
+	}
+	return reserved, nil
+}
+
+// checkStock falla con ErrInsufficientStock si de p no quedan quantity
+// unidades sin reservar, ademas de las requested que ya se pidieron en la
+// misma operacion
+func (s *service) checkStock(p domain.Product, quantity, requested int) error {
+	reserved, err := s.Reserved(p.Id)
+	if err != nil {
+		return err
+	}
+	available := p.Quantity - reserved - requested
+	if quantity > available {
+		if available < 0 {
+			available = 0
+		}
+		return fmt.Errorf("%w for %s: %d available, %d reserved", ErrInsufficientStock, p.CodeValue, available, reserved)
+	}
+	return nil
+}
+
+// checkReserved falla con ErrReservedStock si after baja el stock de before
+// por debajo de lo reservado, porque despues no se podrian confirmar las
+// reservas
+func (s *service) checkReserved(before, after domain.Product) error {
+	if after.Quantity >= before.Quantity {
+		return nil
+	}
+	reserved, err := s.Reserved(before.Id)
+	if err != nil {
+		return err
+	}
+	if after.Quantity < reserved {
+		return fmt.Errorf("%w: %s has %d units reserved", ErrReservedStock, before.CodeValue, reserved)
+	}
+	return nil
+}
+
+// Reserve retiene quantity unidades de un producto durante ttl (0 es el plazo
+// por defecto). Solo se reserva lo que no esta reservado por otros.
+func (s *service) Reserve(code string, quantity int, ttl time.Duration) (domain.Reservation, error) {
+	if quantity <= 0 {
+		return domain.Reservation{}, errors.New("quantity must be greater than 0")
+	}
+	if ttl == 0 {
+		ttl = s.reservationTTL
+	}
+	if ttl <= 0 || ttl > s.maxReservationTTL {
+		return domain.Reservation{}, fmt.Errorf("%w, must be between 1s and %s", ErrInvalidTTL, s.maxReservationTTL)
+	}
+	var reservation domain.Reservation
+	// las reservas cambian dentro de una transaccion de productos, asi no
+	// se cruzan con una compra que mira el stock disponible
+	err := s.r.Transaction(func(tx store.Tx) error {
+		p, err := tx.GetByCodeValue(code)
+		if err != nil {
+			return err
+		}
+		if err := s.checkExpired(p); err != nil {
+			return err
+		}
+		if err := s.checkStock(p, quantity, 0); err != nil {
+			return err
+		}
+		now := s.now().UTC()
+		reservation, err = s.reservations.Create(domain.Reservation{
+			ProductID: p.Id,
+			CodeValue: p.CodeValue,
+			Quantity:  quantity,
+			Status:    domain.ReservationActive,
+			CreatedAt: now,
+			ExpiresAt: now.Add(ttl),
+			Actor:     s.actor,
+		})
+		return err
+	})
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	return reservation, nil
+}
+
+// GetReservation busca una reserva por su id
+func (s *service) GetReservation(id int) (domain.Reservation, error) {
+	return s.reservations.GetByID(id)
+}
+
+// ConfirmReservation compra lo reservado y cierra la reserva. La compra se
+// valua en currency, vacia es la moneda del producto. Si la transaccion de
+// productos no se confirma se borra la compra y la reserva vuelve a quedar
+// activa.
+func (s *service) ConfirmReservation(id int, currency domain.Currency) (domain.Purchase, error) {
+	var sale, purchase domain.Purchase
+	var closed *domain.Reservation
+	err := s.r.Transaction(func(tx store.Tx) error {
+		reservation, err := s.activeReservation(id)
+		if err != nil {
+			return err
+		}
+		p, err := tx.GetByID(reservation.ProductID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkExpired(p); err != nil {
+			return err
+		}
+		if purchase, err = s.quote(reservation.CodeValue, p, reservation.Quantity, currency); err != nil {
+			return err
+		}
+		// lo reservado ya esta descontado del disponible, no hace falta
+		// volver a revisar las reservas
+		if err := s.buy(tx, p, reservation.Quantity); err != nil {
+			return err
+		}
+		if sale, err = s.sell(reservation.CodeValue, p, reservation.Quantity); err != nil {
+			return err
+		}
+		active := reservation
+		reservation.PurchaseID = sale.Id
+		if err := s.closeReservation(reservation, domain.ReservationConfirmed); err != nil {
+			return err
+		}
+		closed = &active
+		return nil
+	})
+	if err != nil {
+		s.discard([]domain.Purchase{sale})
+		s.reopen(closed)
+		return domain.Purchase{}, err
+	}
+	purchase.Id, purchase.ProductID, purchase.At, purchase.Actor = sale.Id, sale.ProductID, sale.At, sale.Actor
+	return purchase, nil
+}
+
+// CancelReservation libera lo reservado
+func (s *service) CancelReservation(id int) (domain.Reservation, error) {
+	var reservation domain.Reservation
+	err := s.r.Transaction(func(tx store.Tx) error {
+		var err error
+		if reservation, err = s.activeReservation(id); err != nil {
+			return err
+		}
+		return s.closeReservation(reservation, domain.ReservationCancelled)
+	})
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	return s.reservations.GetByID(id)
+}
+
+// ExpireReservations libera las reservas vencidas, guardandolas juntas, y
+// devuelve cuantas libero. Una reserva vencida ya no retiene stock aunque no
+// se haya liberado; esto solo deja registrado que vencio.
+func (s *service) ExpireReservations() (int, error) {
+	var expired []domain.Reservation
+	err := s.r.Transaction(func(tx store.Tx) error {
+		active, err := s.reservations.Active()
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		closedAt := now.UTC()
+		for _, reservation := range active {
+			if reservation.Holds(now) {
+				continue
+			}
+			reservation.Status = domain.ReservationExpired
+			reservation.ClosedAt = &closedAt
+			expired = append(expired, reservation)
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+		return s.reservations.UpdateAll(expired)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+// activeReservation devuelve una reserva que todavia retiene stock. Si vencio
+// la libera y falla con ErrReservationExpired.
+func (s *service) activeReservation(id int) (domain.Reservation, error) {
+	reservation, err := s.reservations.GetByID(id)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	if reservation.Status == domain.ReservationExpired {
+		return domain.Reservation{}, ErrReservationExpired
+	}
+	if reservation.Status != domain.ReservationActive {
+		return domain.Reservation{}, fmt.Errorf("%w, it is %s", ErrReservationClosed, reservation.Status)
+	}
+	if !reservation.Holds(s.now()) {
+		if err := s.closeReservation(reservation, domain.ReservationExpired); err != nil {
+			return domain.Reservation{}, err
+		}
+		return domain.Reservation{}, ErrReservationExpired
+	}
+	return reservation, nil
+}
+
+// reopen devuelve a activa una reserva que se cerro en una operacion que no
+// se confirmo; nil no hace nada
+func (s *service) reopen(reservation *domain.Reservation) {
+	if reservation == nil {
+		return
+	}
+	if err := s.reservations.Update(*reservation); err != nil {
+		log.Printf("reopening reservation %d of a failed confirm: %v", reservation.Id, err)
+	}
+}
+
+// closeReservation deja la reserva en status
+func (s *service) closeReservation(reservation domain.Reservation, status string) error {
+	now := s.now().UTC()
+	reservation.Status = status
+	reservation.ClosedAt = &now
+	return s.reservations.Update(reservation)
+}
@@ -0,0 +1,198 @@
+package product
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/internal/purchase"
+	"github.com/mceciabate/web-server/internal/reservation"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+var errCommit = errors.New("journal append failed")
+
+// failingCommits es un repositorio cuyas transacciones se descartan despues
+// de correr fn mientras fail es true, como si fallara la escritura del journal
+type failingCommits struct {
+	Repository
+	fail bool
+}
+
+func (r *failingCommits) Transaction(fn func(tx store.Tx) error) error {
+	return r.Repository.Transaction(func(tx store.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if r.fail {
+			return errCommit
+		}
+		return nil
+	})
+}
+
+// reservationFixture arma un servicio con reservas y compras guardadas en
+// archivos temporales y un producto A1 con 10 unidades
+type reservationFixture struct {
+	s            *service
+	repo         *failingCommits
+	purchases    purchase.Service
+	reservations reservation.Service
+}
+
+func newReservationFixture(t *testing.T) reservationFixture {
+	t.Helper()
+	dir := t.TempDir()
+	products := []domain.Product{
+		{Id: 1, Name: "Oil", Quantity: 10, CodeValue: "A1", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 1), Price: 1000, Version: 1},
+	}
+	data, err := json.Marshal(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "products.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	storage, err := store.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purchases := purchase.NewService(purchase.NewRepository(store.NewFileStore[domain.Purchase](
+		filepath.Join(dir, "purchases.json"), store.JSONCodec[domain.Purchase]{},
+		func(p *domain.Purchase) *int { return &p.Id },
+	)))
+	reservations := reservation.NewService(reservation.NewRepository(store.NewFileStore[domain.Reservation](
+		filepath.Join(dir, "reservations.json"), store.JSONCodec[domain.Reservation]{},
+		func(r *domain.Reservation) *int { return &r.Id },
+	)))
+	repo := &failingCommits{Repository: NewRepository(storage)}
+	s := NewService(repo, WithPurchases(purchases), WithReservations(reservations, time.Minute, time.Hour)).(*service)
+	return reservationFixture{s: s, repo: repo, purchases: purchases, reservations: reservations}
+}
+
+func TestConfirmReservationFailedCommitReopensReservation(t *testing.T) {
+	f := newReservationFixture(t)
+	r, err := f.s.Reserve("A1", 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.repo.fail = true
+	if _, err := f.s.ConfirmReservation(r.Id, ""); !errors.Is(err, errCommit) {
+		t.Fatalf("confirm: got %v, want the commit error", err)
+	}
+	got, err := f.reservations.GetByID(r.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.ReservationActive || got.PurchaseID != 0 || got.ClosedAt != nil {
+		t.Fatalf("reservation after a failed confirm: %+v, want it active again", got)
+	}
+	page, err := f.purchases.List(purchase.Filter{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 {
+		t.Fatalf("failed confirm left %d purchases", page.Total)
+	}
+
+	f.repo.fail = false
+	sale, err := f.s.ConfirmReservation(r.Id, "")
+	if err != nil {
+		t.Fatalf("retrying the confirm: %v", err)
+	}
+	p, err := f.s.GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Quantity != 6 {
+		t.Fatalf("quantity %d after confirming 4 of 10", p.Quantity)
+	}
+	if got, _ := f.reservations.GetByID(r.Id); got.Status != domain.ReservationConfirmed || got.PurchaseID != sale.Id {
+		t.Fatalf("reservation after confirm: %+v", got)
+	}
+}
+
+func TestUpdateBelowReservedIsRejected(t *testing.T) {
+	f := newReservationFixture(t)
+	if _, err := f.s.Reserve("A1", 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	current, err := f.s.GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lowered := current
+	lowered.Quantity = 3
+	if _, err := f.s.Update(1, lowered); !errors.Is(err, ErrReservedStock) {
+		t.Fatalf("update to 3 with 4 reserved: got %v, want ErrReservedStock", err)
+	}
+	_, err = f.s.Patch(1, 0, func(p domain.Product) (domain.Product, error) {
+		p.Quantity = 0
+		return p, nil
+	})
+	if !errors.Is(err, ErrReservedStock) {
+		t.Fatalf("patch to 0 with 4 reserved: got %v, want ErrReservedStock", err)
+	}
+	patched, err := f.s.Patch(1, 0, func(p domain.Product) (domain.Product, error) {
+		p.Quantity = 4
+		return p, nil
+	})
+	if err != nil {
+		t.Fatalf("patch down to the reserved amount: %v", err)
+	}
+	if patched.Quantity != 4 {
+		t.Fatalf("quantity %d, want 4", patched.Quantity)
+	}
+}
+
+func TestExpireReservationsReleasesHeldStock(t *testing.T) {
+	f := newReservationFixture(t)
+	start := time.Now()
+	f.s.now = func() time.Time { return start }
+	first, err := f.s.Reserve("A1", 3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := f.s.Reserve("A1", 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := f.s.Reserve("A1", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reserved, _ := f.s.Reserved(1); reserved != 6 {
+		t.Fatalf("reserved %d, want 6", reserved)
+	}
+
+	f.s.now = func() time.Time { return start.Add(2 * time.Minute) }
+	expired, err := f.s.ExpireReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 2 {
+		t.Fatalf("expired %d reservations, want 2", expired)
+	}
+	for id, status := range map[int]string{first.Id: domain.ReservationExpired, second.Id: domain.ReservationExpired, kept.Id: domain.ReservationActive} {
+		r, err := f.reservations.GetByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != status {
+			t.Errorf("reservation %d: status %s, want %s", id, r.Status, status)
+		}
+	}
+	if reserved, _ := f.s.Reserved(1); reserved != 1 {
+		t.Fatalf("reserved %d after expiring, want 1", reserved)
+	}
+	if _, err := f.s.Buy("A1", 9, 0, ""); err != nil {
+		t.Fatalf("buying the released stock: %v", err)
+	}
+}
@@ -2,28 +2,101 @@ package product
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
 )
 
 type Service interface {
 	GetAll() ([]domain.Product, error)
+	List(q store.Query) (store.Page[domain.Product], error)
 	GetByID(id int) (domain.Product, error)
-	SearchPriceGt(price float64) ([]domain.Product, error)
+	Search(criteria store.SearchCriteria) ([]domain.Product, error)
+	Expiring(withinDays int, includeExpired bool) ([]domain.Product, error)
 	Create(p domain.Product) (domain.Product, error)
 	Update(id int, p domain.Product) (domain.Product, error)
-	Delete(id int) error
-	Buy(code string, quantity int) error
+	Patch(id int, version int, patch PatchFunc) (domain.Product, error)
+	Rename(id int, code string, version int) (domain.Product, error)
+	Delete(id int, version int) error
+	Trash() ([]domain.Product, error)
+	Restore(id int) (domain.Product, error)
+	// Buy compra y devuelve la compra valuada en currency; vacia es la
+	// moneda del producto
+	Buy(code string, quantity int, version int, currency domain.Currency) (domain.Purchase, error)
 	GetByCodeValue(code string) (domain.Product, error)
+	// BuyMany compra todas las lineas de un pedido o ninguna y devuelve el
+	// pedido valuado en currency; vacia es BaseCurrency
+	BuyMany(items []domain.PurchaseItem, currency domain.Currency) (domain.Order, error)
+	// InCurrency devuelve el producto con el precio convertido a currency
+	InCurrency(p domain.Product, currency domain.Currency) (domain.Product, error)
+	MoveStock(fromCode, toCode string, quantity int) error
+	Import(rows []ImportRow, atomic bool) (ImportReport, error)
+	History(id, limit, offset int) (store.Page[domain.ProductChange], error)
+	// Reserved devuelve cuantas unidades del producto estan reservadas
+	Reserved(productID int) (int, error)
+	Reserve(code string, quantity int, ttl time.Duration) (domain.Reservation, error)
+	GetReservation(id int) (domain.Reservation, error)
+	ConfirmReservation(id int, currency domain.Currency) (domain.Purchase, error)
+	CancelReservation(id int) (domain.Reservation, error)
+	ExpireReservations() (int, error)
+	// As devuelve el servicio registrando los cambios en el historial a
+	// nombre de actor
+	As(actor string) Service
 }
 
+// PatchFunc recibe el producto guardado y devuelve como debe quedar
+type PatchFunc func(current domain.Product) (domain.Product, error)
+
+// ErrExpired indica que se intento comprar un producto vencido
+var ErrExpired = errors.New("product is expired")
+
 type service struct {
-	r Repository
+	r             Repository
+	refuseExpired bool
+	now           func() time.Time
+	actor         string
+	converter     Converter
+	purchases     PurchaseLog
+	// reservations y los plazos de reserva los configura WithReservations
+	reservations      ReservationLog
+	reservationTTL    time.Duration
+	maxReservationTTL time.Duration
+}
+
+// Option configura el servicio
+type Option func(*service)
+
+// RefuseExpired hace que las compras de productos vencidos fallen con ErrExpired
+func RefuseExpired(refuse bool) Option {
+	return func(s *service) {
+		s.refuseExpired = refuse
+	}
 }
 
 // NewService crea un nuevo servicio
-func NewService(r Repository) Service {
-	return &service{r}
+func NewService(r Repository, opts ...Option) Service {
+	s := &service{r: r, now: time.Now, actor: SystemActor, converter: sameCurrency{}, purchases: noPurchases{}, reservations: noReservations{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// today devuelve la fecha de hoy
+func (s *service) today() domain.Date {
+	return domain.DateOf(s.now())
+}
+
+// checkExpired falla si el producto esta vencido y no se pueden comprar
+// productos vencidos. Un producto vence al terminar el dia de su expiracion.
+func (s *service) checkExpired(p domain.Product) error {
+	if s.refuseExpired && p.Expiration.Before(s.today()) {
+		return fmt.Errorf("%w: %s expired on %s", ErrExpired, p.CodeValue, p.Expiration)
+	}
+	return nil
 }
 
 // GetAll devuelve todos los productos
@@ -32,6 +105,11 @@ func (s *service) GetAll() ([]domain.Product, error) {
 	return l, nil
 }
 
+// List devuelve una pagina de productos filtrada y ordenada
+func (s *service) List(q store.Query) (store.Page[domain.Product], error) {
+	return s.r.List(q)
+}
+
 // GetByID busca un producto por su id
 func (s *service) GetByID(id int) (domain.Product, error) {
 	p, err := s.r.GetByID(id)
@@ -41,55 +119,249 @@ func (s *service) GetByID(id int) (domain.Product, error) {
 	return p, nil
 }
 
-// SearchPriceGt busca productos por precio mayor que el precio dado
-func (s *service) SearchPriceGt(price float64) ([]domain.Product, error) {
-	l := s.r.SearchPriceGt(price)
+// Search busca productos que cumplan todos los criterios
+func (s *service) Search(criteria store.SearchCriteria) ([]domain.Product, error) {
+	l, err := s.r.Search(criteria)
+	if err != nil {
+		return []domain.Product{}, err
+	}
 	if len(l) == 0 {
 		return []domain.Product{}, errors.New("no products found")
 	}
 	return l, nil
 }
 
+// Expiring devuelve los productos que vencen entre hoy y dentro de
+// withinDays dias, ordenados por fecha de expiracion. Con includeExpired
+// tambien devuelve los que ya vencieron.
+func (s *service) Expiring(withinDays int, includeExpired bool) ([]domain.Product, error) {
+	today := s.today()
+	before := today.AddDays(withinDays + 1)
+	criteria := store.SearchCriteria{ExpiresBefore: &before}
+	if !includeExpired {
+		after := today.AddDays(-1)
+		criteria.ExpiresAfter = &after
+	}
+	l, err := s.r.Search(criteria)
+	if err != nil {
+		return []domain.Product{}, err
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].Expiration.Before(l[j].Expiration)
+	})
+	return l, nil
+}
+
 // Create agrega un nuevo producto
 func (s *service) Create(p domain.Product) (domain.Product, error) {
-	p, err := s.r.Create(p)
+	p.Currency = p.Currency.OrBase()
+	err := s.r.Transaction(func(tx store.Tx) error {
+		created, err := tx.Create(p)
+		if errors.Is(err, store.ErrDuplicateCodeValue) {
+			return err
+		}
+		if err != nil {
+			return errors.New("error creating product")
+		}
+		p = created
+		return s.record(tx, ChangeCreate, nil, p)
+	})
 	if err != nil {
 		return domain.Product{}, err
 	}
 	return p, nil
 }
 
+// Update reemplaza un producto. Si p.Version no es 0 solo lo reemplaza si
+// sigue en esa version. Devuelve el producto guardado, con su nueva version.
 func (s *service) Update(id int, p domain.Product) (domain.Product, error) {
-	_, err := s.r.GetByID(id)
+	p.Id = id
+	p.Currency = p.Currency.OrBase()
+	err := s.r.Transaction(func(tx store.Tx) error {
+		before, err := tx.GetByID(id)
+		if err != nil {
+			return err
+		}
+		if err := s.checkReserved(before, p); err != nil {
+			return err
+		}
+		if err := tx.Update(p); err != nil {
+			return err
+		}
+		if p, err = tx.GetByID(id); err != nil {
+			return err
+		}
+		return s.record(tx, ChangeUpdate, &before, p)
+	})
 	if err != nil {
 		return domain.Product{}, err
 	}
+	return p, nil
+}
 
-	p.Id = id
-	err = s.r.Update(p)
+// Patch aplica patch sobre el producto guardado dentro de una transaccion, de
+// modo que no se pierden cambios hechos entre la lectura y la escritura. Si
+// version no es 0 el producto tiene que estar en esa version.
+func (s *service) Patch(id int, version int, patch PatchFunc) (domain.Product, error) {
+	var patched domain.Product
+	err := s.r.Transaction(func(tx store.Tx) error {
+		current, err := tx.GetByID(id)
+		if err != nil {
+			return err
+		}
+		if err := store.CheckVersion(current, version); err != nil {
+			return err
+		}
+		if patched, err = patch(current); err != nil {
+			return err
+		}
+		patched.Id = id
+		patched.Version = current.Version
+		patched.Currency = patched.Currency.OrBase()
+		if err := s.checkReserved(current, patched); err != nil {
+			return err
+		}
+		if err := tx.Update(patched); err != nil {
+			return err
+		}
+		if patched, err = tx.GetByID(id); err != nil {
+			return err
+		}
+		return s.record(tx, ChangePatch, &current, patched)
+	})
 	if err != nil {
 		return domain.Product{}, err
 	}
+	return patched, nil
+}
 
-	return p, nil
+// Rename cambia el code_value de un producto. El anterior queda como alias,
+// asi las busquedas y compras por ese codigo siguen encontrando el producto.
+// Si version no es 0 el producto tiene que estar en esa version.
+func (s *service) Rename(id int, code string, version int) (domain.Product, error) {
+	var renamed domain.Product
+	err := s.r.Transaction(func(tx store.Tx) error {
+		current, err := tx.GetByID(id)
+		if err != nil {
+			return err
+		}
+		if err := store.CheckVersion(current, version); err != nil {
+			return err
+		}
+		if code == current.CodeValue {
+			renamed = current
+			return nil
+		}
+		if err := tx.Rename(id, code); err != nil {
+			return err
+		}
+		if renamed, err = tx.GetByID(id); err != nil {
+			return err
+		}
+		return s.record(tx, ChangeRename, &current, renamed)
+	})
+	if err != nil {
+		return domain.Product{}, err
+	}
+	return renamed, nil
+}
+
+// Delete manda un producto a la papelera. Si version no es 0 solo lo elimina
+// si sigue en esa version.
+func (s *service) Delete(id int, version int) error {
+	return s.r.Transaction(func(tx store.Tx) error {
+		current, err := tx.GetByID(id)
+		if err != nil {
+			return err
+		}
+		if err := store.CheckVersion(current, version); err != nil {
+			return err
+		}
+		if err := tx.Delete(id); err != nil {
+			return err
+		}
+		deleted, err := tx.GetDeleted(id)
+		if err != nil {
+			return err
+		}
+		return s.record(tx, ChangeDelete, &current, deleted)
+	})
 }
 
-// Delete elimina un producto
-func (s *service) Delete(id int) error {
-	err := s.r.Delete(id)
+// Trash devuelve los productos borrados, el mas reciente primero
+func (s *service) Trash() ([]domain.Product, error) {
+	return s.r.Trash()
+}
+
+// Restore devuelve un producto de la papelera al catalogo. Falla con
+// store.ErrDuplicateCodeValue si otro producto tomo su code_value.
+func (s *service) Restore(id int) (domain.Product, error) {
+	var restored domain.Product
+	err := s.r.Transaction(func(tx store.Tx) error {
+		deleted, err := tx.GetDeleted(id)
+		if err != nil {
+			return err
+		}
+		if restored, err = tx.Undelete(id); err != nil {
+			return err
+		}
+		return s.record(tx, ChangeRestore, &deleted, restored)
+	})
 	if err != nil {
+		return domain.Product{}, err
+	}
+	return restored, nil
+}
+
+// Buy compra un product y registra la compra. Si version no es 0 solo compra
+// si el producto sigue en esa version. Si no se puede valuar la compra en
+// currency no se compra.
+func (s *service) Buy(code string, quantity int, version int, currency domain.Currency) (domain.Purchase, error) {
+	if quantity <= 0 {
+		return domain.Purchase{}, errors.New("quantity must be greater than 0")
+	}
+	var sale, purchase domain.Purchase
+	err := s.r.Transaction(func(tx store.Tx) error {
+		current, err := tx.GetByCodeValue(code)
+		if err != nil {
+			return err
+		}
+		if err := store.CheckVersion(current, version); err != nil {
+			return err
+		}
+		if err := s.checkExpired(current); err != nil {
+			return err
+		}
+		if err := s.checkStock(current, quantity, 0); err != nil {
+			return err
+		}
+		if purchase, err = s.quote(code, current, quantity, currency); err != nil {
+			return err
+		}
+		if err := s.buy(tx, current, quantity); err != nil {
+			return err
+		}
+		sale, err = s.sell(code, current, quantity)
 		return err
+	})
+	if err != nil {
+		s.discard([]domain.Purchase{sale})
+		return domain.Purchase{}, err
 	}
-	return nil
+	purchase.Id, purchase.ProductID, purchase.At, purchase.Actor = sale.Id, sale.ProductID, sale.At, sale.Actor
+	return purchase, nil
 }
 
-// Buy compra un product
-func (s *service) Buy(code string, quantity int) error {
-	err := s.r.Buy(code, quantity)
+// buy descuenta la compra de p y la registra en su historial
+func (s *service) buy(tx store.Tx, p domain.Product, quantity int) error {
+	if err := tx.Buy(p.CodeValue, quantity); err != nil {
+		return err
+	}
+	after, err := tx.GetByID(p.Id)
 	if err != nil {
 		return err
 	}
-	return nil
+	return s.record(tx, ChangeBuy, &p, after)
 }
 
 // Devuelve un producto por code_value
@@ -100,3 +372,45 @@ func (s *service) GetByCodeValue(code string) (domain.Product, error) {
 	}
 	return p, nil
 }
+
+// MoveStock pasa quantity unidades de un producto a otro en una transaccion
+func (s *service) MoveStock(fromCode, toCode string, quantity int) error {
+	if quantity <= 0 {
+		return errors.New("quantity must be greater than 0")
+	}
+	return s.r.Transaction(func(tx store.Tx) error {
+		from, err := tx.GetByCodeValue(fromCode)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fromCode, err)
+		}
+		to, err := tx.GetByCodeValue(toCode)
+		if err != nil {
+			return fmt.Errorf("%s: %w", toCode, err)
+		}
+		// un alias resuelve al mismo producto aunque el code_value sea otro
+		if from.Id == to.Id {
+			return errors.New("source and destination must be different products")
+		}
+		if err := s.checkStock(from, quantity, 0); err != nil {
+			return err
+		}
+		if err := tx.Buy(fromCode, quantity); err != nil {
+			return fmt.Errorf("%s: %w", fromCode, err)
+		}
+		moved := to
+		moved.Quantity += quantity
+		if err := tx.Update(moved); err != nil {
+			return err
+		}
+		for _, before := range []domain.Product{from, to} {
+			after, err := tx.GetByID(before.Id)
+			if err != nil {
+				return err
+			}
+			if err := s.record(tx, ChangeTransfer, &before, after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package product
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/internal/purchase"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// productFixture arma un servicio de productos con las compras guardadas en
+// un archivo temporal y dos productos: A1 con 10 unidades y B2 con 5
+type productFixture struct {
+	s         *service
+	storage   store.ProductStore
+	purchases purchase.Service
+}
+
+func newProductFixture(t *testing.T, opts ...Option) productFixture {
+	t.Helper()
+	dir := t.TempDir()
+	products := []domain.Product{
+		{Id: 1, Name: "Oil", Quantity: 10, CodeValue: "A1", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 1), Price: 1000, Version: 1},
+		{Id: 2, Name: "Wine", Quantity: 5, CodeValue: "B2", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 1), Price: 500, Version: 1},
+	}
+	data, err := json.Marshal(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "products.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	storage, err := store.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purchases := purchase.NewService(purchase.NewRepository(store.NewFileStore[domain.Purchase](
+		filepath.Join(dir, "purchases.json"), store.JSONCodec[domain.Purchase]{},
+		func(p *domain.Purchase) *int { return &p.Id },
+	)))
+	opts = append([]Option{WithPurchases(purchases)}, opts...)
+	s := NewService(NewRepository(storage), opts...).(*service)
+	return productFixture{s: s, storage: storage, purchases: purchases}
+}
+
+func TestBuyRejectsNonPositiveQuantity(t *testing.T) {
+	f := newProductFixture(t)
+	for _, quantity := range []int{0, -3} {
+		if _, err := f.s.Buy("A1", quantity, 0, ""); err == nil {
+			t.Fatalf("buying %d units must fail", quantity)
+		}
+	}
+	p, err := f.s.GetByCodeValue("A1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Quantity != 10 || p.Version != 1 {
+		t.Fatalf("product after rejected buys: quantity %d version %d, want 10 and 1", p.Quantity, p.Version)
+	}
+	page, err := f.purchases.List(purchase.Filter{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 {
+		t.Fatalf("rejected buys recorded %d purchases", page.Total)
+	}
+}
+
+func TestMoveStockRejectsSameProductThroughAlias(t *testing.T) {
+	f := newProductFixture(t)
+	if _, err := f.s.Rename(1, "A1-NEW", 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, codes := range [][2]string{{"A1", "A1"}, {"A1", "A1-NEW"}, {"A1-NEW", "A1"}} {
+		if err := f.s.MoveStock(codes[0], codes[1], 2); err == nil {
+			t.Fatalf("moving stock from %s to %s must fail", codes[0], codes[1])
+		}
+	}
+	p, err := f.s.GetByCodeValue("A1-NEW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Quantity != 10 {
+		t.Fatalf("quantity after rejected moves: %d, want 10", p.Quantity)
+	}
+}
+
+func TestServiceWritesAreLogged(t *testing.T) {
+	f := newProductFixture(t)
+	var out bytes.Buffer
+	storage := store.Decorate(f.storage, store.WithLogging(log.New(&out, "", 0)))
+	s := NewService(NewRepository(storage))
+
+	created, err := s.Create(domain.Product{Name: "Salt", Quantity: 3, CodeValue: "C3", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 1), Price: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Buy("C3", 1, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Buy("C3", 10, 0, ""); err == nil {
+		t.Fatal("buying more than the stock must fail")
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"store tx create code_value=C3 ",
+		"store tx commit ops=1 ",
+		"store tx buy code_value=C3 quantity=1 ",
+		"store tx commit ops=1 ",
+		"store tx rollback ops=0 ",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("logged %d lines, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Fatalf("line %d: %q, want prefix %q", i+1, lines[i], prefix)
+		}
+	}
+	if created.Id == 0 {
+		t.Fatal("created product has no id")
+	}
+}
@@ -0,0 +1,98 @@
+package purchase
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// Filter son los criterios para listar compras. Los campos sin valor no
+// filtran; From incluye su instante y To no.
+type Filter struct {
+	ProductID int
+	From      time.Time
+	To        time.Time
+}
+
+// match indica si la compra cumple el filtro
+func (f Filter) match(p domain.Purchase) bool {
+	switch {
+	case f.ProductID != 0 && p.ProductID != f.ProductID:
+		return false
+	case !f.From.IsZero() && p.At.Before(f.From):
+		return false
+	case !f.To.IsZero() && !p.At.Before(f.To):
+		return false
+	}
+	return true
+}
+
+type Repository interface {
+	List(f Filter) ([]domain.Purchase, error)
+	GetByID(id int) (domain.Purchase, error)
+	Create(p domain.Purchase) (domain.Purchase, error)
+	Delete(id int) error
+}
+
+// ErrNotFound indica una compra que no existe
+var ErrNotFound = errors.New("purchase not found")
+
+type repository struct {
+	storage store.Store[domain.Purchase]
+}
+
+// NewRepository crea el repositorio de compras
+func NewRepository(storage store.Store[domain.Purchase]) Repository {
+	return &repository{storage}
+}
+
+// List devuelve las compras que cumplen el filtro, la mas reciente primero
+func (r *repository) List(f Filter) ([]domain.Purchase, error) {
+	all, err := r.storage.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	purchases := []domain.Purchase{}
+	for _, p := range all {
+		if f.match(p) {
+			purchases = append(purchases, p)
+		}
+	}
+	sort.SliceStable(purchases, func(i, j int) bool {
+		if !purchases[i].At.Equal(purchases[j].At) {
+			return purchases[i].At.After(purchases[j].At)
+		}
+		return purchases[i].Id > purchases[j].Id
+	})
+	return purchases, nil
+}
+
+// GetByID busca una compra por su id
+func (r *repository) GetByID(id int) (domain.Purchase, error) {
+	p, err := r.storage.GetByID(id)
+	if err != nil {
+		return domain.Purchase{}, notFound(err)
+	}
+	return p, nil
+}
+
+// Create guarda una compra nueva
+func (r *repository) Create(p domain.Purchase) (domain.Purchase, error) {
+	return r.storage.Create(p)
+}
+
+// Delete borra una compra
+func (r *repository) Delete(id int) error {
+	return notFound(r.storage.Delete(id))
+}
+
+// notFound traduce el error generico del store al de compras
+func notFound(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package purchase
+
+import (
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+type Service interface {
+	// List devuelve una pagina de las compras que cumplen el filtro, la mas
+	// reciente primero. limit 0 devuelve todas.
+	List(f Filter, limit, offset int) (store.Page[domain.Purchase], error)
+	GetByID(id int) (domain.Purchase, error)
+	// Record guarda una compra ya hecha y la devuelve con su id
+	Record(p domain.Purchase) (domain.Purchase, error)
+	// Discard borra una compra registrada cuya operacion no se confirmo
+	Discard(id int) error
+}
+
+type service struct {
+	r Repository
+}
+
+// NewService crea el servicio de compras
+func NewService(r Repository) Service {
+	return &service{r}
+}
+
+// List devuelve una pagina de las compras que cumplen el filtro
+func (s *service) List(f Filter, limit, offset int) (store.Page[domain.Purchase], error) {
+	purchases, err := s.r.List(f)
+	if err != nil {
+		return store.Page[domain.Purchase]{}, err
+	}
+	page := store.Page[domain.Purchase]{Total: len(purchases)}
+	if offset > len(purchases) {
+		offset = len(purchases)
+	}
+	end := len(purchases)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	page.Items = purchases[offset:end]
+	return page, nil
+}
+
+// GetByID busca una compra por su id
+func (s *service) GetByID(id int) (domain.Purchase, error) {
+	return s.r.GetByID(id)
+}
+
+// Record guarda una compra
+func (s *service) Record(p domain.Purchase) (domain.Purchase, error) {
+	return s.r.Create(p)
+}
+
+// Discard borra una compra
+func (s *service) Discard(id int) error {
+	return s.r.Delete(id)
+}
@@ -0,0 +1,219 @@
+package reservation
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+type Repository interface {
+	GetByID(id int) (domain.Reservation, error)
+	Active() ([]domain.Reservation, error)
+	ActiveFor(productID int) ([]domain.Reservation, error)
+	Create(r domain.Reservation) (domain.Reservation, error)
+	Update(r domain.Reservation) error
+	UpdateAll(reservations []domain.Reservation) error
+	// el repositorio se respalda en lugar de su store para que al restaurar
+	// recargue el indice de reservas activas
+	store.Snapshotter
+}
+
+// ErrNotFound indica una reserva que no existe
+var ErrNotFound = errors.New("reservation not found")
+
+// repository guarda en memoria un indice de las reservas activas por
+// producto, asi revisar el stock reservado no relee el archivo. El indice se
+// arma en la primera lectura y se mantiene con cada cambio; todos los cambios
+// tienen que pasar por el repositorio.
+type repository struct {
+	storage store.Store[domain.Reservation]
+	mu      sync.Mutex
+	active  map[int]map[int]domain.Reservation // producto -> id -> reserva
+}
+
+// NewRepository crea el repositorio de reservas
+func NewRepository(storage store.Store[domain.Reservation]) Repository {
+	return &repository{storage: storage}
+}
+
+// index carga el indice de reservas activas si todavia no se cargo; requiere
+// tener tomado mu
+func (r *repository) index() error {
+	if r.active != nil {
+		return nil
+	}
+	all, err := r.storage.GetAll()
+	if err != nil {
+		return err
+	}
+	r.active = map[int]map[int]domain.Reservation{}
+	for _, reservation := range all {
+		r.indexed(reservation)
+	}
+	return nil
+}
+
+// indexed actualiza la reserva en el indice; requiere tener tomado mu
+func (r *repository) indexed(reservation domain.Reservation) {
+	byID := r.active[reservation.ProductID]
+	if reservation.Status != domain.ReservationActive {
+		delete(byID, reservation.Id)
+		if len(byID) == 0 {
+			delete(r.active, reservation.ProductID)
+		}
+		return
+	}
+	if byID == nil {
+		byID = map[int]domain.Reservation{}
+		r.active[reservation.ProductID] = byID
+	}
+	byID[reservation.Id] = reservation
+}
+
+// GetByID busca una reserva por su id
+func (r *repository) GetByID(id int) (domain.Reservation, error) {
+	reservation, err := r.storage.GetByID(id)
+	if err != nil {
+		return domain.Reservation{}, notFound(err)
+	}
+	return reservation, nil
+}
+
+// Active devuelve las reservas activas, incluso las vencidas que todavia no
+// se liberaron, ordenadas por id
+func (r *repository) Active() ([]domain.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.index(); err != nil {
+		return nil, err
+	}
+	active := []domain.Reservation{}
+	for _, byID := range r.active {
+		for _, reservation := range byID {
+			active = append(active, reservation)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Id < active[j].Id })
+	return active, nil
+}
+
+// ActiveFor devuelve las reservas activas de un producto
+func (r *repository) ActiveFor(productID int) ([]domain.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.index(); err != nil {
+		return nil, err
+	}
+	active := make([]domain.Reservation, 0, len(r.active[productID]))
+	for _, reservation := range r.active[productID] {
+		active = append(active, reservation)
+	}
+	return active, nil
+}
+
+// Create guarda una reserva nueva
+func (r *repository) Create(reservation domain.Reservation) (domain.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.index(); err != nil {
+		return domain.Reservation{}, err
+	}
+	created, err := r.storage.Create(reservation)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+	r.indexed(created)
+	return created, nil
+}
+
+// Update reemplaza una reserva
+func (r *repository) Update(reservation domain.Reservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.index(); err != nil {
+		return err
+	}
+	if err := r.storage.Update(reservation); err != nil {
+		return notFound(err)
+	}
+	r.indexed(reservation)
+	return nil
+}
+
+// UpdateAll reemplaza varias reservas, con una sola escritura si el store lo
+// permite
+func (r *repository) UpdateAll(reservations []domain.Reservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.index(); err != nil {
+		return err
+	}
+	if batch, ok := r.storage.(store.BatchUpdater[domain.Reservation]); ok {
+		if err := batch.UpdateAll(reservations); err != nil {
+			return notFound(err)
+		}
+	} else {
+		for _, reservation := range reservations {
+			if err := r.storage.Update(reservation); err != nil {
+				// las que ya se escribieron quedan en el indice al recargarlo
+				r.active = nil
+				return notFound(err)
+			}
+		}
+	}
+	for _, reservation := range reservations {
+		r.indexed(reservation)
+	}
+	return nil
+}
+
+// snapshotter devuelve el store como Snapshotter
+func (r *repository) snapshotter() (store.Snapshotter, error) {
+	s, ok := r.storage.(store.Snapshotter)
+	if !ok {
+		return nil, errors.New("reservation store does not support snapshots")
+	}
+	return s, nil
+}
+
+// Freeze congela el store de reservas
+func (r *repository) Freeze() (store.Snapshot, error) {
+	s, err := r.snapshotter()
+	if err != nil {
+		return nil, err
+	}
+	return s.Freeze()
+}
+
+// Validate verifica un snapshot de reservas
+func (r *repository) Validate(data []byte) error {
+	s, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+	return s.Validate(data)
+}
+
+// Restore reemplaza las reservas y descarta el indice, que se vuelve a armar
+// en la proxima lectura
+func (r *repository) Restore(data []byte) error {
+	s, err := r.snapshotter()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = nil
+	return s.Restore(data)
+}
+
+// notFound traduce el error generico del store al de reservas
+func notFound(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
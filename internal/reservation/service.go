@@ -0,0 +1,54 @@
+package reservation
+
+import "github.com/mceciabate/web-server/internal/domain"
+
+// Service guarda las reservas. Cuando se retiene o se libera stock lo decide
+// el servicio de productos, que cambia las reservas dentro de sus
+// transacciones.
+type Service interface {
+	GetByID(id int) (domain.Reservation, error)
+	Active() ([]domain.Reservation, error)
+	ActiveFor(productID int) ([]domain.Reservation, error)
+	Create(r domain.Reservation) (domain.Reservation, error)
+	Update(r domain.Reservation) error
+	UpdateAll(reservations []domain.Reservation) error
+}
+
+type service struct {
+	r Repository
+}
+
+// NewService crea el servicio de reservas
+func NewService(r Repository) Service {
+	return &service{r}
+}
+
+// GetByID busca una reserva por su id
+func (s *service) GetByID(id int) (domain.Reservation, error) {
+	return s.r.GetByID(id)
+}
+
+// Active devuelve las reservas activas
+func (s *service) Active() ([]domain.Reservation, error) {
+	return s.r.Active()
+}
+
+// ActiveFor devuelve las reservas activas de un producto
+func (s *service) ActiveFor(productID int) ([]domain.Reservation, error) {
+	return s.r.ActiveFor(productID)
+}
+
+// Create guarda una reserva nueva
+func (s *service) Create(r domain.Reservation) (domain.Reservation, error) {
+	return s.r.Create(r)
+}
+
+// Update reemplaza una reserva
+func (s *service) Update(r domain.Reservation) error {
+	return s.r.Update(r)
+}
+
+// UpdateAll reemplaza varias reservas juntas
+func (s *service) UpdateAll(reservations []domain.Reservation) error {
+	return s.r.UpdateAll(reservations)
+}
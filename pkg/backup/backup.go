@@ -0,0 +1,313 @@
+// Package backup toma snapshots consistentes de varios stores en un archivo
+// zip con fecha, los lista, los restaura y aplica reglas de retencion.
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+const (
+	prefix       = "snapshot-"
+	extension    = ".zip"
+	manifestName = "manifest.json"
+	timeLayout   = "20060102T150405.000Z"
+)
+
+// ErrNotFound indica que no existe el snapshot pedido
+var ErrNotFound = errors.New("snapshot not found")
+
+// Retention define que snapshots se conservan. Un valor cero desactiva el criterio.
+type Retention struct {
+	Keep   int           // cantidad maxima de snapshots
+	MaxAge time.Duration // antiguedad maxima
+}
+
+// Info describe un snapshot guardado
+type Info struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	Stores    []string  `json:"stores"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+type manifest struct {
+	CreatedAt time.Time `json:"created_at"`
+	Stores    []string  `json:"stores"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+type namedStore struct {
+	name  string
+	store store.Snapshotter
+	empty []byte // contenido para snapshots anteriores al store; nil si es obligatorio
+}
+
+// Manager administra los snapshots de un conjunto de stores
+type Manager struct {
+	mu        sync.Mutex
+	dir       string
+	retention Retention
+	stores    []namedStore
+	now       func() time.Time
+}
+
+// NewManager crea un manager que guarda los snapshots en dir
+func NewManager(dir string, retention Retention) *Manager {
+	return &Manager{
+		dir:       dir,
+		retention: retention,
+		now:       func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Register agrega un store a los snapshots. name identifica su archivo dentro
+// del zip y no debe repetirse. Los stores se congelan en el orden en que se
+// registran, asi que debe seguir el orden en que se toman sus locks.
+func (m *Manager) Register(name string, s store.Snapshotter) {
+	m.stores = append(m.stores, namedStore{name: name, store: s})
+}
+
+// RegisterAdded agrega un store que no estaba en los snapshots ya tomados. Al
+// restaurar uno de esos snapshots el store queda con empty, el contenido que
+// tenia antes de existir.
+func (m *Manager) RegisterAdded(name string, s store.Snapshotter, empty []byte) {
+	m.stores = append(m.stores, namedStore{name: name, store: s, empty: empty})
+}
+
+// Create toma un snapshot de todos los stores y aplica la retencion. Congela
+// todos los stores antes de leer cualquiera, asi el snapshot corresponde al
+// mismo instante en todos.
+func (m *Manager) Create() (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, err := m.create("")
+	if err != nil {
+		return Info{}, err
+	}
+	if _, err := m.prune(); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+func (m *Manager) create(reason string) (Info, error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return Info{}, err
+	}
+	snapshots := make([]store.Snapshot, 0, len(m.stores))
+	defer func() {
+		for _, snap := range snapshots {
+			snap.Release()
+		}
+	}()
+	for _, s := range m.stores {
+		snap, err := s.store.Freeze()
+		if err != nil {
+			return Info{}, fmt.Errorf("%s: %w", s.name, err)
+		}
+		snapshots = append(snapshots, snap)
+	}
+
+	createdAt := m.now()
+	info := Info{Name: prefix + createdAt.Format(timeLayout), CreatedAt: createdAt, Reason: reason}
+	if reason != "" {
+		info.Name += "-" + reason
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for i, s := range m.stores {
+		w, err := archive.Create(s.name)
+		if err != nil {
+			return Info{}, err
+		}
+		if err := snapshots[i].Write(w); err != nil {
+			return Info{}, fmt.Errorf("%s: %w", s.name, err)
+		}
+		info.Stores = append(info.Stores, s.name)
+	}
+	w, err := archive.Create(manifestName)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := json.NewEncoder(w).Encode(manifest{CreatedAt: createdAt, Stores: info.Stores, Reason: reason}); err != nil {
+		return Info{}, err
+	}
+	if err := archive.Close(); err != nil {
+		return Info{}, err
+	}
+
+	// el zip se escribe a un temporal y se renombra, asi nunca se lista uno a medias
+	path := filepath.Join(m.dir, info.Name+extension)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return Info{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return Info{}, err
+	}
+	info.Size = int64(buf.Len())
+	return info, nil
+}
+
+// List devuelve los snapshots guardados, del mas nuevo al mas viejo
+func (m *Manager) List() ([]Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list()
+}
+
+func (m *Manager) list() ([]Info, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	infos := []Info{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, extension) {
+			continue
+		}
+		info, _, err := m.open(strings.TrimSuffix(name, extension))
+		if err != nil {
+			// un archivo ilegible no impide listar el resto
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.After(infos[j].CreatedAt) })
+	return infos, nil
+}
+
+// open lee un snapshot completo: su manifest y el contenido de cada store
+func (m *Manager) open(name string) (Info, map[string][]byte, error) {
+	if name == "" || filepath.Base(name) != name || !strings.HasPrefix(name, prefix) {
+		return Info{}, nil, ErrNotFound
+	}
+	path := filepath.Join(m.dir, name+extension)
+	archive, err := zip.OpenReader(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Info{}, nil, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, nil, err
+	}
+	defer archive.Close()
+	contents := map[string][]byte{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			return Info{}, nil, err
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return Info{}, nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		contents[file.Name] = data
+	}
+	var man manifest
+	if err := json.Unmarshal(contents[manifestName], &man); err != nil {
+		return Info{}, nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Info{}, nil, err
+	}
+	info := Info{Name: name, CreatedAt: man.CreatedAt, Size: stat.Size(), Stores: man.Stores, Reason: man.Reason}
+	return info, contents, nil
+}
+
+// Restore valida el snapshot name contra todos los stores y, solo si todos lo
+// aceptan, reemplaza su contenido. Antes del reemplazo guarda un snapshot del
+// estado actual (con motivo "pre-restore"); si algun store falla a mitad de
+// camino se vuelve a ese estado.
+func (m *Manager) Restore(name string) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, contents, err := m.open(name)
+	if err != nil {
+		return Info{}, err
+	}
+	for _, s := range m.stores {
+		data, ok := contents[s.name]
+		if !ok && s.empty == nil {
+			return Info{}, fmt.Errorf("snapshot %s has no data for %s", name, s.name)
+		}
+		if !ok {
+			data = s.empty
+			contents[s.name] = data
+		}
+		if err := s.store.Validate(data); err != nil {
+			return Info{}, fmt.Errorf("snapshot %s: %s: %w", name, s.name, err)
+		}
+	}
+	backup, err := m.create("pre-restore")
+	if err != nil {
+		return Info{}, fmt.Errorf("saving current state: %w", err)
+	}
+	for i, s := range m.stores {
+		if err := s.store.Restore(contents[s.name]); err != nil {
+			return Info{}, m.rollback(backup.Name, i, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return info, nil
+}
+
+// rollback vuelve los primeros n stores al snapshot previo al restore
+func (m *Manager) rollback(name string, n int, cause error) error {
+	_, contents, err := m.open(name)
+	if err != nil {
+		return fmt.Errorf("%w; rollback failed: %v", cause, err)
+	}
+	for _, s := range m.stores[:n] {
+		if err := s.store.Restore(contents[s.name]); err != nil {
+			return fmt.Errorf("%w; rollback of %s failed: %v", cause, s.name, err)
+		}
+	}
+	return fmt.Errorf("%w; restored previous state from %s", cause, name)
+}
+
+// Prune borra los snapshots que exceden la retencion y devuelve sus nombres
+func (m *Manager) Prune() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.prune()
+}
+
+func (m *Manager) prune() ([]string, error) {
+	infos, err := m.list()
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	for i, info := range infos {
+		tooMany := m.retention.Keep > 0 && i >= m.retention.Keep
+		tooOld := m.retention.MaxAge > 0 && m.now().Sub(info.CreatedAt) > m.retention.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir, info.Name+extension)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, info.Name)
+	}
+	return removed, nil
+}
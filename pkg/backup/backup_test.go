@@ -0,0 +1,227 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// memStore es un Snapshotter en memoria. Validate rechaza "invalid" y
+// Restore falla mientras failRestore es true.
+type memStore struct {
+	data        string
+	failRestore bool
+}
+
+type memSnapshot struct{ data string }
+
+func (s memSnapshot) Write(w io.Writer) error {
+	_, err := io.WriteString(w, s.data)
+	return err
+}
+
+func (s memSnapshot) Release() {}
+
+func (s *memStore) Freeze() (store.Snapshot, error) {
+	return memSnapshot{s.data}, nil
+}
+
+func (s *memStore) Validate(data []byte) error {
+	if string(data) == "invalid" {
+		return errors.New("invalid data")
+	}
+	return nil
+}
+
+func (s *memStore) Restore(data []byte) error {
+	if s.failRestore {
+		return errors.New("disk full")
+	}
+	s.data = string(data)
+	return nil
+}
+
+// newTestManager arma un manager con dos stores y un reloj que avanza un
+// minuto en cada snapshot
+func newTestManager(t *testing.T, retention Retention) (*Manager, *memStore, *memStore) {
+	t.Helper()
+	m := NewManager(t.TempDir(), retention)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	a, b := &memStore{data: "a1"}, &memStore{data: "b1"}
+	m.Register("a.json", a)
+	m.Register("b.json", b)
+	return m, a, b
+}
+
+func TestCreateAndList(t *testing.T) {
+	m, a, _ := newTestManager(t, Retention{})
+	first, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.data = "a2"
+	second, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(first.Name, prefix) || first.Size == 0 || fmt.Sprint(first.Stores) != "[a.json b.json]" {
+		t.Fatalf("snapshot info: %+v", first)
+	}
+
+	infos, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Name != second.Name || infos[1].Name != first.Name {
+		t.Fatalf("list: %+v, want the newest first", infos)
+	}
+	_, contents, err := m.open(first.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents["a.json"]) != "a1" || string(contents["b.json"]) != "b1" {
+		t.Fatalf("first snapshot contents: %q", contents)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	m, a, b := newTestManager(t, Retention{})
+	info, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.data, b.data = "a2", "b2"
+
+	if _, err := m.Restore(info.Name); err != nil {
+		t.Fatal(err)
+	}
+	if a.data != "a1" || b.data != "b1" {
+		t.Fatalf("after restore: %q %q, want a1 b1", a.data, b.data)
+	}
+	// antes de restaurar se guarda el estado que habia
+	infos, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Reason != "pre-restore" {
+		t.Fatalf("list after restore: %+v, want a pre-restore snapshot", infos)
+	}
+	_, contents, err := m.open(infos[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents["a.json"]) != "a2" {
+		t.Fatalf("pre-restore snapshot has %q, want a2", contents["a.json"])
+	}
+}
+
+func TestRestoreValidatesBeforeChangingAnything(t *testing.T) {
+	m, a, b := newTestManager(t, Retention{})
+	b.data = "invalid"
+	info, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.data, b.data = "a2", "b2"
+
+	if _, err := m.Restore(info.Name); err == nil {
+		t.Fatal("restoring an invalid snapshot must fail")
+	}
+	if a.data != "a2" || b.data != "b2" {
+		t.Fatalf("after a rejected restore: %q %q, want a2 b2", a.data, b.data)
+	}
+	if infos, _ := m.List(); len(infos) != 1 {
+		t.Fatalf("a rejected restore must not save a pre-restore snapshot: %+v", infos)
+	}
+}
+
+func TestRestoreRollsBackOnFailure(t *testing.T) {
+	m, a, b := newTestManager(t, Retention{})
+	info, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.data, b.data = "a2", "b2"
+	b.failRestore = true
+
+	_, err = m.Restore(info.Name)
+	if err == nil || !strings.Contains(err.Error(), "restored previous state") {
+		t.Fatalf("got %v, want a rollback error", err)
+	}
+	if a.data != "a2" || b.data != "b2" {
+		t.Fatalf("after a failed restore: %q %q, want a2 b2", a.data, b.data)
+	}
+}
+
+func TestRestoreOlderSnapshotWithoutAddedStore(t *testing.T) {
+	m, _, _ := newTestManager(t, Retention{})
+	info, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := &memStore{data: "c1"}
+	m.RegisterAdded("c.json", added, []byte("[]"))
+	if _, err := m.Restore(info.Name); err != nil {
+		t.Fatal(err)
+	}
+	if added.data != "[]" {
+		t.Fatalf("added store after restore: %q, want []", added.data)
+	}
+
+	required := &memStore{data: "d1"}
+	m.Register("d.json", required)
+	if _, err := m.Restore(info.Name); err == nil {
+		t.Fatal("a snapshot without a required store must not restore")
+	}
+}
+
+func TestRestoreUnknownSnapshot(t *testing.T) {
+	m, _, _ := newTestManager(t, Retention{})
+	for _, name := range []string{"snapshot-missing", "../snapshot-x", "other"} {
+		if _, err := m.Restore(name); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("restore %q: got %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	m, _, _ := newTestManager(t, Retention{Keep: 3})
+	var names []string
+	for i := 0; i < 5; i++ {
+		info, err := m.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, info.Name)
+	}
+	infos, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 3 || infos[2].Name != names[2] {
+		t.Fatalf("after creating 5 with keep 3: %+v", infos)
+	}
+
+	// cada llamada a now avanza un minuto, asi que el mas nuevo es el unico
+	// que queda dentro de los 90 segundos
+	m.retention = Retention{MaxAge: 90 * time.Second}
+	removed, err := m.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(removed) != fmt.Sprint([]string{names[3], names[2]}) {
+		t.Fatalf("pruned %v, want %v", removed, []string{names[3], names[2]})
+	}
+	if infos, _ := m.List(); len(infos) != 1 || infos[0].Name != names[4] {
+		t.Fatalf("after pruning by age: %+v", infos)
+	}
+}
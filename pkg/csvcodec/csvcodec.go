@@ -0,0 +1,260 @@
+// Package csvcodec lee y escribe listas de registros en archivos csv. Entiende
+// dos dialectos: el csv estandar (RFC 4180) con fila de encabezado y el
+// formato historico de data/employees.csv, una lista [{a;b;c},...] con los
+// campos separados por punto y coma.
+package csvcodec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Dialect es el formato con el que se escribe el archivo
+type Dialect int
+
+const (
+	// RFC4180 es csv estandar separado por comas con fila de encabezado
+	RFC4180 Dialect = iota
+	// Braces es el formato [{1;Juan Lopez;false},...] sin encabezado
+	Braces
+)
+
+// ParseError indica en que linea del archivo fallo la lectura
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Row es un registro leido con la linea donde empieza. Si no se pudo
+// convertir, Err es un *ParseError con el motivo.
+type Row[T any] struct {
+	Line int
+	Item T
+	Err  error
+}
+
+// Codec convierte registros de tipo T a filas y viceversa. Las columnas se
+// entregan y se esperan siempre en el orden de Columns.
+type Codec[T any] struct {
+	dialect Dialect
+	columns []string
+	// required es la cantidad de columnas obligatorias; el resto son opcionales
+	required int
+	decode   func(record []string) (T, error)
+	encode   func(item T) ([]string, error)
+}
+
+// New crea un codec. Al leer se detecta el dialecto del contenido; al
+// escribir se usa siempre dialect.
+func New[T any](dialect Dialect, columns []string, decode func([]string) (T, error), encode func(T) ([]string, error)) *Codec[T] {
+	return &Codec[T]{
+		dialect:  dialect,
+		columns:  columns,
+		required: len(columns),
+		decode:   decode,
+		encode:   encode,
+	}
+}
+
+// Optional marca como opcionales las ultimas n columnas, agregadas despues de
+// que ya habia archivos escritos. Al leer, una columna opcional que falta
+// llega como ""; en el dialecto Braces tampoco se escriben las opcionales
+// vacias del final, asi los registros sin ellas quedan como antes.
+func (c *Codec[T]) Optional(n int) *Codec[T] {
+	c.required = len(c.columns) - n
+	return c
+}
+
+// Decode parsea el contenido de un archivo en cualquiera de los dos dialectos
+// y falla en el primer registro invalido
+func (c *Codec[T]) Decode(data []byte) ([]T, error) {
+	rows, err := c.Rows(data)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, 0, len(rows))
+	for _, row := range rows {
+		if row.Err != nil {
+			return nil, row.Err
+		}
+		items = append(items, row.Item)
+	}
+	return items, nil
+}
+
+// Rows parsea el contenido como Decode pero informa los registros invalidos
+// en cada fila en lugar de fallar. Solo devuelve error si el archivo no se
+// puede leer (encabezado faltante, comillas sin cerrar, etc).
+func (c *Codec[T]) Rows(data []byte) ([]Row[T], error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return []Row[T]{}, nil
+	}
+	if trimmed[0] == '[' || trimmed[0] == '{' {
+		return c.decodeBraces(data)
+	}
+	return c.decodeRFC4180(data)
+}
+
+// Encode serializa los registros en el dialecto del codec
+func (c *Codec[T]) Encode(items []T) ([]byte, error) {
+	if c.dialect == Braces {
+		return c.encodeBraces(items)
+	}
+	var buf bytes.Buffer
+	if err := c.encodeRFC4180(&buf, items); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeTo escribe los registros en w a medida que los convierte
+func (c *Codec[T]) EncodeTo(w io.Writer, items []T) error {
+	if c.dialect == Braces {
+		data, err := c.encodeBraces(items)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	return c.encodeRFC4180(w, items)
+}
+
+func (c *Codec[T]) decodeRFC4180(data []byte) ([]Row[T], error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, lineError(err, 1)
+	}
+	// posicion de cada columna esperada dentro del encabezado
+	index := make([]int, len(c.columns))
+	for i, column := range c.columns {
+		index[i] = -1
+		for j, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), column) {
+				index[i] = j
+			}
+		}
+		if index[i] < 0 && i < c.required {
+			return nil, &ParseError{Line: 1, Err: fmt.Errorf("missing column %q", column)}
+		}
+	}
+	rows := []Row[T]{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, lineError(err, 0)
+		}
+		line, _ := reader.FieldPos(0)
+		fields := make([]string, len(index))
+		for i, j := range index {
+			if j >= 0 {
+				fields[i] = strings.TrimSpace(record[j])
+			}
+		}
+		rows = append(rows, c.row(line, fields))
+	}
+}
+
+// row convierte los campos de un registro
+func (c *Codec[T]) row(line int, fields []string) Row[T] {
+	item, err := c.decode(fields)
+	if err != nil {
+		return Row[T]{Line: line, Err: &ParseError{Line: line, Err: err}}
+	}
+	return Row[T]{Line: line, Item: item}
+}
+
+func (c *Codec[T]) encodeRFC4180(w io.Writer, items []T) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(c.columns); err != nil {
+		return err
+	}
+	for _, item := range items {
+		record, err := c.encode(item)
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (c *Codec[T]) decodeBraces(data []byte) ([]Row[T], error) {
+	rows := []Row[T]{}
+	for n, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		line = strings.TrimPrefix(line, "[")
+		line = strings.TrimSuffix(line, "]")
+		// una linea puede tener uno o varios registros {a;b;c} separados por coma
+		for line = strings.TrimSpace(line); line != ""; {
+			end := strings.Index(line, "}")
+			if !strings.HasPrefix(line, "{") || end < 0 {
+				return nil, &ParseError{Line: n + 1, Err: errors.New("record must be enclosed in {}")}
+			}
+			fields := strings.Split(line[1:end], ";")
+			if len(fields) < c.required || len(fields) > len(c.columns) {
+				return nil, &ParseError{Line: n + 1, Err: fmt.Errorf("expected %d fields, got %d", len(c.columns), len(fields))}
+			}
+			fields = append(fields, make([]string, len(c.columns)-len(fields))...)
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+			rows = append(rows, c.row(n+1, fields))
+			line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[end+1:]), ","))
+		}
+	}
+	return rows, nil
+}
+
+func (c *Codec[T]) encodeBraces(items []T) ([]byte, error) {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		record, err := c.encode(item)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range record {
+			if strings.ContainsAny(field, ";{}\n") {
+				return nil, fmt.Errorf("field %q can't be written in braces format", field)
+			}
+		}
+		for len(record) > c.required && record[len(record)-1] == "" {
+			record = record[:len(record)-1]
+		}
+		lines = append(lines, "{"+strings.Join(record, ";")+"}")
+	}
+	return []byte("[" + strings.Join(lines, ",\n") + "]"), nil
+}
+
+// lineError convierte los errores de encoding/csv en ParseError
+func lineError(err error, line int) error {
+	var csvErr *csv.ParseError
+	if errors.As(err, &csvErr) {
+		return &ParseError{Line: csvErr.Line, Err: csvErr.Err}
+	}
+	if err == io.EOF {
+		err = errors.New("missing header row")
+	}
+	return &ParseError{Line: line, Err: err}
+}
@@ -0,0 +1,140 @@
+package csvcodec
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type person struct {
+	Id     int
+	Name   string
+	Active bool
+	Email  string
+}
+
+// newCodec arma un codec de personas con la columna email opcional
+func newCodec(dialect Dialect) *Codec[person] {
+	decode := func(record []string) (person, error) {
+		id, err := strconv.Atoi(record[0])
+		if err != nil {
+			return person{}, errors.New("invalid id")
+		}
+		active, err := strconv.ParseBool(record[2])
+		if err != nil {
+			return person{}, errors.New("invalid active")
+		}
+		return person{Id: id, Name: record[1], Active: active, Email: record[3]}, nil
+	}
+	encode := func(p person) ([]string, error) {
+		return []string{strconv.Itoa(p.Id), p.Name, strconv.FormatBool(p.Active), p.Email}, nil
+	}
+	return New(dialect, []string{"id", "name", "active", "email"}, decode, encode).Optional(1)
+}
+
+var people = []person{
+	{Id: 1, Name: "Juan Lopez", Active: false},
+	{Id: 2, Name: "Ana, Maria", Active: true, Email: "ana@mail.com"},
+}
+
+func TestRoundTrip(t *testing.T) {
+	cases := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{RFC4180, "id,name,active,email\n1,Juan Lopez,false,\n2,\"Ana, Maria\",true,ana@mail.com\n"},
+		{Braces, "[{1;Juan Lopez;false},\n{2;Ana, Maria;true;ana@mail.com}]"},
+	}
+	for _, c := range cases {
+		codec := newCodec(c.dialect)
+		data, err := codec.Encode(people)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != c.want {
+			t.Fatalf("dialect %d encoded:\n%s\nwant\n%s", c.dialect, data, c.want)
+		}
+		decoded, err := codec.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, people) {
+			t.Fatalf("dialect %d decoded %+v, want %+v", c.dialect, decoded, people)
+		}
+	}
+}
+
+func TestDecodeDetectsDialect(t *testing.T) {
+	codec := newCodec(RFC4180)
+	inputs := []string{
+		"[{1;Juan Lopez;false},{2;Ana, Maria;true;ana@mail.com}]",
+		"Email,Active,Name,Id\n,false,Juan Lopez,1\nana@mail.com,true,\"Ana, Maria\",2\n",
+		"id,name,active\n1,Juan Lopez,false\n2,\"Ana, Maria\",true\n",
+	}
+	for _, input := range inputs {
+		decoded, err := codec.Decode([]byte(input))
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if len(decoded) != 2 || decoded[0] != people[0] || decoded[1].Name != "Ana, Maria" {
+			t.Fatalf("%q: decoded %+v", input, decoded)
+		}
+	}
+	if decoded, err := codec.Decode([]byte("  \n")); err != nil || len(decoded) != 0 {
+		t.Fatalf("empty file: %v %v, want no records", decoded, err)
+	}
+}
+
+func TestParseErrorsHaveLineNumbers(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{"rfc invalid field", "id,name,active\n1,Juan,false\nx,Ana,true\n", 3},
+		{"rfc missing column", "id,name\n1,Juan\n", 1},
+		{"rfc unclosed quote", "id,name,active\n1,Juan,false\n2,\"Ana,true\n", 3},
+		{"braces invalid field", "[{1;Juan;false},\n{2;Ana;maybe}]", 2},
+		{"braces missing fields", "[{1;Juan;false},\n{2;Ana}]", 2},
+		{"braces too many fields", "[{1;Juan;false;a;b}]", 1},
+		{"braces without braces", "[{1;Juan;false},\n2;Ana;true]", 2},
+	}
+	for _, c := range cases {
+		_, err := newCodec(RFC4180).Decode([]byte(c.input))
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%s: got %v, want a ParseError", c.name, err)
+		}
+		if parseErr.Line != c.line {
+			t.Fatalf("%s: error on line %d, want %d (%v)", c.name, parseErr.Line, c.line, err)
+		}
+	}
+}
+
+func TestRowsReportsInvalidRecords(t *testing.T) {
+	rows, err := newCodec(RFC4180).Rows([]byte("id,name,active\n1,Juan,false\nx,Ana,true\n3,Luis,true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	for i, want := range []int{2, 3, 4} {
+		if rows[i].Line != want {
+			t.Fatalf("row %d on line %d, want %d", i, rows[i].Line, want)
+		}
+	}
+	if rows[0].Err != nil || rows[2].Err != nil || rows[1].Err == nil {
+		t.Fatalf("only the second row must fail: %+v", rows)
+	}
+	if rows[1].Err.Error() != "line 3: invalid id" {
+		t.Fatalf("row error %q, want %q", rows[1].Err, "line 3: invalid id")
+	}
+}
+
+func TestBracesRejectsUnwritableFields(t *testing.T) {
+	if _, err := newCodec(Braces).Encode([]person{{Id: 1, Name: "a;b"}}); err == nil {
+		t.Fatal("a field with ; can't be written in braces format")
+	}
+}
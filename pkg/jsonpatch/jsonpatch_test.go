@@ -0,0 +1,131 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// canonical reescribe un documento json con las claves ordenadas, para
+// comparar documentos sin importar el formato
+func canonical(t *testing.T, doc string) string {
+	t.Helper()
+	var value any
+	if err := json.Unmarshal([]byte(doc), &value); err != nil {
+		t.Fatalf("invalid json %s: %v", doc, err)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApply(t *testing.T) {
+	const doc = `{"a":1,"b":{"c":[1,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		{name: "add member", doc: `{"a":1}`, patch: `[{"op":"add","path":"/b","value":2}]`, want: `{"a":1,"b":2}`},
+		{name: "add replaces existing member", doc: `{"a":1}`, patch: `[{"op":"add","path":"/a","value":[1]}]`, want: `{"a":[1]}`},
+		{name: "add inserts in array", doc: doc, patch: `[{"op":"add","path":"/b/c/1","value":9}]`, want: `{"a":1,"b":{"c":[1,9,2,3]},"a/b":"slash","m~n":"tilde","~1":"literal"}`},
+		{name: "add at array length appends", doc: `[1,2]`, patch: `[{"op":"add","path":"/2","value":3}]`, want: `[1,2,3]`},
+		{name: "add with - appends", doc: `[1,2]`, patch: `[{"op":"add","path":"/-","value":3}]`, want: `[1,2,3]`},
+		{name: "add past array length", doc: `[1,2]`, patch: `[{"op":"add","path":"/3","value":3}]`, err: ErrInvalidPatch},
+		{name: "add with leading zero index", doc: `[1,2]`, patch: `[{"op":"add","path":"/01","value":3}]`, err: ErrInvalidPatch},
+		{name: "add under missing parent", doc: `{}`, patch: `[{"op":"add","path":"/a/b","value":1}]`, err: ErrInvalidPatch},
+		{name: "add at root replaces document", doc: `{"a":1}`, patch: `[{"op":"add","path":"","value":[1]}]`, want: `[1]`},
+		{name: "remove member", doc: `{"a":1,"b":2}`, patch: `[{"op":"remove","path":"/a"}]`, want: `{"b":2}`},
+		{name: "remove array element", doc: `[1,2,3]`, patch: `[{"op":"remove","path":"/0"}]`, want: `[2,3]`},
+		{name: "remove missing member", doc: `{"a":1}`, patch: `[{"op":"remove","path":"/b"}]`, err: ErrInvalidPatch},
+		{name: "remove with - index", doc: `[1,2]`, patch: `[{"op":"remove","path":"/-"}]`, err: ErrInvalidPatch},
+		{name: "replace member", doc: `{"a":1}`, patch: `[{"op":"replace","path":"/a","value":false}]`, want: `{"a":false}`},
+		{name: "replace array element", doc: `[1,2,3]`, patch: `[{"op":"replace","path":"/2","value":0}]`, want: `[1,2,0]`},
+		{name: "replace missing member", doc: `{"a":1}`, patch: `[{"op":"replace","path":"/b","value":1}]`, err: ErrInvalidPatch},
+		{name: "replace root", doc: `{"a":1}`, patch: `[{"op":"replace","path":"","value":{"b":2}}]`, want: `{"b":2}`},
+		{name: "replace with null keeps the member", doc: `{"a":1}`, patch: `[{"op":"replace","path":"/a","value":null}]`, want: `{"a":null}`},
+		{name: "move member", doc: `{"a":1,"b":{}}`, patch: `[{"op":"move","from":"/a","path":"/b/a"}]`, want: `{"b":{"a":1}}`},
+		{name: "move within array", doc: `[1,2,3]`, patch: `[{"op":"move","from":"/0","path":"/2"}]`, want: `[2,3,1]`},
+		{name: "move to itself", doc: `{"a":1}`, patch: `[{"op":"move","from":"/a","path":"/a"}]`, want: `{"a":1}`},
+		{name: "move into own child", doc: `{"a":{"b":1}}`, patch: `[{"op":"move","from":"/a","path":"/a/c"}]`, err: ErrInvalidPatch},
+		{name: "move to sibling with same prefix", doc: `{"a":1}`, patch: `[{"op":"move","from":"/a","path":"/ab"}]`, want: `{"ab":1}`},
+		{name: "move from missing", doc: `{"a":1}`, patch: `[{"op":"move","from":"/b","path":"/c"}]`, err: ErrInvalidPatch},
+		{name: "copy member", doc: `{"a":{"x":1}}`, patch: `[{"op":"copy","from":"/a","path":"/b"}]`, want: `{"a":{"x":1},"b":{"x":1}}`},
+		{name: "copy is deep", doc: `{"a":{"x":1}}`, patch: `[{"op":"copy","from":"/a","path":"/b"},{"op":"replace","path":"/b/x","value":2}]`, want: `{"a":{"x":1},"b":{"x":2}}`},
+		{name: "copy into array", doc: `{"a":[1,2]}`, patch: `[{"op":"copy","from":"/a/1","path":"/a/0"}]`, want: `{"a":[2,1,2]}`},
+		{name: "test passes", doc: doc, patch: `[{"op":"test","path":"/b/c","value":[1,2,3]}]`, want: doc},
+		{name: "test compares numbers by value", doc: `{"a":1}`, patch: `[{"op":"test","path":"/a","value":1.0}]`, want: `{"a":1}`},
+		{name: "test fails", doc: `{"a":1}`, patch: `[{"op":"test","path":"/a","value":2}]`, err: ErrTestFailed},
+		{name: "test missing path", doc: `{"a":1}`, patch: `[{"op":"test","path":"/b","value":1}]`, err: ErrInvalidPatch},
+		{name: "test root", doc: `{"a":1}`, patch: `[{"op":"test","path":"","value":{"a":1}}]`, want: `{"a":1}`},
+		{name: "failed test discards earlier operations", doc: `{"a":1}`, patch: `[{"op":"add","path":"/b","value":2},{"op":"test","path":"/a","value":3}]`, err: ErrTestFailed},
+		{name: "escaped slash", doc: doc, patch: `[{"op":"replace","path":"/a~1b","value":"x"}]`, want: `{"a":1,"b":{"c":[1,2,3]},"a/b":"x","m~n":"tilde","~1":"literal"}`},
+		{name: "escaped tilde", doc: doc, patch: `[{"op":"remove","path":"/m~0n"}]`, want: `{"a":1,"b":{"c":[1,2,3]},"a/b":"slash","~1":"literal"}`},
+		{name: "tilde zero one is a literal tilde one", doc: doc, patch: `[{"op":"test","path":"/~01","value":"literal"}]`, want: doc},
+		{name: "path without leading slash", doc: `{"a":1}`, patch: `[{"op":"remove","path":"a"}]`, err: ErrInvalidPatch},
+		{name: "missing path", doc: `{"a":1}`, patch: `[{"op":"remove"}]`, err: ErrInvalidPatch},
+		{name: "missing value", doc: `{"a":1}`, patch: `[{"op":"add","path":"/b"}]`, err: ErrInvalidPatch},
+		{name: "missing from", doc: `{"a":1}`, patch: `[{"op":"copy","path":"/b"}]`, err: ErrInvalidPatch},
+		{name: "unknown op", doc: `{"a":1}`, patch: `[{"op":"increment","path":"/a"}]`, err: ErrInvalidPatch},
+		{name: "patch is not an array", doc: `{"a":1}`, patch: `{"op":"remove","path":"/a"}`, err: ErrInvalidPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %s, %v; want error %v", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if canonical(t, string(got)) != canonical(t, tt.want) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{name: "replace member", doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "add member", doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "null deletes member", doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "null deletes nested member", doc: `{"a":{"b":1,"c":2}}`, patch: `{"a":{"b":null}}`, want: `{"a":{"c":2}}`},
+		{name: "null for missing member", doc: `{"a":1}`, patch: `{"b":null}`, want: `{"a":1}`},
+		{name: "false and zero are kept", doc: `{"a":true,"b":5}`, patch: `{"a":false,"b":0}`, want: `{"a":false,"b":0}`},
+		{name: "arrays are replaced", doc: `{"a":[1,2]}`, patch: `{"a":[3]}`, want: `{"a":[3]}`},
+		{name: "object replaces scalar", doc: `{"a":"b"}`, patch: `{"a":{"c":null,"d":1}}`, want: `{"a":{"d":1}}`},
+		{name: "non object patch replaces document", doc: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{name: "null patch replaces document", doc: `{"a":"b"}`, patch: `null`, want: `null`},
+		{name: "empty patch changes nothing", doc: `{"a":{"b":1}}`, patch: `{}`, want: `{"a":{"b":1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if canonical(t, string(got)) != canonical(t, tt.want) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	if _, err := MergePatch([]byte(`{"a":1}`), []byte(`{"a":`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Fatalf("got %v, want ErrInvalidPatch", err)
+	}
+}
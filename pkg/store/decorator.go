@@ -0,0 +1,184 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// Decorator envuelve un ProductStore agregando comportamiento (logging, metricas,
+// cache, reintentos) sin modificar el backend.
+//
+// Para escribir uno basta con embeber el ProductStore envuelto en un struct y
+// redefinir solo los metodos que interesan; el resto se delega solo.
+type Decorator func(ProductStore) ProductStore
+
+// Decorate aplica los decorators sobre s. El primero de la lista queda como
+// capa mas externa, es decir, es el primero en recibir cada llamada.
+func Decorate(s ProductStore, decorators ...Decorator) ProductStore {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		s = decorators[i](s)
+	}
+	return s
+}
+
+type loggingStore struct {
+	ProductStore
+	logger *log.Logger
+}
+
+// WithLogging registra cada operacion de escritura con su duracion y error
+func WithLogging(logger *log.Logger) Decorator {
+	return func(next ProductStore) ProductStore {
+		return &loggingStore{ProductStore: next, logger: logger}
+	}
+}
+
+func (s *loggingStore) log(op string, start time.Time, err error) {
+	s.logger.Printf("store %s took=%s err=%v", op, time.Since(start), err)
+}
+
+// Create registra la creacion de un producto
+func (s *loggingStore) Create(product domain.Product) (domain.Product, error) {
+	start := time.Now()
+	created, err := s.ProductStore.Create(product)
+	s.log("create code_value="+product.CodeValue, start, err)
+	return created, err
+}
+
+// Update registra la actualizacion de un producto
+func (s *loggingStore) Update(product domain.Product) error {
+	start := time.Now()
+	err := s.ProductStore.Update(product)
+	s.log(fmt.Sprintf("update id=%d", product.Id), start, err)
+	return err
+}
+
+// Delete registra la baja de un producto
+func (s *loggingStore) Delete(id int) error {
+	start := time.Now()
+	err := s.ProductStore.Delete(id)
+	s.log(fmt.Sprintf("delete id=%d", id), start, err)
+	return err
+}
+
+// Buy registra una compra
+func (s *loggingStore) Buy(code string, quantity int) error {
+	start := time.Now()
+	err := s.ProductStore.Buy(code, quantity)
+	s.log("buy code_value="+code, start, err)
+	return err
+}
+
+// Undelete registra la recuperacion de un producto de la papelera
+func (s *loggingStore) Undelete(id int) (domain.Product, error) {
+	start := time.Now()
+	restored, err := s.ProductStore.Undelete(id)
+	s.log(fmt.Sprintf("undelete id=%d", id), start, err)
+	return restored, err
+}
+
+// Purge registra la purga de la papelera
+func (s *loggingStore) Purge(before time.Time) (int, error) {
+	start := time.Now()
+	purged, err := s.ProductStore.Purge(before)
+	s.log(fmt.Sprintf("purge before=%s purged=%d", before.Format(time.RFC3339), purged), start, err)
+	return purged, err
+}
+
+// Begin abre una transaccion que registra cada escritura y como termina. Los
+// servicios escriben siempre dentro de una transaccion, asi que sin esto el
+// log no veria ningun cambio.
+func (s *loggingStore) Begin() (Tx, error) {
+	tx, err := s.ProductStore.Begin()
+	if err != nil {
+		s.log("begin", time.Now(), err)
+		return nil, err
+	}
+	return &loggingTx{Tx: tx, s: s, start: time.Now()}, nil
+}
+
+// loggingTx registra las escrituras de una transaccion con el prefijo tx
+type loggingTx struct {
+	Tx
+	s     *loggingStore
+	start time.Time
+	ops   int
+	done  bool
+}
+
+func (tx *loggingTx) log(op string, start time.Time, err error) {
+	tx.ops++
+	tx.s.log("tx "+op, start, err)
+}
+
+// Create registra la creacion de un producto
+func (tx *loggingTx) Create(product domain.Product) (domain.Product, error) {
+	start := time.Now()
+	created, err := tx.Tx.Create(product)
+	tx.log("create code_value="+product.CodeValue, start, err)
+	return created, err
+}
+
+// Update registra la actualizacion de un producto
+func (tx *loggingTx) Update(product domain.Product) error {
+	start := time.Now()
+	err := tx.Tx.Update(product)
+	tx.log(fmt.Sprintf("update id=%d", product.Id), start, err)
+	return err
+}
+
+// Rename registra el cambio de code_value de un producto
+func (tx *loggingTx) Rename(id int, code string) error {
+	start := time.Now()
+	err := tx.Tx.Rename(id, code)
+	tx.log(fmt.Sprintf("rename id=%d code_value=%s", id, code), start, err)
+	return err
+}
+
+// Delete registra la baja de un producto
+func (tx *loggingTx) Delete(id int) error {
+	start := time.Now()
+	err := tx.Tx.Delete(id)
+	tx.log(fmt.Sprintf("delete id=%d", id), start, err)
+	return err
+}
+
+// Undelete registra la recuperacion de un producto de la papelera
+func (tx *loggingTx) Undelete(id int) (domain.Product, error) {
+	start := time.Now()
+	restored, err := tx.Tx.Undelete(id)
+	tx.log(fmt.Sprintf("undelete id=%d", id), start, err)
+	return restored, err
+}
+
+// Buy registra una compra
+func (tx *loggingTx) Buy(code string, quantity int) error {
+	start := time.Now()
+	err := tx.Tx.Buy(code, quantity)
+	tx.log(fmt.Sprintf("buy code_value=%s quantity=%d", code, quantity), start, err)
+	return err
+}
+
+// Commit registra la confirmacion con la cantidad de escrituras y la duracion
+// de toda la transaccion
+func (tx *loggingTx) Commit() error {
+	err := tx.Tx.Commit()
+	if !tx.done {
+		tx.done = true
+		tx.s.log(fmt.Sprintf("tx commit ops=%d", tx.ops), tx.start, err)
+	}
+	return err
+}
+
+// Rollback registra el descarte de una transaccion que no se confirmo
+func (tx *loggingTx) Rollback() error {
+	err := tx.Tx.Rollback()
+	if !tx.done {
+		tx.done = true
+		tx.s.log(fmt.Sprintf("tx rollback ops=%d", tx.ops), tx.start, err)
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// tracingStore anota su nombre en calls cada vez que recibe un GetByID
+type tracingStore struct {
+	ProductStore
+	name  string
+	calls *[]string
+}
+
+func tracing(name string, calls *[]string) Decorator {
+	return func(next ProductStore) ProductStore {
+		return &tracingStore{ProductStore: next, name: name, calls: calls}
+	}
+}
+
+func (s *tracingStore) GetByID(id int) (domain.Product, error) {
+	*s.calls = append(*s.calls, s.name)
+	return s.ProductStore.GetByID(id)
+}
+
+// logLines devuelve las lineas escritas en out
+func logLines(out *bytes.Buffer) []string {
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+// assertLog verifica que cada linea sea "store <op> took=<duracion> err=<err>"
+// con las operaciones y errores de want, en orden
+func assertLog(t *testing.T, out *bytes.Buffer, want ...string) {
+	t.Helper()
+	lines := logLines(out)
+	if len(lines) != len(want) {
+		t.Fatalf("log:\n%s\nwant %d lines", out, len(want))
+	}
+	for i, line := range lines {
+		op, err, _ := strings.Cut(want[i], " err=")
+		pattern := "^store " + regexp.QuoteMeta(op) + ` took=\S+ err=` + regexp.QuoteMeta(err) + "$"
+		if !regexp.MustCompile(pattern).MatchString(line) {
+			t.Fatalf("log line %d: %q, want %q", i, line, want[i])
+		}
+	}
+}
+
+func TestDecorateOrder(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		if Decorate(s) != s {
+			t.Fatal("Decorate without decorators must return the same store")
+		}
+		var calls []string
+		decorated := Decorate(s, tracing("outer", &calls), tracing("middle", &calls), tracing("inner", &calls))
+		p, err := decorated.GetByID(1)
+		if err != nil || p.CodeValue != "A1" {
+			t.Fatalf("GetByID through the decorators: %+v %v", p, err)
+		}
+		if strings.Join(calls, ",") != "outer,middle,inner" {
+			t.Fatalf("calls: %v, want outer,middle,inner", calls)
+		}
+	})
+}
+
+func TestLoggingFormat(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		var out bytes.Buffer
+		logged := Decorate(s, WithLogging(log.New(&out, "", 0)))
+
+		p := domain.Product{Name: "Tea", Quantity: 1, CodeValue: "D4", Expiration: domain.NewDate(2030, time.May, 1), Price: 100, Currency: domain.ARS}
+		created, err := logged.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		created.Quantity = 3
+		if err := logged.Update(created); err != nil {
+			t.Fatal(err)
+		}
+		if err := logged.Buy("D4", 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := logged.Delete(created.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := logged.Undelete(created.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := logged.GetByID(created.Id); err != nil {
+			t.Fatal(err)
+		}
+		before := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		if _, err := logged.Purge(before); err != nil {
+			t.Fatal(err)
+		}
+		assertLog(t, &out,
+			"create code_value=D4 err=<nil>",
+			"update id=4 err=<nil>",
+			"buy code_value=D4 err=<nil>",
+			"delete id=4 err=<nil>",
+			"undelete id=4 err=<nil>",
+			"purge before=2020-01-01T00:00:00Z purged=0 err=<nil>",
+		)
+	})
+}
+
+func TestLoggingTransactions(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		var out bytes.Buffer
+		logged := Decorate(s, WithLogging(log.New(&out, "", 0)))
+
+		tx, err := logged.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Buy("A1", 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Rename(2, "B2-NEW"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		tx.Rollback()
+
+		tx, err = logged.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Delete(3); err != nil {
+			t.Fatal(err)
+		}
+		tx.Rollback()
+		tx.Rollback()
+
+		assertLog(t, &out,
+			"tx buy code_value=A1 quantity=1 err=<nil>",
+			"tx rename id=2 code_value=B2-NEW err=<nil>",
+			"tx commit ops=2 err=<nil>",
+			"tx delete id=3 err=<nil>",
+			"tx rollback ops=1 err=<nil>",
+		)
+	})
+}
+
+func TestLoggingPassesErrorsThrough(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		var out bytes.Buffer
+		logged := Decorate(s, WithLogging(log.New(&out, "", 0)))
+
+		duplicate := domain.Product{Name: "Copy", Quantity: 1, CodeValue: "A1", Expiration: domain.NewDate(2030, time.May, 1), Price: 100, Currency: domain.ARS}
+		if _, err := logged.Create(duplicate); !errors.Is(err, ErrDuplicateCodeValue) {
+			t.Fatalf("Create: got %v, want ErrDuplicateCodeValue", err)
+		}
+		if _, err := logged.Undelete(1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Undelete: got %v, want ErrNotFound", err)
+		}
+		tx, err := logged.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if _, err := tx.Create(duplicate); !errors.Is(err, ErrDuplicateCodeValue) {
+			t.Fatalf("tx Create: got %v, want ErrDuplicateCodeValue", err)
+		}
+
+		lines := logLines(&out)
+		if len(lines) != 3 {
+			t.Fatalf("log:\n%s\nwant 3 lines", &out)
+		}
+		for _, line := range lines {
+			if strings.HasSuffix(line, "err=<nil>") {
+				t.Fatalf("failed operation logged without its error: %q", line)
+			}
+		}
+		if !strings.Contains(lines[0], "err="+ErrDuplicateCodeValue.Error()) {
+			t.Fatalf("log line %q must contain the error", lines[0])
+		}
+	})
+}
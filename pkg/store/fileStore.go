@@ -0,0 +1,315 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Codec convierte una lista de registros desde y hacia el contenido de un archivo
+type Codec[T any] interface {
+	Decode(data []byte) ([]T, error)
+	Encode(items []T) ([]byte, error)
+}
+
+// JSONCodec guarda los registros como un array json
+type JSONCodec[T any] struct{}
+
+// Decode parsea un array json
+func (JSONCodec[T]) Decode(data []byte) ([]T, error) {
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Encode serializa los registros como un array json
+func (JSONCodec[T]) Encode(items []T) ([]byte, error) {
+	return json.Marshal(items)
+}
+
+// CodecByExtension elige entre codecs, indexados por extension (".json",
+// ".csv"), el que corresponde al archivo en path. Falla si la extension no
+// tiene codec.
+func CodecByExtension[T any](path string, codecs map[string]Codec[T]) (Codec[T], error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	codec, ok := codecs[ext]
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported file extension %q", path, ext)
+	}
+	return codec, nil
+}
+
+type fileStore[T any] struct {
+	mu         sync.Mutex
+	pathToFile string
+	codec      Codec[T]
+	id         func(*T) *int
+	deletedAt  func(*T) **time.Time
+	seq        *Sequence
+}
+
+// FileOption configura un store de archivo
+type FileOption[T any] func(*fileStore[T])
+
+// WithSoftDelete hace que Delete marque el registro en lugar de quitarlo del
+// archivo. deletedAt devuelve un puntero al campo con el momento de la baja.
+func WithSoftDelete[T any](deletedAt func(*T) **time.Time) FileOption[T] {
+	return func(s *fileStore[T]) {
+		s.deletedAt = deletedAt
+	}
+}
+
+// NewFileStore crea un store generico persistido en un archivo. id devuelve un
+// puntero al campo id del registro, que el store usa para buscarlo y asignarlo.
+// Si el archivo no existe o esta vacio se arranca con una lista vacia. Los ids nuevos salen
+// de la secuencia guardada en <path>.seq, que se abre en la primera lectura.
+// Sin WithSoftDelete la papelera siempre esta vacia.
+func NewFileStore[T any](path string, codec Codec[T], id func(*T) *int, opts ...FileOption[T]) TrashStore[T] {
+	s := &fileStore[T]{
+		pathToFile: path,
+		codec:      codec,
+		id:         id,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// deleted indica si el registro esta en la papelera
+func (s *fileStore[T]) deleted(item *T) bool {
+	return s.deletedAt != nil && *s.deletedAt(item) != nil
+}
+
+// undelete quita la marca de baja del registro
+func (s *fileStore[T]) undelete(item *T) {
+	if s.deletedAt != nil {
+		*s.deletedAt(item) = nil
+	}
+}
+
+// find devuelve la posicion del registro con el id dado que esta (o no) en
+// la papelera, o -1
+func (s *fileStore[T]) find(items []T, id int, deleted bool) int {
+	for i := range items {
+		if *s.id(&items[i]) == id && s.deleted(&items[i]) == deleted {
+			return i
+		}
+	}
+	return -1
+}
+
+// load lee todos los registros del archivo y verifica que no haya ids repetidos
+func (s *fileStore[T]) load() ([]T, error) {
+	items := []T{}
+	file, err := os.ReadFile(s.pathToFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	// un archivo vacio, ej. recien creado con touch, no tiene registros
+	if err == nil && len(bytes.TrimSpace(file)) > 0 {
+		if items, err = s.codec.Decode(file); err != nil {
+			return nil, err
+		}
+	}
+	if err := CheckIDs(s.pathToFile, items, s.id); err != nil {
+		return nil, err
+	}
+	if s.seq == nil {
+		if s.seq, err = NewFileSequence(s.pathToFile+".seq", maxID(items, s.id)); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// save escribe todos los registros en el archivo
+func (s *fileStore[T]) save(items []T) error {
+	data, err := s.codec.Encode(items)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.pathToFile, data, 0644)
+}
+
+// GetAll devuelve todos los registros que no estan en la papelera
+func (s *fileStore[T]) GetAll() ([]T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	live := make([]T, 0, len(items))
+	for i := range items {
+		if !s.deleted(&items[i]) {
+			live = append(live, items[i])
+		}
+	}
+	return live, nil
+}
+
+// GetByID devuelve un registro por su id
+func (s *fileStore[T]) GetByID(id int) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var zero T
+	items, err := s.load()
+	if err != nil {
+		return zero, err
+	}
+	if i := s.find(items, id, false); i >= 0 {
+		return items[i], nil
+	}
+	return zero, ErrNotFound
+}
+
+// Create agrega un registro asignandole el siguiente id de la secuencia
+func (s *fileStore[T]) Create(item T) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var zero T
+	items, err := s.load()
+	if err != nil {
+		return zero, err
+	}
+	next, err := s.seq.Next()
+	if err != nil {
+		return zero, err
+	}
+	*s.id(&item) = next
+	s.undelete(&item)
+	if err := s.save(append(items, item)); err != nil {
+		return zero, err
+	}
+	return item, nil
+}
+
+// Update reemplaza el registro con el mismo id
+func (s *fileStore[T]) Update(item T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, err := s.load()
+	if err != nil {
+		return err
+	}
+	i := s.find(items, *s.id(&item), false)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.undelete(&item)
+	items[i] = item
+	return s.save(items)
+}
+
+// UpdateAll reemplaza los registros con los mismos ids escribiendo el archivo
+// una sola vez
+func (s *fileStore[T]) UpdateAll(updated []T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, err := s.load()
+	if err != nil {
+		return err
+	}
+	for _, item := range updated {
+		i := s.find(items, *s.id(&item), false)
+		if i < 0 {
+			return ErrNotFound
+		}
+		s.undelete(&item)
+		items[i] = item
+	}
+	return s.save(items)
+}
+
+// Delete manda a la papelera el registro con el id dado, o lo elimina si el
+// store no tiene WithSoftDelete
+func (s *fileStore[T]) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, err := s.load()
+	if err != nil {
+		return err
+	}
+	i := s.find(items, id, false)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if s.deletedAt == nil {
+		return s.save(append(items[:i], items[i+1:]...))
+	}
+	now := time.Now().UTC()
+	*s.deletedAt(&items[i]) = &now
+	return s.save(items)
+}
+
+// Trash devuelve los registros borrados, el mas reciente primero
+func (s *fileStore[T]) Trash() ([]T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	trash := []T{}
+	for i := range items {
+		if s.deleted(&items[i]) {
+			trash = append(trash, items[i])
+		}
+	}
+	sort.SliceStable(trash, func(i, j int) bool {
+		return (*s.deletedAt(&trash[i])).After(**s.deletedAt(&trash[j]))
+	})
+	return trash, nil
+}
+
+// Undelete devuelve un registro de la papelera a las lecturas normales
+func (s *fileStore[T]) Undelete(id int) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var zero T
+	items, err := s.load()
+	if err != nil {
+		return zero, err
+	}
+	i := s.find(items, id, true)
+	if i < 0 {
+		return zero, ErrNotFound
+	}
+	s.undelete(&items[i])
+	if err := s.save(items); err != nil {
+		return zero, err
+	}
+	return items[i], nil
+}
+
+// Purge elimina definitivamente los registros borrados antes de before
+func (s *fileStore[T]) Purge(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	kept := make([]T, 0, len(items))
+	for i := range items {
+		if s.deleted(&items[i]) && (*s.deletedAt(&items[i])).Before(before) {
+			continue
+		}
+		kept = append(kept, items[i])
+	}
+	purged := len(items) - len(kept)
+	if purged == 0 {
+		return 0, nil
+	}
+	return purged, s.save(kept)
+}
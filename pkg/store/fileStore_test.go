@@ -0,0 +1,155 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/pkg/csvcodec"
+)
+
+// note es el registro de prueba de los stores de archivo
+type note struct {
+	ID        int        `json:"id"`
+	Text      string     `json:"text"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func noteID(n *note) *int { return &n.ID }
+
+// noteCSV guarda las notas como csv con columnas id y text
+func noteCSV() Codec[note] {
+	return csvcodec.New(csvcodec.RFC4180, []string{"id", "text"},
+		func(record []string) (note, error) {
+			id, err := strconv.Atoi(record[0])
+			return note{ID: id, Text: record[1]}, err
+		},
+		func(n note) ([]string, error) { return []string{strconv.Itoa(n.ID), n.Text}, nil },
+	)
+}
+
+// failingCodec lee con JSONCodec pero nunca puede escribir
+type failingCodec struct{ JSONCodec[note] }
+
+func (failingCodec) Encode([]note) ([]byte, error) { return nil, errors.New("disk full") }
+
+func TestCodecByExtension(t *testing.T) {
+	codecs := map[string]Codec[note]{".json": JSONCodec[note]{}, ".csv": noteCSV()}
+	for path, want := range map[string]Codec[note]{
+		"data/notes.json": codecs[".json"],
+		"data/notes.CSV":  codecs[".csv"],
+	} {
+		codec, err := CodecByExtension(path, codecs)
+		if err != nil || codec != want {
+			t.Fatalf("%s: got %T %v", path, codec, err)
+		}
+	}
+	for _, path := range []string{"notes.xml", "notes"} {
+		if _, err := CodecByExtension(path, codecs); err == nil {
+			t.Fatalf("%s: an unsupported extension must fail", path)
+		}
+	}
+}
+
+func TestFileStoreRoundTripPerCodec(t *testing.T) {
+	for ext, codec := range map[string]Codec[note]{".json": JSONCodec[note]{}, ".csv": noteCSV()} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "notes"+ext)
+			s := NewFileStore[note](path, codec, noteID)
+			for _, text := range []string{"first", "second, with a comma"} {
+				if _, err := s.Create(note{Text: text}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Update(note{ID: 1, Text: "first \"edited\""}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Update(note{ID: 9, Text: "missing"}); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Update of a missing id: got %v, want ErrNotFound", err)
+			}
+
+			notes, err := NewFileStore[note](path, codec, noteID).GetAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(notes) != 2 || notes[0] != (note{ID: 1, Text: "first \"edited\""}) || notes[1] != (note{ID: 2, Text: "second, with a comma"}) {
+				t.Fatalf("notes after reopening: %+v", notes)
+			}
+		})
+	}
+}
+
+func TestFileStoreMissingAndEmptyFile(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.json")
+	s := NewFileStore[note](missing, JSONCodec[note]{}, noteID)
+	if notes, err := s.GetAll(); err != nil || len(notes) != 0 {
+		t.Fatalf("missing file: %+v %v", notes, err)
+	}
+	if _, err := s.GetByID(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetByID on a missing file: got %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("reading must not create the file")
+	}
+
+	for name, codec := range map[string]Codec[note]{"empty.json": JSONCodec[note]{}, "empty.csv": noteCSV()} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s := NewFileStore[note](path, codec, noteID)
+		if notes, err := s.GetAll(); err != nil || len(notes) != 0 {
+			t.Fatalf("%s: %+v %v", name, notes, err)
+		}
+		if n, err := s.Create(note{Text: "first"}); err != nil || n.ID != 1 {
+			t.Fatalf("%s: Create: %+v %v", name, n, err)
+		}
+	}
+}
+
+func TestFileStoreWritesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.json")
+	if err := os.WriteFile(path, []byte(`[{"id":1,"text":"kept"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := NewFileStore[note](path, failingCodec{}, noteID)
+	if err := broken.Update(note{ID: 1, Text: "lost"}); err == nil {
+		t.Fatal("a failed encode must fail the update")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"id":1,"text":"kept"}]` {
+		t.Fatalf("a failed write changed the file: %s", data)
+	}
+
+	s := NewFileStore[note](path, JSONCodec[note]{}, noteID)
+	if _, err := s.Create(note{Text: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || names[0] != "notes.json" || names[1] != "notes.json.seq" {
+		t.Fatalf("files next to the store: %v, want the data and its sequence only", names)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Fatalf("file mode %v, want 0644", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// historyLog es el historial de cambios del store json. Cada entrada nueva
+// viaja en el journal junto con el cambio que registra; al compactar, las
+// pendientes se agregan a <path>.history, un archivo append-only con una
+// entrada json por linea. Las entradas tienen ids crecientes, asi reaplicar
+// una que ya estaba en el archivo no la duplica.
+type historyLog struct {
+	path      string
+	byProduct map[int][]domain.ProductChange
+	last      int                    // id de la ultima entrada
+	pending   []domain.ProductChange // entradas que todavia no estan en el archivo
+}
+
+func newHistoryLog(path string) *historyLog {
+	return &historyLog{path: path, byProduct: map[int][]domain.ProductChange{}}
+}
+
+// load lee el archivo de historial. Una ultima linea incompleta (corte a
+// mitad de una compactacion) se descarta: sus entradas siguen en el journal.
+func (h *historyLog) load() error {
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var valid int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		var change domain.ProductChange
+		if err := json.Unmarshal(line, &change); err != nil {
+			if valid+int64(len(line)) >= int64(len(data))-1 {
+				return os.Truncate(h.path, valid)
+			}
+			return fmt.Errorf("%s: line %d: %w", h.path, n, err)
+		}
+		h.add(change)
+		valid += int64(len(line)) + 1
+	}
+	h.pending = nil
+	return scanner.Err()
+}
+
+// add agrega una entrada al historial en memoria
+func (h *historyLog) add(change domain.ProductChange) {
+	h.byProduct[change.ProductID] = append(h.byProduct[change.ProductID], change)
+	h.pending = append(h.pending, change)
+	h.last = change.ID
+}
+
+// replay agrega una entrada del journal si no estaba en el archivo
+func (h *historyLog) replay(change domain.ProductChange) {
+	if change.ID > h.last {
+		h.add(change)
+	}
+}
+
+// undo quita la ultima entrada agregada
+func (h *historyLog) undo() {
+	change := h.pending[len(h.pending)-1]
+	h.pending = h.pending[:len(h.pending)-1]
+	changes := h.byProduct[change.ProductID]
+	h.byProduct[change.ProductID] = changes[:len(changes)-1]
+	h.last = change.ID - 1
+}
+
+// flush agrega las entradas pendientes al archivo y lo sincroniza a disco
+func (h *historyLog) flush() error {
+	if len(h.pending) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, change := range h.pending {
+		if err := encoder.Encode(change); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	h.pending = nil
+	return nil
+}
+
+// retain deja en el historial solo las entradas para las que keep devuelve
+// true y reescribe el archivo con ellas, incluidas las pendientes
+func (h *historyLog) retain(keep func(change domain.ProductChange) bool) error {
+	kept := []domain.ProductChange{}
+	for _, changes := range h.byProduct {
+		for _, change := range changes {
+			if keep(change) {
+				kept = append(kept, change)
+			}
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].ID < kept[j].ID })
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, change := range kept {
+		if err := encoder.Encode(change); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(h.path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	h.byProduct = map[int][]domain.ProductChange{}
+	for _, change := range kept {
+		h.byProduct[change.ProductID] = append(h.byProduct[change.ProductID], change)
+	}
+	h.pending = nil
+	return nil
+}
+
+// page devuelve el historial de un producto, la entrada mas reciente primero.
+// Limit 0 significa sin limite.
+func (h *historyLog) page(productID, limit, offset int) Page[domain.ProductChange] {
+	changes := h.byProduct[productID]
+	items := []domain.ProductChange{}
+	for i := len(changes) - 1 - offset; i >= 0 && (limit == 0 || len(items) < limit); i-- {
+		items = append(items, changes[i])
+	}
+	return Page[domain.ProductChange]{Items: items, Total: len(changes)}
+}
+
+// History devuelve una pagina del historial de un producto, la entrada mas
+// reciente primero
+func (s *jsonStore) History(productID, limit, offset int) (Page[domain.ProductChange], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.history.page(productID, limit, offset), nil
+}
+
+// resetJournal pasa el historial pendiente a su archivo y vacia el journal,
+// una vez que los productos ya quedaron en el snapshot
+func (s *jsonStore) resetJournal() error {
+	if err := s.history.flush(); err != nil {
+		return err
+	}
+	return s.journal.reset()
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrKeyInProgress indica que otro request con la misma clave todavia se esta
+// procesando
+var ErrKeyInProgress = errors.New("a request with this idempotency key is in progress")
+
+// ErrKeyMismatch indica una clave ya usada con un request distinto
+var ErrKeyMismatch = errors.New("idempotency key was already used with a different request")
+
+// StoredResponse es la respuesta guardada de un request con clave de
+// idempotencia, que se repite tal cual en los reintentos
+type StoredResponse struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   []byte            `json:"body"`
+}
+
+// idempotencyRecord es una clave con el request que la uso y su respuesta;
+// Response es nil mientras el request se procesa
+type idempotencyRecord struct {
+	Key         string          `json:"key"`
+	Fingerprint string          `json:"fingerprint"`
+	Response    *StoredResponse `json:"response"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// IdempotencyStore guarda las respuestas de los requests con clave de
+// idempotencia durante retention. Las claves en proceso solo viven en
+// memoria; las completas se guardan en path, si no esta vacio, para que un
+// reintento despues de reiniciar el servidor tambien se repita.
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	records   map[string]*idempotencyRecord
+	now       func() time.Time
+}
+
+// NewIdempotencyStore abre el store de claves guardado en path (vacio lo deja
+// solo en memoria)
+func NewIdempotencyStore(path string, retention time.Duration) (*IdempotencyStore, error) {
+	s := &IdempotencyStore{path: path, retention: retention, records: map[string]*idempotencyRecord{}, now: time.Now}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []*idempotencyRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Response != nil && !s.expired(r) {
+			s.records[r.Key] = r
+		}
+	}
+	return s, nil
+}
+
+// expired indica si la clave ya paso su retencion; requiere tener tomado mu
+// o no compartir todavia el store
+func (s *IdempotencyStore) expired(r *idempotencyRecord) bool {
+	return !s.now().Before(r.CreatedAt.Add(s.retention))
+}
+
+// Begin toma key para el request identificado por fingerprint. Si la clave ya
+// tiene una respuesta para el mismo request la devuelve para repetirla; si no
+// la tiene, el request se procesa y despues se llama a Complete o a Abort.
+func (s *IdempotencyStore) Begin(key, fingerprint string) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok && !s.expired(r) {
+		switch {
+		case r.Fingerprint != fingerprint:
+			return nil, ErrKeyMismatch
+		case r.Response == nil:
+			return nil, ErrKeyInProgress
+		}
+		return r.Response, nil
+	}
+	s.records[key] = &idempotencyRecord{Key: key, Fingerprint: fingerprint, CreatedAt: s.now().UTC()}
+	return nil, nil
+}
+
+// Complete guarda la respuesta del request que tomo key
+func (s *IdempotencyStore) Complete(key string, response StoredResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[key]
+	if !ok {
+		return nil
+	}
+	r.Response = &response
+	return s.save()
+}
+
+// Abort libera key sin guardar respuesta, asi un reintento vuelve a procesarse
+func (s *IdempotencyStore) Abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok && r.Response == nil {
+		delete(s.records, key)
+	}
+}
+
+// save descarta las claves vencidas y escribe las completas; requiere tener
+// tomado mu
+func (s *IdempotencyStore) save() error {
+	records := make([]*idempotencyRecord, 0, len(s.records))
+	for key, r := range s.records {
+		if s.expired(r) {
+			delete(s.records, key)
+			continue
+		}
+		if r.Response != nil {
+			records = append(records, r)
+		}
+	}
+	if s.path == "" {
+		return nil
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0644)
+}
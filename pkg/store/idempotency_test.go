@@ -0,0 +1,55 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, err := NewIdempotencyStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Now().UTC()
+	s.now = func() time.Time { return clock }
+
+	if stored, err := s.Begin("k1", "f1"); stored != nil || err != nil {
+		t.Fatalf("first begin: %v %v, want nothing", stored, err)
+	}
+	if _, err := s.Begin("k1", "f1"); !errors.Is(err, ErrKeyInProgress) {
+		t.Fatalf("begin while in progress: got %v, want ErrKeyInProgress", err)
+	}
+	if err := s.Complete("k1", StoredResponse{Status: 201, Body: []byte("ok")}); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := s.Begin("k1", "f1"); err != nil || stored == nil || stored.Status != 201 {
+		t.Fatalf("begin after complete: %v %v, want the stored response", stored, err)
+	}
+	if _, err := s.Begin("k1", "f2"); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("begin with another fingerprint: got %v, want ErrKeyMismatch", err)
+	}
+
+	// las claves completas sobreviven a reabrir el store
+	reopened, err := NewIdempotencyStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := reopened.Begin("k1", "f1"); err != nil || stored == nil {
+		t.Fatalf("begin after reopening: %v %v, want the stored response", stored, err)
+	}
+
+	// vencida la retencion la clave vuelve a estar libre
+	clock = clock.Add(time.Hour)
+	if stored, err := s.Begin("k1", "f2"); stored != nil || err != nil {
+		t.Fatalf("begin after retention: %v %v, want nothing", stored, err)
+	}
+
+	// abortar libera una clave en proceso
+	s.Abort("k1")
+	if stored, err := s.Begin("k1", "f3"); stored != nil || err != nil {
+		t.Fatalf("begin after abort: %v %v, want nothing", stored, err)
+	}
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// Operaciones que se registran en el journal
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpBuy    = "buy"
+	// OpUndelete devuelve un producto de la papelera y OpPurge lo elimina
+	OpUndelete = "undelete"
+	OpPurge    = "purge"
+	// OpHistory agrega una entrada al historial del producto
+	OpHistory = "history"
+	// OpTx agrupa las entradas de una transaccion en una sola linea, de modo
+	// que se reaplica completa o, si la linea quedo cortada, no se reaplica
+	OpTx = "tx"
+)
+
+// JournalEntry es una mutacion registrada en el journal. Create, update, buy,
+// delete y undelete guardan el producto como quedo despues del cambio y purge
+// solo el id, asi reaplicar una entrada que ya estaba en el snapshot no
+// cambia el resultado. Un delete sin producto es un borrado definitivo de una
+// version anterior. History guarda la entrada del historial en Change.
+type JournalEntry struct {
+	Op      string                `json:"op"`
+	V       int                   `json:"v,omitempty"` // version del formato de Product
+	At      time.Time             `json:"at"`
+	ID      int                   `json:"id"`
+	Product *domain.Product       `json:"product,omitempty"`
+	Change  *domain.ProductChange `json:"change,omitempty"`
+	Entries []JournalEntry        `json:"entries,omitempty"`
+}
+
+// maxID devuelve el mayor id que menciona la entrada
+func (e JournalEntry) maxID() int {
+	max := e.ID
+	for _, nested := range e.Entries {
+		if id := nested.maxID(); id > max {
+			max = id
+		}
+	}
+	return max
+}
+
+// journal es un archivo append-only con una entrada json por linea
+type journal struct {
+	path    string
+	file    *os.File
+	size    int64
+	pending int // entradas desde la ultima compactacion
+}
+
+// readJournal lee las entradas validas de un journal. Una ultima linea
+// incompleta (corte a mitad de un append) se descarta y se informa en torn;
+// una linea invalida en el medio del archivo es un error.
+func readJournal(path string) (entries []JournalEntry, size int64, torn int64, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, 0, nil
+	}
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// lo que queda sin '\n' es un append que no termino
+			return entries, size, int64(len(raw)), nil
+		}
+		var entry JournalEntry
+		if jsonErr := json.Unmarshal(raw, &entry); jsonErr != nil {
+			if size+int64(len(raw)) == int64(len(data)) {
+				return entries, size, int64(len(raw)), nil
+			}
+			return nil, 0, 0, fmt.Errorf("%s line %d: %w", path, line, jsonErr)
+		}
+		entries = append(entries, entry)
+		size += int64(len(raw))
+	}
+}
+
+// openJournal abre el journal para agregar entradas, descartando todo lo que
+// haya despues de size
+func openJournal(path string, size int64, pending int) (*journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &journal{path: path, file: file, size: size, pending: pending}, nil
+}
+
+// append escribe las entradas y las sincroniza a disco antes de volver
+func (j *journal) append(entries ...JournalEntry) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if _, err := j.file.Write(buf.Bytes()); err != nil {
+		j.rewind()
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		j.rewind()
+		return err
+	}
+	j.size += int64(buf.Len())
+	j.pending += len(entries)
+	return nil
+}
+
+// rewind descarta una escritura a medias para no dejar basura entre entradas
+func (j *journal) rewind() {
+	j.file.Truncate(j.size)
+	j.file.Seek(j.size, io.SeekStart)
+}
+
+// reset vacia el journal despues de compactarlo en el snapshot
+func (j *journal) reset() error {
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	j.size = 0
+	j.pending = 0
+	return j.file.Sync()
+}
+
+// RecoveryReport resume lo que se reconstruyo al abrir un store json
+type RecoveryReport struct {
+	Snapshot  int              `json:"snapshot"`
+	Replayed  map[string]int   `json:"replayed"`
+	Entries   []JournalEntry   `json:"entries"`
+	TornBytes int64            `json:"torn_bytes"`
+	Products  int              `json:"products"`
+	Compacted bool             `json:"compacted"`
+	Migration *MigrationReport `json:"migration"`
+}
+
+// RecoverJSON reconstruye el estado de un store json (snapshot + journal) y
+// devuelve que se reaplico. Si compact es true escribe el resultado en el
+// snapshot y vacia el journal. No debe usarse con el servidor corriendo.
+func RecoverJSON(path string, compact bool) (*RecoveryReport, error) {
+	s := newJSONStore(path)
+	report, size, err := s.recover()
+	if err != nil {
+		return nil, err
+	}
+	if !compact {
+		return report, nil
+	}
+	if s.journal, err = openJournal(s.journalPath(), size, len(report.Entries)); err != nil {
+		return nil, err
+	}
+	defer s.journal.file.Close()
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	report.Compacted = true
+	return report, nil
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// newJournaledStore crea un store json con seedProducts en un directorio
+// temporal y devuelve su path
+func newJournaledStore(t *testing.T, opts ...JSONOption) (ProductStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "products.json")
+	data, err := encodeProducts(seedProducts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+// mutate hace sobre s una compra, una actualizacion, un alta y una baja
+func mutate(t *testing.T, s ProductStore) {
+	t.Helper()
+	if err := s.Buy("A1", 3); err != nil {
+		t.Fatal(err)
+	}
+	p, err := s.GetByID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Name = "Red wine"
+	if err := s.Update(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(domain.Product{Name: "New", Quantity: 4, CodeValue: "D4", Expiration: domain.NewDate(2031, time.May, 1), Price: 700}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertMutated verifica que s tenga el estado que deja mutate
+func assertMutated(t *testing.T, s ProductStore) {
+	t.Helper()
+	live, err := s.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]domain.Product{}
+	for _, p := range live {
+		got[p.Id] = p
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d live products, want 3: %+v", len(got), live)
+	}
+	if got[1].Quantity != 7 || got[1].Version != 2 {
+		t.Errorf("product 1: quantity %d version %d, want 7 and 2", got[1].Quantity, got[1].Version)
+	}
+	if got[2].Name != "Red wine" || got[2].Version != 2 {
+		t.Errorf("product 2: name %q version %d, want Red wine and 2", got[2].Name, got[2].Version)
+	}
+	if got[4].CodeValue != "D4" {
+		t.Errorf("product 4: %+v, want the created D4", got[4])
+	}
+	trash, err := s.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Id != 3 || trash[0].DeletedAt == nil {
+		t.Errorf("trash %+v, want product 3", trash)
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	s, path := newJournaledStore(t)
+	mutate(t, s)
+
+	report, err := RecoverJSON(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Snapshot != 3 || len(report.Entries) != 4 || report.TornBytes != 0 {
+		t.Fatalf("report %+v, want 3 products in the snapshot and 4 entries", report)
+	}
+	for op, n := range map[string]int{OpBuy: 1, OpUpdate: 1, OpCreate: 1, OpDelete: 1} {
+		if report.Replayed[op] != n {
+			t.Errorf("replayed %d %s, want %d", report.Replayed[op], op, n)
+		}
+	}
+
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMutated(t, reopened)
+	created, err := reopened.Create(domain.Product{Name: "Next", Quantity: 1, CodeValue: "E5", Price: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Id != 5 {
+		t.Fatalf("id %d after replay, want 5", created.Id)
+	}
+}
+
+func TestJournalDropsTornLastLine(t *testing.T) {
+	s, path := newJournaledStore(t)
+	mutate(t, s)
+	journal := path + ".journal"
+	valid, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	torn := []byte(`{"op":"update","v":5,"id":1,"product":{"id":1,"name":"Torn`)
+	if err := os.WriteFile(journal, append(append([]byte{}, valid...), torn...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := RecoverJSON(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TornBytes != int64(len(torn)) || len(report.Entries) != 4 {
+		t.Fatalf("report: %d torn bytes and %d entries, want %d and 4", report.TornBytes, len(report.Entries), len(torn))
+	}
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMutated(t, reopened)
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, valid) {
+		t.Fatalf("journal was not truncated to its last complete entry")
+	}
+}
+
+func TestJournalRejectsCorruptMiddleLine(t *testing.T) {
+	s, path := newJournaledStore(t)
+	mutate(t, s)
+	journal := path + ".journal"
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := append([]byte("not json\n"), data...)
+	if err := os.WriteFile(journal, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(path); err == nil {
+		t.Fatal("a corrupt line before valid entries must fail instead of dropping them")
+	}
+}
+
+func TestJournalReplayAfterCompaction(t *testing.T) {
+	s, path := newJournaledStore(t, WithCompaction(2, 0))
+	mutate(t, s)
+	assertMutated(t, s)
+
+	entries, _, _, err := readJournal(path + ".journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("%d entries left in the journal after compacting every 2", len(entries))
+	}
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMutated(t, reopened)
+}
+
+func TestJournalReplayIsIdempotentAfterInterruptedCompaction(t *testing.T) {
+	s, path := newJournaledStore(t)
+	mutate(t, s)
+	journal := path + ".journal"
+	pending, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecoverJSON(path, true); err != nil {
+		t.Fatal(err)
+	}
+	// el snapshot ya tiene los cambios pero el journal no se llego a vaciar
+	if err := os.WriteFile(journal, pending, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMutated(t, reopened)
+}
@@ -1,166 +1,537 @@
 package store
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"os"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/mceciabate/web-server/internal/domain"
 )
 
-type Store interface {
-	GetAll() ([]domain.Product, error)
-	GetByID(id int) (domain.Product, error)
-	SearchPriceGt(price float64) []domain.Product
-	Create(product domain.Product) error
-	Update(product domain.Product) error
-	Delete(id int) error
-	GetByCodeValue(code string) (domain.Product, error)
-	Buy(code string, quantity int) error
-	saveProducts(products []domain.Product) error
-	loadProducts() ([]domain.Product, error)
-}
-
+// jsonStore mantiene los productos en memoria, indexados por id, code_value y
+// precio; los borrados quedan aparte en trash hasta que se purgan. Cada
+// cambio se agrega primero a un journal (<path>.journal) y se sincroniza a
+// disco; cada tanto el journal se compacta reescribiendo el archivo json de
+// forma atomica. Al abrir el store se lee el archivo y se
+// reaplica el journal. Las escrituras se serializan con mu.
 type jsonStore struct {
+	mu         sync.RWMutex
 	pathToFile string
+
+	journal         *journal
+	compactEvery    int
+	compactInterval time.Duration
+
+	byID    map[int]domain.Product
+	order   []int          // ids en el orden del archivo
+	byCode  map[string]int // code_value -> id
+	byAlias map[string]int // code_value anterior a un renombre -> id
+	byPrice []int          // ids ordenados por precio ascendente
+	trash   map[int]domain.Product
+	history *historyLog
+	seq     *Sequence
 }
 
-// loadProducts carga los productos desde un archivo json
-func (s *jsonStore) loadProducts() ([]domain.Product, error) {
-	var products []domain.Product
+// loadProducts carga los productos desde un archivo json, migrandolos a la
+// version actual si el archivo es de una version anterior
+func (s *jsonStore) loadProducts() ([]domain.Product, *MigrationReport, error) {
 	file, err := os.ReadFile(s.pathToFile)
 	if err != nil {
-		return nil, err
-	}
-	err = json.Unmarshal([]byte(file), &products)
-	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return products, nil
+	return decodeProducts(file)
 }
 
-// saveProducts guarda los productos en un archivo json
+// saveProducts guarda los productos en un archivo json con la version actual
 func (s *jsonStore) saveProducts(products []domain.Product) error {
-	bytes, err := json.Marshal(products)
+	bytes, err := encodeProducts(products)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.pathToFile, bytes, 0644)
+	return writeFileAtomic(s.pathToFile, bytes, 0644)
 }
 
-// NewJsonStore crea un nuevo store de products
-func NewStore(path string) Store {
-	return &jsonStore{
-		pathToFile: path,
+// JSONOption configura un store json
+type JSONOption func(*jsonStore)
+
+// WithCompaction define cada cuantas entradas del journal y/o cada cuanto tiempo
+// se compacta el journal en el archivo json. Un valor cero desactiva ese criterio.
+func WithCompaction(every int, interval time.Duration) JSONOption {
+	return func(s *jsonStore) {
+		s.compactEvery = every
+		s.compactInterval = interval
 	}
 }
 
-// GetAll devuelve todos los productos
-func (s *jsonStore) GetAll() ([]domain.Product, error) {
-	products, err := s.loadProducts()
+// NewStore crea un nuevo store de products cargando el archivo en memoria y
+// reaplicando el journal. Si el archivo es de una version anterior del formato
+// se migra y se reescribe, dejando el original en <path>.v<version>.bak. Falla si el archivo tiene ids repetidos. Los ids
+// nuevos salen de la secuencia guardada en <path>.seq.
+func NewStore(path string, opts ...JSONOption) (ProductStore, error) {
+	s := newJSONStore(path)
+	s.compactEvery = 1000
+	for _, opt := range opts {
+		opt(s)
+	}
+	report, size, err := s.recover()
 	if err != nil {
 		return nil, err
 	}
-	return products, nil
+	if s.journal, err = openJournal(s.journalPath(), size, len(report.Entries)); err != nil {
+		return nil, err
+	}
+	if report.Migration.Upgraded() {
+		// el archivo se reescribe en la version actual guardando el original
+		if err := keepOriginal(path, report.Migration.From); err != nil {
+			return nil, err
+		}
+		if err := s.saveProducts(s.all()); err != nil {
+			return nil, err
+		}
+		if err := s.resetJournal(); err != nil {
+			return nil, err
+		}
+	}
+	if s.compactInterval > 0 {
+		go s.compactLoop()
+	}
+	return s, nil
 }
 
-// GetById devuelve un producto por su id
-func (s *jsonStore) GetByID(id int) (domain.Product, error) {
-	products, err := s.loadProducts()
-	if err != nil {
-		return domain.Product{}, err
+func newJSONStore(path string) *jsonStore {
+	return &jsonStore{
+		pathToFile: path,
+		byID:       map[int]domain.Product{},
+		byCode:     map[string]int{},
+		byAlias:    map[string]int{},
+		trash:      map[int]domain.Product{},
+		history:    newHistoryLog(path + ".history"),
 	}
-	for _, product := range products {
-		if product.Id == id {
-			return product, nil
+}
+
+// load reemplaza el contenido de los indices por los productos dados
+func (s *jsonStore) load(products []domain.Product) {
+	s.byID = map[int]domain.Product{}
+	s.byCode = map[string]int{}
+	s.byAlias = map[string]int{}
+	s.trash = map[int]domain.Product{}
+	s.order = nil
+	s.byPrice = nil
+	for _, p := range products {
+		if p.DeletedAt != nil {
+			s.trash[p.Id] = p
+			continue
 		}
+		s.insert(p)
 	}
-	return domain.Product{}, errors.New("product not found")
 }
 
-// Create agrega un nuevo producto
-func (s *jsonStore) Create(product domain.Product) error {
-	products, err := s.loadProducts()
+func (s *jsonStore) journalPath() string {
+	return s.pathToFile + ".journal"
+}
+
+// recover carga el snapshot, reaplica el journal y abre la secuencia de ids.
+// Devuelve el reporte y el tamaño valido del journal.
+func (s *jsonStore) recover() (*RecoveryReport, int64, error) {
+	products, migration, err := s.loadProducts()
 	if err != nil {
-		return err
+		return nil, 0, fmt.Errorf("%s: %w", s.pathToFile, err)
+	}
+	s.load(products)
+	if err := s.history.load(); err != nil {
+		return nil, 0, err
+	}
+	entries, size, torn, err := readJournal(s.journalPath())
+	if err != nil {
+		return nil, 0, err
 	}
-	product.Id = len(products) + 1
-	products = append(products, product)
-	return s.saveProducts(products)
+	report := &RecoveryReport{
+		Snapshot:  len(products),
+		Replayed:  map[string]int{},
+		Entries:   entries,
+		TornBytes: torn,
+		Migration: migration,
+	}
+	floor := maxID(products, productID)
+	for _, entry := range entries {
+		s.apply(entry)
+		report.Replayed[entry.Op]++
+		for _, nested := range entry.Entries {
+			report.Replayed[nested.Op]++
+		}
+		if id := entry.maxID(); id > floor {
+			floor = id
+		}
+	}
+	report.Products = len(s.order)
+	s.seq, err = NewFileSequence(s.pathToFile+".seq", floor)
+	if err != nil {
+		return nil, 0, err
+	}
+	return report, size, nil
 }
 
-// Update actualiza un producto
-func (s *jsonStore) Update(product domain.Product) error {
-	products, err := s.loadProducts()
-	if err != nil {
+// apply reaplica una entrada del journal sobre los indices
+func (s *jsonStore) apply(entry JournalEntry) {
+	_, exists := s.byID[entry.ID]
+	switch {
+	case entry.Op == OpTx:
+		for _, nested := range entry.Entries {
+			s.apply(nested)
+		}
+	case entry.Op == OpDelete || entry.Op == OpPurge:
+		if exists {
+			s.remove(entry.ID)
+		}
+		delete(s.trash, entry.ID)
+		if entry.Op == OpDelete && entry.Product != nil {
+			s.trash[entry.ID] = *entry.Product
+		}
+	case entry.Op == OpHistory:
+		if entry.Change != nil {
+			s.history.replay(*entry.Change)
+		}
+	case entry.Product == nil:
+	case entry.Op == OpUndelete:
+		delete(s.trash, entry.ID)
+		if exists {
+			s.replace(*entry.Product)
+		} else {
+			s.insert(*entry.Product)
+		}
+	case exists:
+		s.replace(*entry.Product)
+	default:
+		s.insert(*entry.Product)
+	}
+}
+
+// compact escribe el estado actual en el archivo json y vacia el journal.
+// Si se corta entre los dos pasos el journal se vuelve a aplicar sin efecto.
+func (s *jsonStore) compact() error {
+	if s.journal.pending == 0 {
+		return nil
+	}
+	if err := s.saveProducts(s.all()); err != nil {
 		return err
 	}
-	for i, p := range products {
-		if p.Id == product.Id {
-			products[i] = product
-			return s.saveProducts(products)
+	return s.resetJournal()
+}
+
+// compactLoop compacta el journal cada compactInterval. Si falla lo informa
+// y lo vuelve a intentar en el proximo intervalo; mientras tanto el journal
+// sigue creciendo.
+func (s *jsonStore) compactLoop() {
+	ticker := time.NewTicker(s.compactInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		if err := s.compact(); err != nil {
+			log.Printf("compacting %s journal (%d pending entries): %v", s.pathToFile, s.journal.pending, err)
 		}
+		s.mu.Unlock()
 	}
-	return errors.New("product not found")
 }
 
-// DeleteOne elimina un producto
-func (s *jsonStore) Delete(id int) error {
-	products, err := s.loadProducts()
-	if err != nil {
-		return err
+// productID devuelve el campo id de un producto
+func productID(p *domain.Product) *int {
+	return &p.Id
+}
+
+// list devuelve los productos en el orden del archivo
+func (s *jsonStore) list() []domain.Product {
+	products := make([]domain.Product, 0, len(s.order))
+	for _, id := range s.order {
+		products = append(products, s.byID[id])
+	}
+	return products
+}
+
+// all devuelve los productos en el orden del archivo seguidos de la papelera
+// ordenada por id, que es lo que se guarda en el archivo
+func (s *jsonStore) all() []domain.Product {
+	return append(s.list(), s.trashed()...)
+}
+
+// trashed devuelve la papelera ordenada por id
+func (s *jsonStore) trashed() []domain.Product {
+	products := make([]domain.Product, 0, len(s.trash))
+	for _, p := range s.trash {
+		products = append(products, p)
 	}
-	for i, p := range products {
-		if p.Id == id {
-			products = append(products[:i], products[i+1:]...)
-			return s.saveProducts(products)
+	sort.Slice(products, func(i, j int) bool { return products[i].Id < products[j].Id })
+	return products
+}
+
+// sortTrash ordena productos borrados del mas reciente al mas antiguo
+func sortTrash(products []domain.Product) {
+	sort.SliceStable(products, func(i, j int) bool {
+		return products[i].DeletedAt.After(*products[j].DeletedAt)
+	})
+}
+
+// pricePos devuelve la posicion en byPrice del primer producto con precio
+// >= price, o > price si strict es true
+func (s *jsonStore) pricePos(price domain.Amount, strict bool) int {
+	return sort.Search(len(s.byPrice), func(i int) bool {
+		p := s.byID[s.byPrice[i]].Price
+		return p > price || (!strict && p == price)
+	})
+}
+
+// indexPrice agrega el id al indice de precios manteniendo el orden
+func (s *jsonStore) indexPrice(p domain.Product) {
+	i := sort.Search(len(s.byPrice), func(i int) bool {
+		return s.byID[s.byPrice[i]].Price >= p.Price
+	})
+	s.byPrice = append(s.byPrice, 0)
+	copy(s.byPrice[i+1:], s.byPrice[i:])
+	s.byPrice[i] = p.Id
+}
+
+// unindexPrice quita el id del indice de precios; p debe tener el precio indexado
+func (s *jsonStore) unindexPrice(p domain.Product) {
+	i := sort.Search(len(s.byPrice), func(i int) bool {
+		return s.byID[s.byPrice[i]].Price >= p.Price
+	})
+	for ; i < len(s.byPrice); i++ {
+		if s.byPrice[i] == p.Id {
+			s.byPrice = append(s.byPrice[:i], s.byPrice[i+1:]...)
+			return
 		}
 	}
-	return errors.New("product not found")
 }
 
-// SearchPriceGt busca productos por precio mayor o igual que el precio dado
-func (s *jsonStore) SearchPriceGt(price float64) []domain.Product {
-	var productsFound []domain.Product
-	products, err := s.loadProducts()
-	if err != nil {
-		return []domain.Product{}
+// insertAt agrega un producto a los indices en la posicion pos de order
+func (s *jsonStore) insertAt(pos int, p domain.Product) {
+	s.byID[p.Id] = p
+	s.order = append(s.order, 0)
+	copy(s.order[pos+1:], s.order[pos:])
+	s.order[pos] = p.Id
+	if _, taken := s.byCode[p.CodeValue]; !taken {
+		s.byCode[p.CodeValue] = p.Id
 	}
-	for _, product := range products {
-		if product.Price > price {
-			productsFound = append(productsFound, product)
+	s.indexAliases(p)
+	s.indexPrice(p)
+}
+
+// indexAliases agrega los alias del producto al indice
+func (s *jsonStore) indexAliases(p domain.Product) {
+	for _, alias := range p.Aliases {
+		if _, taken := s.byAlias[alias]; !taken {
+			s.byAlias[alias] = p.Id
 		}
 	}
-	return productsFound
 }
 
-// GetByCodeValue devuelve un producto por su code_value
-func (s *jsonStore) GetByCodeValue(code string) (domain.Product, error) {
-	products, err := s.loadProducts()
-	if err != nil {
-		return domain.Product{}, err
+// unindexAliases quita los alias del producto del indice
+func (s *jsonStore) unindexAliases(p domain.Product) {
+	for _, alias := range p.Aliases {
+		if s.byAlias[alias] == p.Id {
+			delete(s.byAlias, alias)
+		}
 	}
-	for _, product := range products {
-		if product.CodeValue == code {
-			return product, nil
+}
+
+// insert agrega un producto al final
+func (s *jsonStore) insert(p domain.Product) {
+	s.insertAt(len(s.order), p)
+}
+
+// remove quita un producto de los indices y devuelve su posicion en order
+func (s *jsonStore) remove(id int) int {
+	p := s.byID[id]
+	s.unindexPrice(p)
+	if s.byCode[p.CodeValue] == id {
+		delete(s.byCode, p.CodeValue)
+	}
+	s.unindexAliases(p)
+	delete(s.byID, id)
+	for i, other := range s.order {
+		if other == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			return i
+		}
+	}
+	return len(s.order)
+}
+
+// replace cambia los datos de un producto existente manteniendo su posicion
+func (s *jsonStore) replace(p domain.Product) {
+	old := s.byID[p.Id]
+	if old.Price != p.Price {
+		s.unindexPrice(old)
+		defer s.indexPrice(p)
+	}
+	if old.CodeValue != p.CodeValue {
+		if s.byCode[old.CodeValue] == p.Id {
+			delete(s.byCode, old.CodeValue)
+		}
+		if _, taken := s.byCode[p.CodeValue]; !taken {
+			s.byCode[p.CodeValue] = p.Id
 		}
 	}
-	return domain.Product{}, errors.New("product not found")
+	s.unindexAliases(old)
+	s.indexAliases(p)
+	s.byID[p.Id] = p
+}
+
+// GetAll devuelve todos los productos
+func (s *jsonStore) GetAll() ([]domain.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list(), nil
+}
+
+// GetById devuelve un producto por su id
+func (s *jsonStore) GetByID(id int) (domain.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getByID(id)
+}
+
+// Create agrega un nuevo producto
+func (s *jsonStore) Create(product domain.Product) (created domain.Product, err error) {
+	err = s.inTx(func(tx *jsonTx) error {
+		created, err = tx.Create(product)
+		return err
+	})
+	return created, err
+}
+
+// Update actualiza un producto
+func (s *jsonStore) Update(product domain.Product) error {
+	return s.inTx(func(tx *jsonTx) error { return tx.Update(product) })
+}
+
+// Delete manda un producto a la papelera
+func (s *jsonStore) Delete(id int) error {
+	return s.inTx(func(tx *jsonTx) error { return tx.Delete(id) })
+}
+
+// Trash devuelve los productos borrados, el mas reciente primero
+func (s *jsonStore) Trash() ([]domain.Product, error) {
+	s.mu.RLock()
+	products := s.trashed()
+	s.mu.RUnlock()
+	sortTrash(products)
+	return products, nil
+}
+
+// Undelete devuelve un producto de la papelera al catalogo
+func (s *jsonStore) Undelete(id int) (restored domain.Product, err error) {
+	err = s.inTx(func(tx *jsonTx) error {
+		restored, err = tx.Undelete(id)
+		return err
+	})
+	return restored, err
+}
+
+// Purge elimina definitivamente los productos borrados antes de before
+// y conserva su historial
+func (s *jsonStore) Purge(before time.Time) (purged int, err error) {
+	err = s.inTx(func(tx *jsonTx) error {
+		for _, p := range s.trashed() {
+			if p.DeletedAt.Before(before) {
+				tx.purge(p.Id)
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// Search devuelve los productos que cumplen los criterios, ordenados por id.
+// Si hay rango de precio solo se recorren los productos dentro del rango.
+func (s *jsonStore) Search(criteria SearchCriteria) ([]domain.Product, error) {
+	s.mu.RLock()
+	candidates := s.order
+	if criteria.PriceMin != nil || criteria.PriceMax != nil {
+		from, to := 0, len(s.byPrice)
+		if criteria.PriceMin != nil {
+			from = s.pricePos(*criteria.PriceMin, false)
+		}
+		if criteria.PriceMax != nil {
+			to = s.pricePos(*criteria.PriceMax, true)
+		}
+		candidates = nil
+		if from < to {
+			candidates = s.byPrice[from:to]
+		}
+	}
+	found := []domain.Product{}
+	for _, id := range candidates {
+		if p := s.byID[id]; criteria.match(p) {
+			found = append(found, p)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(found, func(i, j int) bool { return found[i].Id < found[j].Id })
+	return found, nil
+}
+
+// GetByCodeValue devuelve un producto por su code_value
+func (s *jsonStore) GetByCodeValue(code string) (domain.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getByCodeValue(code)
 }
 
 // Setea la cantidad de producto según la compra
-// TODO QUE PASA CON EL HAPPY PATH
 func (s *jsonStore) Buy(code string, quantity int) error {
-	products, err := s.loadProducts()
+	return s.inTx(func(tx *jsonTx) error { return tx.Buy(code, quantity) })
+}
+
+// getByID busca un producto por id; requiere tener tomado mu
+func (s *jsonStore) getByID(id int) (domain.Product, error) {
+	product, ok := s.byID[id]
+	if !ok {
+		return domain.Product{}, errors.New("product not found")
+	}
+	return product, nil
+}
+
+// getByCodeValue busca un producto por code_value o, si ninguno lo tiene,
+// por alias; requiere tener tomado mu
+func (s *jsonStore) getByCodeValue(code string) (domain.Product, error) {
+	id, ok := s.codeOwner(code)
+	if !ok {
+		return domain.Product{}, errors.New("product not found")
+	}
+	return s.byID[id], nil
+}
+
+// codeOwner devuelve el id del producto que usa code como code_value o como
+// alias; requiere tener tomado mu
+func (s *jsonStore) codeOwner(code string) (int, bool) {
+	if id, ok := s.byCode[code]; ok {
+		return id, true
+	}
+	id, ok := s.byAlias[code]
+	return id, ok
+}
+
+// List devuelve una pagina de productos filtrada y ordenada
+func (s *jsonStore) List(q Query) (Page[domain.Product], error) {
+	filters, err := q.bind()
 	if err != nil {
-		return err
+		return Page[domain.Product]{}, err
 	}
-	for i, p := range products {
-		if p.CodeValue == code && p.Quantity >= quantity {
-			products[i].Quantity -= quantity
-			return s.saveProducts(products)
+	s.mu.RLock()
+	found := []domain.Product{}
+	for _, id := range s.order {
+		if p := s.byID[id]; matches(p, filters) {
+			found = append(found, p)
 		}
 	}
-	return errors.New("No se puede ejecutar la compra")
-
+	s.mu.RUnlock()
+	if len(q.Sort) > 0 {
+		sortProducts(found, q.Sort)
+	}
+	return Page[domain.Product]{Items: paginate(found, q.Limit, q.Offset), Total: len(found)}, nil
 }
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// scanStore es la implementacion anterior de jsonStore: parsea el archivo
+// completo en cada llamada y recorre la lista. Se mantiene solo para comparar.
+type scanStore struct {
+	pathToFile string
+}
+
+func (s *scanStore) load() ([]domain.Product, error) {
+	var products []domain.Product
+	file, err := os.ReadFile(s.pathToFile)
+	if err != nil {
+		return nil, err
+	}
+	return products, json.Unmarshal(file, &products)
+}
+
+func (s *scanStore) GetByID(id int) (domain.Product, error) {
+	products, err := s.load()
+	if err != nil {
+		return domain.Product{}, err
+	}
+	for _, p := range products {
+		if p.Id == id {
+			return p, nil
+		}
+	}
+	return domain.Product{}, errors.New("product not found")
+}
+
+func (s *scanStore) GetByCodeValue(code string) (domain.Product, error) {
+	products, err := s.load()
+	if err != nil {
+		return domain.Product{}, err
+	}
+	for _, p := range products {
+		if p.CodeValue == code {
+			return p, nil
+		}
+	}
+	return domain.Product{}, errors.New("product not found")
+}
+
+func (s *scanStore) Search(criteria SearchCriteria) ([]domain.Product, error) {
+	var found []domain.Product
+	products, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		if criteria.match(p) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+func (s *scanStore) Buy(code string, quantity int) error {
+	products, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, p := range products {
+		if p.CodeValue == code && p.Quantity >= quantity {
+			products[i].Quantity -= quantity
+			bytes, err := json.Marshal(products)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(s.pathToFile, bytes, 0644)
+		}
+	}
+	return errors.New("No se puede ejecutar la compra")
+}
+
+// writeCatalog genera un products.json con n productos
+func writeCatalog(b *testing.B, n int) string {
+	b.Helper()
+	products := make([]domain.Product, n)
+	for i := range products {
+		products[i] = domain.Product{
+			Id:          i + 1,
+			Name:        fmt.Sprintf("Product %d", i+1),
+			Quantity:    1 << 30,
+			CodeValue:   fmt.Sprintf("C%06d", i+1),
+			IsPublished: i%2 == 0,
+			Expiration:  domain.NewDate(2030, time.December, 15),
+			Price:       domain.Amount((i * 7919) % 100000),
+		}
+	}
+	data, err := json.Marshal(products)
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), "products.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+type benchStore interface {
+	GetByID(id int) (domain.Product, error)
+	GetByCodeValue(code string) (domain.Product, error)
+	Search(criteria SearchCriteria) ([]domain.Product, error)
+	Buy(code string, quantity int) error
+}
+
+func benchStores(b *testing.B, n int, run func(b *testing.B, s benchStore)) {
+	for _, impl := range []string{"scan", "indexed"} {
+		b.Run(fmt.Sprintf("%s/n=%d", impl, n), func(b *testing.B) {
+			path := writeCatalog(b, n)
+			var s benchStore = &scanStore{pathToFile: path}
+			if impl == "indexed" {
+				indexed, err := NewStore(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				s = indexed
+			}
+			b.ResetTimer()
+			run(b, s)
+		})
+	}
+}
+
+func BenchmarkGetByID(b *testing.B) {
+	for _, n := range []int{1000, 20000} {
+		benchStores(b, n, func(b *testing.B, s benchStore) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.GetByID(i%n + 1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetByCodeValue(b *testing.B) {
+	for _, n := range []int{1000, 20000} {
+		benchStores(b, n, func(b *testing.B, s benchStore) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.GetByCodeValue(fmt.Sprintf("C%06d", i%n+1)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSearchPriceRange(b *testing.B) {
+	min := domain.Amount(99000)
+	for _, n := range []int{1000, 20000} {
+		benchStores(b, n, func(b *testing.B, s benchStore) {
+			for i := 0; i < b.N; i++ {
+				// el rango deja afuera ~99% del catalogo
+				s.Search(SearchCriteria{PriceMin: &min})
+			}
+		})
+	}
+}
+
+func BenchmarkBuy(b *testing.B) {
+	for _, n := range []int{1000, 20000} {
+		benchStores(b, n, func(b *testing.B, s benchStore) {
+			for i := 0; i < b.N; i++ {
+				if err := s.Buy(fmt.Sprintf("C%06d", i%n+1), 1); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Envelope es el formato versionado de los archivos de datos. Los archivos
+// anteriores a los sobres (un array json pelado) se consideran version 1.
+type Envelope struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Migration lleva los datos de la version From a la From+1
+type Migration struct {
+	From        int
+	Description string
+	// Record transforma un registro en su lugar. Puede ser nil si el paso
+	// solo cambia el formato del archivo.
+	Record func(record map[string]any) error
+}
+
+// Migrator conoce la version actual de un tipo de archivo y los pasos para
+// llegar a ella desde cualquier version anterior
+type Migrator struct {
+	current int
+	steps   map[int]Migration
+}
+
+// NewMigrator crea un migrator. Debe haber un paso por cada version desde 1
+// hasta current-1; si falta alguno es un error de programacion y entra en panic.
+func NewMigrator(current int, steps ...Migration) *Migrator {
+	m := &Migrator{current: current, steps: map[int]Migration{}}
+	for _, step := range steps {
+		m.steps[step.From] = step
+	}
+	for v := 1; v < current; v++ {
+		if _, ok := m.steps[v]; !ok {
+			panic(fmt.Sprintf("store: missing migration from version %d", v))
+		}
+	}
+	return m
+}
+
+// Current devuelve la version que escribe el migrator
+func (m *Migrator) Current() int {
+	return m.current
+}
+
+// RecordChange muestra un registro antes y despues de un paso
+type RecordChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// StepReport resume un paso de migracion
+type StepReport struct {
+	From        int            `json:"from"`
+	To          int            `json:"to"`
+	Description string         `json:"description"`
+	Changed     int            `json:"changed"`
+	Samples     []RecordChange `json:"samples,omitempty"`
+}
+
+// MigrationReport resume la migracion de un archivo
+type MigrationReport struct {
+	From    int          `json:"from"`
+	To      int          `json:"to"`
+	Records int          `json:"records"`
+	Steps   []StepReport `json:"steps"`
+}
+
+// Upgraded indica si el archivo estaba en una version anterior
+func (r *MigrationReport) Upgraded() bool {
+	return r.From < r.To
+}
+
+// maxSamples es la cantidad de registros de ejemplo que guarda cada paso
+const maxSamples = 3
+
+// Decode lee un archivo en cualquier version soportada y devuelve su array de
+// registros ya llevado a la version actual, junto con lo que se cambio.
+func (m *Migrator) Decode(data []byte) (json.RawMessage, *MigrationReport, error) {
+	version, payload, err := m.open(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	report := &MigrationReport{From: version, To: m.current, Steps: []StepReport{}}
+	if version == m.current {
+		return payload, report, nil
+	}
+	records, err := decodeRecords(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Records = len(records)
+	for v := version; v < m.current; v++ {
+		step := m.steps[v]
+		stepReport := StepReport{From: v, To: v + 1, Description: step.Description}
+		if step.Record != nil {
+			for i, record := range records {
+				before, _ := json.Marshal(record)
+				if err := step.Record(record); err != nil {
+					return nil, nil, fmt.Errorf("migration %d->%d, record %d: %w", v, v+1, i+1, err)
+				}
+				after, _ := json.Marshal(record)
+				if !bytes.Equal(before, after) {
+					stepReport.Changed++
+					if len(stepReport.Samples) < maxSamples {
+						stepReport.Samples = append(stepReport.Samples, RecordChange{Before: before, After: after})
+					}
+				}
+			}
+		}
+		report.Steps = append(report.Steps, stepReport)
+	}
+	migrated, err := json.Marshal(records)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrated, report, nil
+}
+
+// MigrateRecord lleva un registro suelto (por ejemplo de un journal) de la
+// version dada a la actual
+func (m *Migrator) MigrateRecord(version int, raw json.RawMessage) (json.RawMessage, error) {
+	if version > m.current {
+		return nil, fmt.Errorf("record version %d is newer than supported version %d", version, m.current)
+	}
+	if version == m.current {
+		return raw, nil
+	}
+	records, err := decodeRecords(append(append([]byte("["), raw...), ']'))
+	if err != nil {
+		return nil, err
+	}
+	for v := version; v < m.current; v++ {
+		if record := m.steps[v].Record; record != nil {
+			if err := record(records[0]); err != nil {
+				return nil, fmt.Errorf("migration %d->%d: %w", v, v+1, err)
+			}
+		}
+	}
+	return json.Marshal(records[0])
+}
+
+// Encode serializa los registros en un sobre con la version actual
+func (m *Migrator) Encode(items any) ([]byte, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{Version: m.current, Data: data})
+}
+
+// open detecta la version del archivo y devuelve su array de registros
+func (m *Migrator) open(data []byte) (int, json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return 1, trimmed, nil
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(trimmed, &envelope); err != nil {
+		return 0, nil, err
+	}
+	switch {
+	case envelope.Version < 1:
+		return 0, nil, errors.New("missing or invalid version in data file")
+	case envelope.Version > m.current:
+		return 0, nil, fmt.Errorf("data file version %d is newer than supported version %d", envelope.Version, m.current)
+	}
+	return envelope.Version, envelope.Data, nil
+}
+
+// decodeRecords parsea un array de objetos conservando los numeros tal cual
+func decodeRecords(payload json.RawMessage) ([]map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var records []map[string]any
+	if err := decoder.Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// legacyProducts es un products.json de la version 1: un array sin sobre,
+// sin version de producto, con fechas dd/mm/yyyy y sin moneda
+const legacyProducts = `[
+	{"id":1,"name":"Oil","quantity":10,"code_value":"A1","is_published":true,"expiration":"15/12/2021","price":12.5},
+	{"id":2,"name":"Wine","quantity":5,"code_value":"B2","is_published":false,"expiration":"01/02/2022","price":7}
+]`
+
+func writeLegacy(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "products.json")
+	if err := os.WriteFile(path, []byte(legacyProducts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMigrateProductsFileStepsToCurrentVersion(t *testing.T) {
+	path := writeLegacy(t)
+
+	report, err := MigrateProductsFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != 1 || report.To != ProductsSchemaVersion || report.Records != 2 {
+		t.Fatalf("report: from %d to %d records %d, want 1, %d and 2", report.From, report.To, report.Records, ProductsSchemaVersion)
+	}
+	if len(report.Steps) != ProductsSchemaVersion-1 {
+		t.Fatalf("report has %d steps, want %d", len(report.Steps), ProductsSchemaVersion-1)
+	}
+	for i, step := range report.Steps {
+		if step.From != i+1 || step.To != i+2 || step.Description == "" {
+			t.Fatalf("step %d: %+v", i, step)
+		}
+	}
+	// el primer paso solo cambia el formato; los demas tocan los dos registros
+	if report.Steps[0].Changed != 0 {
+		t.Fatalf("step 1->2 changed %d records, want 0", report.Steps[0].Changed)
+	}
+	for _, step := range report.Steps[1:] {
+		if step.Changed != 2 || len(step.Samples) != 2 {
+			t.Fatalf("step %d->%d: changed %d with %d samples, want 2 and 2", step.From, step.To, step.Changed, len(step.Samples))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("migrated file is not an envelope: %v", err)
+	}
+	if envelope.Version != ProductsSchemaVersion {
+		t.Fatalf("migrated file version %d, want %d", envelope.Version, ProductsSchemaVersion)
+	}
+	var products []domain.Product
+	if err := json.Unmarshal(envelope.Data, &products); err != nil {
+		t.Fatal(err)
+	}
+	oil := products[0]
+	if oil.Version != 1 || oil.Expiration.String() != "2021-12-15" || oil.Currency != domain.BaseCurrency || oil.Price != 1250 {
+		t.Fatalf("migrated product: %+v", oil)
+	}
+
+	original, err := os.ReadFile(backupPath(path, 1))
+	if err != nil {
+		t.Fatalf("migration must keep a copy of the original: %v", err)
+	}
+	if string(original) != legacyProducts {
+		t.Fatal("the copy of the original differs from the legacy file")
+	}
+
+	// un archivo ya migrado no cambia
+	again, err := MigrateProductsFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Upgraded() || len(again.Steps) != 0 {
+		t.Fatalf("second migration: %+v, want no steps", again)
+	}
+}
+
+func TestMigrateProductsFileDryRun(t *testing.T) {
+	path := writeLegacy(t)
+
+	report, err := MigrateProductsFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Upgraded() || len(report.Steps) != ProductsSchemaVersion-1 {
+		t.Fatalf("dry run report: %+v", report)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != legacyProducts {
+		t.Fatal("dry run must not change the file")
+	}
+	if _, err := os.Stat(backupPath(path, 1)); !os.IsNotExist(err) {
+		t.Fatalf("dry run must not write a backup, stat: %v", err)
+	}
+}
+
+func TestMigratorRejectsNewerVersion(t *testing.T) {
+	newer, _ := json.Marshal(Envelope{Version: ProductsSchemaVersion + 1, Data: json.RawMessage("[]")})
+	if _, _, err := productMigrations.Decode(newer); err == nil {
+		t.Fatal("a file newer than the supported version must fail")
+	}
+	if _, _, err := productMigrations.Decode([]byte(`{"data":[]}`)); err == nil {
+		t.Fatal("an envelope without version must fail")
+	}
+}
+
+func TestMigrateRecord(t *testing.T) {
+	raw := json.RawMessage(`{"id":1,"name":"Oil","expiration":"15/12/2021","price":0.999}`)
+	migrated, err := productMigrations.MigrateRecord(1, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"currency":"ARS","expiration":"2021-12-15","id":1,"name":"Oil","price":1.00,"version":1}`
+	if !bytes.Equal(migrated, []byte(want)) {
+		t.Fatalf("migrated record:\n%s\nwant\n%s", migrated, want)
+	}
+}
+
+func TestNewMigratorPanicsOnMissingStep(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a missing step must panic")
+		}
+	}()
+	NewMigrator(3, Migration{From: 1})
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// ProductsSchemaVersion es la version actual del formato de products.json
+const ProductsSchemaVersion = 5
+
+// productMigrations tiene un paso por cada cambio del formato de productos.
+// Para cambiar domain.Product se sube ProductsSchemaVersion y se agrega el
+// paso que lleva los registros viejos al formato nuevo.
+var productMigrations = NewMigrator(ProductsSchemaVersion,
+	Migration{From: 1, Description: "wrap bare product array in a versioned envelope"},
+	Migration{From: 2, Description: "add product version for optimistic concurrency", Record: func(record map[string]any) error {
+		if _, ok := record["version"]; !ok {
+			record["version"] = 1
+		}
+		return nil
+	}},
+	Migration{From: 3, Description: "store expiration as an ISO 8601 date (yyyy-mm-dd)", Record: func(record map[string]any) error {
+		value, _ := record["expiration"].(string)
+		if value == "" {
+			return nil
+		}
+		date, err := domain.ParseDate(value)
+		if err != nil {
+			return err
+		}
+		record["expiration"] = date.String()
+		return nil
+	}},
+	Migration{From: 4, Description: "add the price currency (ARS) and round the price to cents", Record: func(record map[string]any) error {
+		if _, ok := record["currency"]; !ok {
+			record["currency"] = string(domain.BaseCurrency)
+		}
+		return roundPrice(record)
+	}},
+)
+
+// roundPrice redondea a centavos un precio guardado como float, igual que
+// sqlitePrices, para que domain.Amount lo acepte
+func roundPrice(record map[string]any) error {
+	value, ok := record["price"].(json.Number)
+	if !ok {
+		return nil
+	}
+	price, err := value.Float64()
+	if err != nil {
+		return err
+	}
+	record["price"] = json.Number(domain.Amount(math.Round(price * 100)).String())
+	return nil
+}
+
+// decodeProducts parsea un archivo o snapshot de productos en cualquier
+// version soportada y verifica que no repita ids
+func decodeProducts(data []byte) ([]domain.Product, *MigrationReport, error) {
+	payload, report, err := productMigrations.Decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var products []domain.Product
+	if err := json.Unmarshal(payload, &products); err != nil {
+		return nil, nil, err
+	}
+	if err := CheckIDs("products", products, productID); err != nil {
+		return nil, nil, err
+	}
+	return products, report, nil
+}
+
+// encodeProducts serializa los productos con la version actual
+func encodeProducts(products []domain.Product) ([]byte, error) {
+	return productMigrations.Encode(products)
+}
+
+// backupPath es donde se guarda una copia del archivo antes de migrarlo
+func backupPath(path string, version int) string {
+	return path + ".v" + strconv.Itoa(version) + ".bak"
+}
+
+// keepOriginal copia el archivo original antes de reescribirlo en la version
+// nueva, salvo que ya exista una copia de esa version
+func keepOriginal(path string, version int) error {
+	dst := backupPath(path, version)
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data, 0644)
+}
+
+// MigrateProductsFile lleva un products.json a la version actual. Con dryRun
+// solo informa que cambiaria; si no, guarda una copia del original en
+// <path>.v<version>.bak y reescribe el archivo. No debe usarse con el
+// servidor corriendo.
+func MigrateProductsFile(path string, dryRun bool) (*MigrationReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	products, report, err := decodeProducts(data)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun || !report.Upgraded() {
+		return report, nil
+	}
+	if err := keepOriginal(path, report.From); err != nil {
+		return nil, err
+	}
+	encoded, err := encodeProducts(products)
+	if err != nil {
+		return nil, err
+	}
+	return report, writeFileAtomic(path, encoded, 0644)
+}
+
+// UnmarshalJSON lleva el producto de la entrada a la version actual antes de
+// decodificarlo, asi un journal escrito por una version anterior se puede
+// reaplicar
+func (e *JournalEntry) UnmarshalJSON(data []byte) error {
+	type plain JournalEntry
+	var raw struct {
+		plain
+		Product json.RawMessage `json:"product,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = JournalEntry(raw.plain)
+	e.Product = nil
+	if len(raw.Product) == 0 || string(raw.Product) == "null" {
+		return nil
+	}
+	version := e.V
+	if version == 0 {
+		version = 1
+	}
+	migrated, err := productMigrations.MigrateRecord(version, raw.Product)
+	if err != nil {
+		return err
+	}
+	var p domain.Product
+	if err := json.Unmarshal(migrated, &p); err != nil {
+		return errors.New("invalid product in journal entry: " + err.Error())
+	}
+	e.Product = &p
+	e.V = productMigrations.Current()
+	return nil
+}
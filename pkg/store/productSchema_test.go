@@ -0,0 +1,41 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+func TestLegacyFractionalPriceIsRoundedToCents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json")
+	legacy := `[
+		{"id":1,"name":"Oil","quantity":10,"code_value":"A1","is_published":true,"expiration":"15/12/2021","price":12.3456},
+		{"id":2,"name":"Wine","quantity":5,"code_value":"B2","is_published":true,"expiration":"01/02/2022","price":-0.125}
+	]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	journal := `{"op":"update","v":4,"at":"2024-01-01T00:00:00Z","id":1,"product":{"id":1,"name":"Oil","quantity":9,"code_value":"A1","is_published":true,"expiration":"2021-12-15","price":0.999,"version":2}}` + "\n"
+	if err := os.WriteFile(path+".journal", []byte(journal), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("legacy file with fractional prices must open: %v", err)
+	}
+	for id, want := range map[int]domain.Amount{1: 100, 2: -13} {
+		p, err := s.GetByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Price != want {
+			t.Errorf("product %d: price %s, want %s", id, p.Price, want)
+		}
+		if p.Currency != domain.BaseCurrency {
+			t.Errorf("product %d: currency %q, want %q", id, p.Currency, domain.BaseCurrency)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// ErrInvalidQuery indica un filtro u orden sobre un campo desconocido o con un
+// valor que no corresponde al tipo del campo
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Filter pide que el campo sea igual al valor
+type Filter struct {
+	Field string
+	Value string
+}
+
+// SortField ordena por un campo, ascendente salvo que Desc sea true
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Query describe una pagina de productos: filtros por igualdad, orden y
+// limit/offset. Limit 0 significa sin limite. Los resultados siempre se
+// desempatan por id para que la paginacion sea estable.
+type Query struct {
+	Filters []Filter
+	Sort    []SortField
+	Limit   int
+	Offset  int
+}
+
+// Page es una pagina de resultados y el total que cumple los filtros
+type Page[T any] struct {
+	Items []T
+	Total int
+}
+
+// ParseSort interpreta "price,-name": un campo por coma, con - para descendente
+func ParseSort(value string) []SortField {
+	var fields []SortField
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		desc := strings.HasPrefix(part, "-")
+		fields = append(fields, SortField{Field: strings.TrimPrefix(part, "-"), Desc: desc})
+	}
+	return fields
+}
+
+// productField describe un campo de producto consultable
+type productField struct {
+	column string
+	value  func(p domain.Product) any
+	parse  func(value string) (any, error)
+}
+
+func parseInt(value string) (any, error)    { return strconv.Atoi(value) }
+func parseAmount(value string) (any, error) { return domain.ParseAmount(value) }
+func parseBool(value string) (any, error)   { return strconv.ParseBool(value) }
+func parseString(value string) (any, error) { return value, nil }
+
+// parseDate acepta cualquier formato de domain.ParseDate y lo normaliza a
+// yyyy-mm-dd, que es como se compara y como lo guarda sqlite
+func parseDate(value string) (any, error) {
+	date, err := domain.ParseDate(value)
+	return date.String(), err
+}
+
+// productFields son los campos por los que se puede filtrar y ordenar, con el
+// nombre json que usa la api
+var productFields = map[string]productField{
+	"id":           {"id", func(p domain.Product) any { return p.Id }, parseInt},
+	"name":         {"name", func(p domain.Product) any { return p.Name }, parseString},
+	"quantity":     {"quantity", func(p domain.Product) any { return p.Quantity }, parseInt},
+	"code_value":   {"code_value", func(p domain.Product) any { return p.CodeValue }, parseString},
+	"is_published": {"is_published", func(p domain.Product) any { return p.IsPublished }, parseBool},
+	"expiration":   {"expiration", func(p domain.Product) any { return p.Expiration.String() }, parseDate},
+	"price":        {"price_minor", func(p domain.Product) any { return p.Price }, parseAmount},
+	"version":      {"version", func(p domain.Product) any { return p.Version }, parseInt},
+}
+
+// boundFilter es un filtro con el valor ya convertido al tipo del campo
+type boundFilter struct {
+	field productField
+	value any
+}
+
+// bind valida la query contra los campos conocidos
+func (q Query) bind() ([]boundFilter, error) {
+	if q.Limit < 0 || q.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset can't be negative", ErrInvalidQuery)
+	}
+	filters := make([]boundFilter, 0, len(q.Filters))
+	for _, f := range q.Filters {
+		field, ok := productFields[f.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, f.Field)
+		}
+		value, err := field.parse(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value %q for %s", ErrInvalidQuery, f.Value, f.Field)
+		}
+		filters = append(filters, boundFilter{field: field, value: value})
+	}
+	for _, s := range q.Sort {
+		if _, ok := productFields[s.Field]; !ok {
+			return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, s.Field)
+		}
+	}
+	return filters, nil
+}
+
+// matches indica si el producto cumple todos los filtros
+func matches(p domain.Product, filters []boundFilter) bool {
+	for _, f := range filters {
+		if f.field.value(p) != f.value {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues compara dos valores del mismo campo
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return compareOrdered(a, b.(int))
+	case domain.Amount:
+		return compareOrdered(a, b.(domain.Amount))
+	case string:
+		return strings.Compare(a, b.(string))
+	case bool:
+		return compareOrdered(boolToInt(a), boolToInt(b.(bool)))
+	}
+	return 0
+}
+
+func compareOrdered[T int | domain.Amount](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// sortProducts ordena segun q.Sort desempatando por id
+func sortProducts(products []domain.Product, fields []SortField) {
+	sort.SliceStable(products, func(i, j int) bool {
+		for _, s := range fields {
+			field := productFields[s.Field]
+			c := compareValues(field.value(products[i]), field.value(products[j]))
+			if s.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return products[i].Id < products[j].Id
+	})
+}
+
+// paginate recorta la lista segun limit y offset
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// codesOf devuelve los code_value de los productos, en orden
+func codesOf(products []domain.Product) []string {
+	codes := []string{}
+	for _, p := range products {
+		codes = append(codes, p.CodeValue)
+	}
+	return codes
+}
+
+func TestList(t *testing.T) {
+	cases := []struct {
+		name  string
+		query Query
+		want  []string
+		total int
+	}{
+		{"everything by id", Query{}, []string{"A1", "B2", "C3", "D4"}, 4},
+		{"filter", Query{Filters: []Filter{{"is_published", "true"}}}, []string{"A1", "B2", "D4"}, 3},
+		{"several filters", Query{Filters: []Filter{{"quantity", "5"}, {"price", "10.00"}}}, []string{"D4"}, 1},
+		{"filter by date in any format", Query{Filters: []Filter{{"expiration", "01/03/2030"}}}, []string{"C3"}, 1},
+		{"sort ties by id", Query{Sort: []SortField{{Field: "quantity"}}}, []string{"B2", "D4", "C3", "A1"}, 4},
+		{"sort descending", Query{Sort: []SortField{{Field: "price", Desc: true}}}, []string{"B2", "A1", "D4", "C3"}, 4},
+		{"sort by several fields", Query{Sort: []SortField{{Field: "price"}, {Field: "name", Desc: true}}}, []string{"C3", "A1", "D4", "B2"}, 4},
+		{"first page", Query{Sort: []SortField{{Field: "name"}}, Limit: 2}, []string{"D4", "C3"}, 4},
+		{"last page", Query{Sort: []SortField{{Field: "name"}}, Limit: 2, Offset: 2}, []string{"A1", "B2"}, 4},
+		{"page past the end", Query{Limit: 2, Offset: 10}, []string{}, 4},
+		{"filtered page", Query{Filters: []Filter{{"is_published", "true"}}, Limit: 1, Offset: 1}, []string{"B2"}, 3},
+	}
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		apple := domain.Product{Name: "Apple", Quantity: 5, CodeValue: "D4", IsPublished: true, Expiration: domain.NewDate(2030, time.April, 1), Price: 1000, Currency: domain.ARS}
+		if _, err := s.Create(apple); err != nil {
+			t.Fatal(err)
+		}
+		deleted := domain.Product{Name: "Gone", Quantity: 5, CodeValue: "E5", IsPublished: true, Expiration: domain.NewDate(2030, time.April, 1), Price: 1000, Currency: domain.ARS}
+		created, err := s.Create(deleted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete(created.Id); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range cases {
+			page, err := s.List(c.query)
+			if err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+			got := codesOf(page.Items)
+			if page.Total != c.total || len(got) != len(c.want) {
+				t.Fatalf("%s: got %v of %d, want %v of %d", c.name, got, page.Total, c.want, c.total)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+				}
+			}
+		}
+	})
+}
+
+func TestListRejectsInvalidQueries(t *testing.T) {
+	queries := []Query{
+		{Filters: []Filter{{"color", "red"}}},
+		{Filters: []Filter{{"quantity", "many"}}},
+		{Filters: []Filter{{"expiration", "31/02/2030"}}},
+		{Sort: []SortField{{Field: "color"}}},
+		{Limit: -1},
+		{Offset: -1},
+	}
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		for _, q := range queries {
+			if _, err := s.List(q); !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("List(%+v): got %v, want ErrInvalidQuery", q, err)
+			}
+		}
+	})
+}
+
+func TestParseSort(t *testing.T) {
+	got := ParseSort(" price,-name,,")
+	want := []SortField{{Field: "price"}, {Field: "name", Desc: true}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	}
+}
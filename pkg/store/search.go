@@ -0,0 +1,54 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// SearchCriteria son los criterios de busqueda de productos. Los campos sin
+// valor (cadena vacia o nil) no filtran. Los rangos de precio y cantidad
+// incluyen sus extremos; los de expiracion no (ExpiresBefore es antes de esa
+// fecha y ExpiresAfter despues).
+type SearchCriteria struct {
+	NameContains  string // subcadena del nombre, sin distinguir mayusculas
+	CodePrefix    string
+	PriceMin      *domain.Amount // en la moneda de cada producto
+	PriceMax      *domain.Amount
+	QuantityMin   *int
+	QuantityMax   *int
+	IsPublished   *bool
+	ExpiresBefore *domain.Date
+	ExpiresAfter  *domain.Date
+}
+
+// match indica si el producto cumple todos los criterios
+func (c SearchCriteria) match(p domain.Product) bool {
+	switch {
+	case !c.matchName(p):
+		return false
+	case c.CodePrefix != "" && !strings.HasPrefix(p.CodeValue, c.CodePrefix):
+		return false
+	case c.PriceMin != nil && p.Price < *c.PriceMin:
+		return false
+	case c.PriceMax != nil && p.Price > *c.PriceMax:
+		return false
+	case c.QuantityMin != nil && p.Quantity < *c.QuantityMin:
+		return false
+	case c.QuantityMax != nil && p.Quantity > *c.QuantityMax:
+		return false
+	case c.IsPublished != nil && p.IsPublished != *c.IsPublished:
+		return false
+	case c.ExpiresBefore != nil && !p.Expiration.Before(*c.ExpiresBefore):
+		return false
+	case c.ExpiresAfter != nil && !p.Expiration.After(*c.ExpiresAfter):
+		return false
+	}
+	return true
+}
+
+// matchName indica si el nombre contiene NameContains sin distinguir
+// mayusculas, tambien fuera de ASCII (ej. "ñandú" en "Ñandú")
+func (c SearchCriteria) matchName(p domain.Product) bool {
+	return c.NameContains == "" || strings.Contains(strings.ToLower(p.Name), strings.ToLower(c.NameContains))
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+func TestSearch(t *testing.T) {
+	amount := func(a domain.Amount) *domain.Amount { return &a }
+	number := func(n int) *int { return &n }
+	date := func(year int, month time.Month, day int) *domain.Date {
+		d := domain.NewDate(year, month, day)
+		return &d
+	}
+	published := false
+	cases := []struct {
+		name     string
+		criteria SearchCriteria
+		want     []string
+	}{
+		{"no criteria", SearchCriteria{}, []string{"A1", "B2", "C3", "D4"}},
+		{"name ignores case", SearchCriteria{NameContains: "oI"}, []string{"A1"}},
+		{"name ignores case outside ascii", SearchCriteria{NameContains: "ñANDÚ"}, []string{"D4"}},
+		{"code prefix", SearchCriteria{CodePrefix: "B"}, []string{"B2"}},
+		{"price range includes its ends", SearchCriteria{PriceMin: amount(1000), PriceMax: amount(2500)}, []string{"A1", "B2"}},
+		{"quantity range includes its ends", SearchCriteria{QuantityMin: number(5), QuantityMax: number(8)}, []string{"B2", "C3"}},
+		{"published", SearchCriteria{IsPublished: &published}, []string{"C3"}},
+		{"expires before excludes the date", SearchCriteria{ExpiresBefore: date(2030, time.March, 1)}, []string{"A1"}},
+		{"expires after excludes the date", SearchCriteria{ExpiresAfter: date(2030, time.March, 1)}, []string{"B2", "D4"}},
+		{"criteria are combined", SearchCriteria{PriceMin: amount(600), ExpiresAfter: date(2030, time.January, 1)}, []string{"B2"}},
+		{"nothing matches", SearchCriteria{CodePrefix: "Z"}, []string{}},
+	}
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		extra := []domain.Product{
+			{Name: "Ñandú eggs", Quantity: 2, CodeValue: "D4", IsPublished: true, Expiration: domain.NewDate(2030, time.December, 1), Price: 300, Currency: domain.ARS},
+			{Name: "Oil old", Quantity: 1, CodeValue: "E5", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 1), Price: 1000, Currency: domain.ARS},
+		}
+		for _, p := range extra {
+			if _, err := s.Create(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		deleted, err := s.GetByCodeValue("E5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete(deleted.Id); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range cases {
+			found, err := s.Search(c.criteria)
+			if err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+			got := []string{}
+			for _, p := range found {
+				got = append(got, p.CodeValue)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+				}
+			}
+		}
+	})
+}
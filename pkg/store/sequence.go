@@ -0,0 +1,107 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Sequence entrega ids crecientes que nunca se reutilizan, aunque se borren
+// registros. El ultimo id entregado se persiste en un archivo junto a los
+// datos (por convencion <archivo de datos>.seq).
+type Sequence struct {
+	mu   sync.Mutex
+	path string
+	last int
+}
+
+// NewFileSequence abre la secuencia guardada en path. floor es el mayor id
+// presente en los datos: la secuencia nunca entrega un id menor o igual, aun
+// si el archivo no existe o quedo desactualizado.
+func NewFileSequence(path string, floor int) (*Sequence, error) {
+	seq := &Sequence{path: path, last: floor}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return seq, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sequence file %s: %w", path, err)
+	}
+	if stored > seq.last {
+		seq.last = stored
+	}
+	return seq, nil
+}
+
+// Next reserva y persiste el siguiente id
+func (s *Sequence) Next() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.last + 1
+	if err := writeFileAtomic(s.path, []byte(strconv.Itoa(next)), 0644); err != nil {
+		return 0, err
+	}
+	s.last = next
+	return next, nil
+}
+
+// Observe asegura que la secuencia no entregue ids menores o iguales a floor
+func (s *Sequence) Observe(floor int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if floor > s.last {
+		s.last = floor
+	}
+}
+
+// DuplicateIDsError indica que los datos persistidos tienen ids repetidos
+type DuplicateIDsError struct {
+	Source string
+	IDs    []int
+}
+
+func (e *DuplicateIDsError) Error() string {
+	ids := make([]string, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	return fmt.Sprintf("%s: duplicate ids %s", e.Source, strings.Join(ids, ", "))
+}
+
+// CheckIDs verifica que ningun id se repita. source identifica el origen de
+// los datos en el mensaje de error.
+func CheckIDs[T any](source string, items []T, id func(*T) *int) error {
+	seen := make(map[int]int, len(items))
+	var dups []int
+	for i := range items {
+		key := *id(&items[i])
+		seen[key]++
+		if seen[key] == 2 {
+			dups = append(dups, key)
+		}
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+	sort.Ints(dups)
+	return &DuplicateIDsError{Source: source, IDs: dups}
+}
+
+// maxID devuelve el mayor id de la lista o 0 si esta vacia
+func maxID[T any](items []T, id func(*T) *int) int {
+	max := 0
+	for i := range items {
+		if v := *id(&items[i]); v > max {
+			max = v
+		}
+	}
+	return max
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// readSeq devuelve el contenido del archivo de secuencia
+func readSeq(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSequencePersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.seq")
+	seq, err := NewFileSequence(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for want := 4; want <= 5; want++ {
+		if id, err := seq.Next(); err != nil || id != want {
+			t.Fatalf("Next: %d %v, want %d", id, err, want)
+		}
+	}
+	if got := readSeq(t, path); got != "5" {
+		t.Fatalf("sequence file: %q, want 5", got)
+	}
+
+	reopened, err := NewFileSequence(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := reopened.Next(); err != nil || id != 6 {
+		t.Fatalf("Next after reopening: %d %v, want 6", id, err)
+	}
+}
+
+func TestSequenceNeverGoesBelowTheData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.seq")
+	if err := os.WriteFile(path, []byte("2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	seq, err := NewFileSequence(path, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq.Observe(9)
+	seq.Observe(4)
+	if id, err := seq.Next(); err != nil || id != 10 {
+		t.Fatalf("Next: %d %v, want 10", id, err)
+	}
+}
+
+func TestSequenceRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.seq")
+	if err := os.WriteFile(path, []byte("ten"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileSequence(path, 0); err == nil {
+		t.Fatal("an invalid sequence file must fail")
+	}
+}
+
+func TestCheckIDs(t *testing.T) {
+	items := []domain.Employee{{Id: 3}, {Id: 1}, {Id: 3}, {Id: 2}, {Id: 1}, {Id: 3}}
+	err := CheckIDs("employees", items, func(e *domain.Employee) *int { return &e.Id })
+	var dup *DuplicateIDsError
+	if !errors.As(err, &dup) {
+		t.Fatalf("got %v, want DuplicateIDsError", err)
+	}
+	if dup.Source != "employees" || len(dup.IDs) != 2 || dup.IDs[0] != 1 || dup.IDs[1] != 3 {
+		t.Fatalf("duplicates: %+v", dup)
+	}
+	if err.Error() != "employees: duplicate ids 1, 3" {
+		t.Fatalf("message: %q", err)
+	}
+}
+
+func TestProductIDsAreNotReused(t *testing.T) {
+	s, path := newJournaledStore(t)
+	if err := s.Delete(3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Purge(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	p := domain.Product{Name: "Tea", Quantity: 1, CodeValue: "D4", Expiration: domain.NewDate(2030, time.May, 1), Price: 100, Currency: domain.ARS}
+	created, err := s.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Id != 4 {
+		t.Fatalf("created after purging the last product: id %d, want 4", created.Id)
+	}
+
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Delete(4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Purge(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	p.CodeValue = "E5"
+	if created, err = reopened.Create(p); err != nil || created.Id != 5 {
+		t.Fatalf("created after a restart: id %d %v, want 5", created.Id, err)
+	}
+	if got := readSeq(t, path+".seq"); got != "5" {
+		t.Fatalf("sequence file: %q, want 5", got)
+	}
+}
+
+func TestProductStoreRejectsDuplicateIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json")
+	products := append(seedProducts(), domain.Product{Id: 2, Name: "Copy", Quantity: 1, CodeValue: "Z9", Price: 100, Version: 1})
+	data, err := encodeProducts(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var dup *DuplicateIDsError
+	if _, err := NewStore(path); !errors.As(err, &dup) || len(dup.IDs) != 1 || dup.IDs[0] != 2 {
+		t.Fatalf("got %v, want duplicate id 2", err)
+	}
+}
+
+func TestFileStoreIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "employees.json")
+	open := func() TrashStore[domain.Employee] {
+		return NewFileStore[domain.Employee](path, JSONCodec[domain.Employee]{}, func(e *domain.Employee) *int { return &e.Id })
+	}
+	s := open()
+	for want := 1; want <= 2; want++ {
+		if e, err := s.Create(domain.Employee{Name: "Ana"}); err != nil || e.Id != want {
+			t.Fatalf("Create: id %d %v, want %d", e.Id, err, want)
+		}
+	}
+	if err := s.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := open().Create(domain.Employee{Name: "Luis"}); err != nil || e.Id != 3 {
+		t.Fatalf("Create after deleting and reopening: id %d %v, want 3", e.Id, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"id":1,"name":"Ana"},{"id":1,"name":"Luis"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var dup *DuplicateIDsError
+	if _, err := open().GetAll(); !errors.As(err, &dup) {
+		t.Fatalf("got %v, want DuplicateIDsError", err)
+	}
+}
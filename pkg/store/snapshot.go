@@ -0,0 +1,288 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// Snapshotter es un store que se puede respaldar y restaurar completo
+type Snapshotter interface {
+	// Freeze toma una foto consistente del contenido. Hasta llamar a Release
+	// las escrituras del store quedan en espera.
+	Freeze() (Snapshot, error)
+	// Validate verifica que data sea un contenido restaurable
+	Validate(data []byte) error
+	// Restore reemplaza todo el contenido por data, validandolo antes
+	Restore(data []byte) error
+}
+
+// Snapshot es el contenido congelado de un store
+type Snapshot interface {
+	Write(w io.Writer) error
+	Release()
+}
+
+type bytesSnapshot struct {
+	data    []byte
+	release func()
+}
+
+func (s *bytesSnapshot) Write(w io.Writer) error {
+	_, err := w.Write(s.data)
+	return err
+}
+
+func (s *bytesSnapshot) Release() {
+	if s.release != nil {
+		s.release()
+		s.release = nil
+	}
+}
+
+// ErrReadOnly indica un intento de restaurar un store abierto solo para
+// respaldarlo
+var ErrReadOnly = errors.New("store is open read-only")
+
+// readOnlySnapshotter respalda un store leyendo sus archivos sin abrirlo, asi
+// se puede usar con el servidor corriendo. No restaura.
+type readOnlySnapshotter struct {
+	read func() ([]byte, error)
+}
+
+func (r readOnlySnapshotter) Freeze() (Snapshot, error) {
+	data, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	return &bytesSnapshot{data: data}, nil
+}
+
+func (r readOnlySnapshotter) Validate(data []byte) error {
+	return ErrReadOnly
+}
+
+func (r readOnlySnapshotter) Restore(data []byte) error {
+	return ErrReadOnly
+}
+
+// maxReadAttempts es cuantas veces se vuelve a leer un store json que se
+// compacto mientras se lo leia
+const maxReadAttempts = 5
+
+// ReadOnlyJSON devuelve un Snapshotter de los productos del store json en
+// path que reconstruye el estado (archivo mas journal) sin escribir ningun
+// archivo. A diferencia de NewStore no recorta el journal ni migra el
+// archivo, asi que no pisa lo que el servidor escribe mientras tanto.
+func ReadOnlyJSON(path string) Snapshotter {
+	return readOnlySnapshotter{read: func() ([]byte, error) {
+		for attempt := 0; attempt < maxReadAttempts; attempt++ {
+			before, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			s := newJSONStore(path)
+			if err := s.replay(); err != nil {
+				return nil, err
+			}
+			// si el archivo cambio, una compactacion pudo vaciar el journal
+			// despues de leer el archivo viejo y se perderian sus entradas
+			after, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Equal(before, after) {
+				return encodeProducts(s.all())
+			}
+		}
+		return nil, fmt.Errorf("%s: file kept changing while reading it", path)
+	}}
+}
+
+// replay carga el archivo y reaplica el journal sobre los indices, sin
+// abrir el journal ni la secuencia
+func (s *jsonStore) replay() error {
+	products, _, err := s.loadProducts()
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.pathToFile, err)
+	}
+	s.load(products)
+	entries, _, _, err := readJournal(s.journalPath())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.apply(entry)
+	}
+	return nil
+}
+
+// ReadOnlySqlite devuelve un Snapshotter de los productos de la base sqlite
+// en dbPath, abierta en modo de solo lectura
+func ReadOnlySqlite(dbPath string) Snapshotter {
+	return readOnlySnapshotter{read: func() ([]byte, error) {
+		db, err := sql.Open("sqlite", "file:"+(&url.URL{Path: dbPath}).EscapedPath()+"?mode=ro")
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		products, err := sqliteOps{q: db}.loadProducts()
+		if err != nil {
+			return nil, err
+		}
+		return encodeProducts(products)
+	}}
+}
+
+// Freeze congela las escrituras y serializa los productos
+func (s *jsonStore) Freeze() (Snapshot, error) {
+	s.mu.RLock()
+	data, err := encodeProducts(s.all())
+	if err != nil {
+		s.mu.RUnlock()
+		return nil, err
+	}
+	return &bytesSnapshot{data: data, release: s.mu.RUnlock}, nil
+}
+
+// Validate verifica un snapshot de productos
+func (s *jsonStore) Validate(data []byte) error {
+	_, _, err := decodeProducts(data)
+	return err
+}
+
+// Restore reemplaza los productos, reescribe el archivo y vacia el journal.
+// El historial se recorta con restoredHistory.
+func (s *jsonStore) Restore(data []byte) error {
+	products, _, err := decodeProducts(data)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.saveProducts(products); err != nil {
+		return err
+	}
+	if err := s.history.retain(restoredHistory(products)); err != nil {
+		return err
+	}
+	if err := s.journal.reset(); err != nil {
+		return err
+	}
+	s.load(products)
+	s.seq.Observe(maxID(products, productID))
+	return nil
+}
+
+// restoredHistory indica que entradas del historial siguen valiendo despues de
+// restaurar products: las de versiones que los productos restaurados ya
+// tenian (un delete solo si el producto quedo en la papelera) y las de los
+// productos purgados antes del snapshot, que tienen ids menores que el mayor
+// restaurado. Se pierde el de los productos creados despues del snapshot.
+func restoredHistory(products []domain.Product) func(change domain.ProductChange) bool {
+	restored := map[int]domain.Product{}
+	for _, p := range products {
+		restored[p.Id] = p
+	}
+	floor := maxID(products, productID)
+	return func(change domain.ProductChange) bool {
+		p, ok := restored[change.ProductID]
+		if !ok {
+			return change.ProductID < floor
+		}
+		if change.Version == p.Version && change.Op == OpDelete {
+			// el delete no cambia la version del producto
+			return p.DeletedAt != nil
+		}
+		return change.Version <= p.Version
+	}
+}
+
+// Freeze abre una transaccion de lectura, que retiene la unica conexion del
+// pool, y vuelca los productos como array json
+func (s *sqliteStore) Freeze() (Snapshot, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	products, err := sqliteOps{q: tx}.loadProducts()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	data, err := encodeProducts(products)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &bytesSnapshot{data: data, release: func() { tx.Rollback() }}, nil
+}
+
+// Validate verifica un snapshot de productos
+func (s *sqliteStore) Validate(data []byte) error {
+	_, _, err := decodeProducts(data)
+	return err
+}
+
+// Restore reemplaza el contenido de la tabla y recorta el historial con
+// restoredHistory, en una transaccion
+func (s *sqliteStore) Restore(data []byte) error {
+	products, _, err := decodeProducts(data)
+	if err != nil {
+		return err
+	}
+	return s.saveProducts(products)
+}
+
+// Freeze congela las escrituras y devuelve el archivo tal como esta
+func (s *fileStore[T]) Freeze() (Snapshot, error) {
+	s.mu.Lock()
+	items, err := s.load()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	data, err := s.codec.Encode(items)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	return &bytesSnapshot{data: data, release: s.mu.Unlock}, nil
+}
+
+// Validate verifica que data se pueda leer con el codec y no repita ids
+func (s *fileStore[T]) Validate(data []byte) error {
+	items, err := s.codec.Decode(data)
+	if err != nil {
+		return err
+	}
+	return CheckIDs("snapshot", items, s.id)
+}
+
+// Restore reemplaza el archivo por data
+func (s *fileStore[T]) Restore(data []byte) error {
+	items, err := s.codec.Decode(data)
+	if err != nil {
+		return err
+	}
+	if err := CheckIDs("snapshot", items, s.id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := writeFileAtomic(s.pathToFile, data, 0644); err != nil {
+		return err
+	}
+	if s.seq == nil {
+		s.seq, err = NewFileSequence(s.pathToFile+".seq", maxID(items, s.id))
+		return err
+	}
+	s.seq.Observe(maxID(items, s.id))
+	return nil
+}
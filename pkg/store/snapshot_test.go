@@ -0,0 +1,191 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// change aplica fn en una transaccion y registra op en el historial de id con
+// la version que quedo
+func change(t *testing.T, s ProductStore, id int, op string, fn func(tx Tx) error) {
+	t.Helper()
+	tx, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		t.Fatal(err)
+	}
+	p, err := tx.GetByID(id)
+	if err != nil {
+		if p, err = tx.GetDeleted(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.AppendHistory(domain.ProductChange{ProductID: id, Op: op, Version: p.Version, At: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// opsOf devuelve las operaciones del historial de id, la mas vieja primero
+func opsOf(t *testing.T, s ProductStore, id int) []string {
+	t.Helper()
+	page, err := s.History(id, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for i := len(page.Items) - 1; i >= 0; i-- {
+		names = append(names, page.Items[i].Op)
+	}
+	return names
+}
+
+func TestRestoreTrimsHistoryToRestoredVersions(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		update := func(id int) func(tx Tx) error {
+			return func(tx Tx) error {
+				p, err := tx.GetByID(id)
+				if err != nil {
+					return err
+				}
+				p.Quantity++
+				return tx.Update(p)
+			}
+		}
+		remove := func(id int) func(tx Tx) error {
+			return func(tx Tx) error { return tx.Delete(id) }
+		}
+		change(t, s, 1, "update", update(1))
+		change(t, s, 3, "delete", remove(3))
+
+		snapshotter := s.(Snapshotter)
+		snap, err := snapshotter.Freeze()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data bytes.Buffer
+		if err := snap.Write(&data); err != nil {
+			t.Fatal(err)
+		}
+		snap.Release()
+
+		change(t, s, 1, "update", update(1))
+		change(t, s, 2, "delete", remove(2))
+		created, err := s.Create(domain.Product{Name: "New", Quantity: 1, CodeValue: "D4", Price: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		change(t, s, created.Id, "update", update(created.Id))
+
+		if err := snapshotter.Restore(data.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		want := map[int][]string{1: {"update"}, 2: {}, 3: {"delete"}, created.Id: {}}
+		for id, ops := range want {
+			got := opsOf(t, s, id)
+			if len(got) != len(ops) {
+				t.Errorf("product %d: history %v, want %v", id, got, ops)
+				continue
+			}
+			for i := range ops {
+				if got[i] != ops[i] {
+					t.Errorf("product %d: history %v, want %v", id, got, ops)
+				}
+			}
+		}
+	})
+}
+
+// frozen devuelve el contenido que entrega el Freeze de s
+func frozen(t *testing.T, s Snapshotter) []domain.Product {
+	t.Helper()
+	snap, err := s.Freeze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+	var buf bytes.Buffer
+	if err := snap.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	products, _, err := decodeProducts(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return products
+}
+
+func TestReadOnlyJSONSeesJournalWithoutTouchingIt(t *testing.T) {
+	s, path := newJournaledStore(t)
+	mutate(t, s)
+	journal, err := os.ReadFile(path + ".journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal) == 0 {
+		t.Fatal("mutate must leave pending journal entries")
+	}
+
+	readOnly := ReadOnlyJSON(path)
+	if got, want := frozen(t, readOnly), frozen(t, s.(Snapshotter)); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("read-only snapshot:\n%v\nwant\n%v", got, want)
+	}
+	after, err := os.ReadFile(path + ".journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after, journal) {
+		t.Fatal("reading the store must not change its journal")
+	}
+
+	// el store abierto sigue escribiendo en el mismo journal
+	if err := s.Buy("A1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if p := frozen(t, readOnly)[0]; p.Quantity != 6 {
+		t.Fatalf("read-only snapshot after a later buy: quantity %d, want 6", p.Quantity)
+	}
+	if err := readOnly.Restore(nil); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("restore: got %v, want ErrReadOnly", err)
+	}
+}
+
+func TestReadOnlySqlite(t *testing.T) {
+	dir := t.TempDir()
+	seed := filepath.Join(dir, "products.json")
+	data, err := encodeProducts(seedProducts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(seed, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db := filepath.Join(dir, "products.db")
+	s, err := NewSqliteStore(db, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Buy("B2", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	products := frozen(t, ReadOnlySqlite(db))
+	if len(products) != 3 || products[1].Quantity != 3 {
+		t.Fatalf("read-only snapshot: %+v", products)
+	}
+	if err := s.Buy("B2", 1); err != nil {
+		t.Fatalf("the store must keep writing after a read-only snapshot: %v", err)
+	}
+}
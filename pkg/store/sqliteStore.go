@@ -0,0 +1,739 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS products (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	name         TEXT    NOT NULL,
+	quantity     INTEGER NOT NULL,
+	code_value   TEXT    NOT NULL,
+	is_published INTEGER NOT NULL DEFAULT 0,
+	expiration   TEXT    NOT NULL,
+	price        REAL    NOT NULL,
+	version      INTEGER NOT NULL DEFAULT 1,
+	deleted_at   TEXT,
+	aliases      TEXT    NOT NULL DEFAULT '[]',
+	price_minor  INTEGER,
+	currency     TEXT    NOT NULL DEFAULT 'ARS'
+);
+CREATE TABLE IF NOT EXISTS product_history (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id INTEGER NOT NULL,
+	op         TEXT    NOT NULL,
+	version    INTEGER NOT NULL,
+	at         TEXT    NOT NULL,
+	actor      TEXT    NOT NULL,
+	diff       TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_product_history_product ON product_history (product_id, id);
+`
+
+// sqliteIndexes se aplican despues de agregar las columnas faltantes. El
+// code_value es unico solo entre los productos que no estan en la papelera.
+const sqliteIndexes = `
+DROP INDEX IF EXISTS idx_products_code_value;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_live_code_value ON products (code_value) WHERE deleted_at IS NULL;
+`
+
+// price es el precio aproximado, que se sigue escribiendo para quien lea la
+// base con una version anterior; el exacto, en centavos, es price_minor
+const productColumns = "id, name, quantity, code_value, is_published, expiration, price_minor, version, deleted_at, aliases, currency"
+
+// timeLayout es el formato de deleted_at y de las fechas del historial: ancho
+// fijo y en UTC, para que comparar como texto sea comparar los instantes
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// sqliteColumns son las columnas agregadas despues de la primera version del
+// schema, que se agregan a las bases existentes al abrirlas
+var sqliteColumns = []struct{ name, definition string }{
+	{"version", "INTEGER NOT NULL DEFAULT 1"},
+	{"deleted_at", "TEXT"},
+	{"aliases", "TEXT NOT NULL DEFAULT '[]'"},
+	{"price_minor", "INTEGER"},
+	{"currency", "TEXT NOT NULL DEFAULT 'ARS'"},
+}
+
+// sqlitePrices completa price_minor en las filas escritas por versiones que
+// solo guardaban price
+const sqlitePrices = "UPDATE products SET price_minor = CAST(round(price * 100) AS INTEGER) WHERE price_minor IS NULL"
+
+// querier es lo que comparten *sql.DB y *sql.Tx
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqliteOps implementa las operaciones sobre la base o sobre una transaccion
+type sqliteOps struct {
+	q querier
+}
+
+type sqliteStore struct {
+	sqliteOps
+	db *sql.DB
+}
+
+// sqliteTx es una transaccion de la base. Como el pool tiene una sola
+// conexion, mientras esta abierta el resto de las operaciones espera.
+type sqliteTx struct {
+	sqliteOps
+	tx *sql.Tx
+}
+
+// NewSqliteStore abre (o crea) la base sqlite en dbPath y aplica el schema. Los
+// ids los asigna AUTOINCREMENT, que guarda en sqlite_sequence el mayor id
+// usado y no los reutiliza despues de un delete.
+// Si seedPath no esta vacio y la tabla esta vacia, importa una unica vez los
+// productos de ese archivo json.
+func NewSqliteStore(dbPath string, seedPath string) (ProductStore, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite admite un solo escritor, serializamos todo en una conexion
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := addMissingColumns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteIndexes); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := normalizeExpirations(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(sqlitePrices); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &sqliteStore{sqliteOps: sqliteOps{q: db}, db: db}
+	if seedPath != "" {
+		if _, err := s.importJSON(seedPath); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// importJSON copia los productos de un products.json si la tabla esta vacia,
+// conservando los ids. Devuelve la cantidad de productos importados.
+func (s *sqliteStore) importJSON(path string) (int, error) {
+	var count int
+	if err := s.q.QueryRow("SELECT COUNT(*) FROM products").Scan(&count); err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, nil
+	}
+	source := &jsonStore{pathToFile: path}
+	products, _, err := source.loadProducts()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.saveProducts(products); err != nil {
+		return 0, err
+	}
+	return len(products), nil
+}
+
+// addMissingColumns agrega a la tabla products las columnas de sqliteColumns
+// que todavia no tenga
+func addMissingColumns(db *sql.DB) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('products')")
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, column := range sqliteColumns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE products ADD COLUMN " + column.name + " " + column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeExpirations pasa a yyyy-mm-dd las fechas de expiracion guardadas
+// en otro formato por versiones anteriores
+func normalizeExpirations(db *sql.DB) error {
+	rows, err := db.Query("SELECT id, expiration FROM products WHERE expiration NOT GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]'")
+	if err != nil {
+		return err
+	}
+	dates := map[int]domain.Date{}
+	for rows.Next() {
+		var id int
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		date, err := domain.ParseDate(value)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("product %d: %w", id, err)
+		}
+		dates[id] = date
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, date := range dates {
+		if _, err := db.Exec("UPDATE products SET expiration = ? WHERE id = ?", date, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProduct(row rowScanner) (domain.Product, error) {
+	var p domain.Product
+	var deletedAt sql.NullString
+	var aliases string
+	if err := row.Scan(&p.Id, &p.Name, &p.Quantity, &p.CodeValue, &p.IsPublished, &p.Expiration, &p.Price, &p.Version, &deletedAt, &aliases, &p.Currency); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal([]byte(aliases), &p.Aliases); err != nil {
+		return p, fmt.Errorf("product %d: invalid aliases %q", p.Id, aliases)
+	}
+	if len(p.Aliases) == 0 {
+		p.Aliases = nil
+	}
+	if deletedAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, deletedAt.String)
+		if err != nil {
+			return p, fmt.Errorf("product %d: invalid deleted_at %q", p.Id, deletedAt.String)
+		}
+		p.DeletedAt = &t
+	}
+	return p, nil
+}
+
+// deletedAtValue es el valor de deleted_at de un producto
+func deletedAtValue(p domain.Product) any {
+	if p.DeletedAt == nil {
+		return nil
+	}
+	return p.DeletedAt.UTC().Format(timeLayout)
+}
+
+// aliasesValue es el valor de aliases de un producto: un array json
+func aliasesValue(aliases []string) string {
+	if len(aliases) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(aliases)
+	return string(data)
+}
+
+// queryProducts ejecuta una consulta y devuelve los productos encontrados
+func (s sqliteOps) queryProducts(query string, args ...any) ([]domain.Product, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	products := []domain.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// loadProducts carga todos los productos de la base, incluida la papelera
+func (s sqliteOps) loadProducts() ([]domain.Product, error) {
+	return s.queryProducts("SELECT " + productColumns + " FROM products ORDER BY id")
+}
+
+// saveProducts reemplaza el contenido de la tabla por los productos dados y
+// recorta el historial con restoredHistory
+func (s *sqliteStore) saveProducts(products []domain.Product) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM products"); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT INTO products (" + productColumns + ", price) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range products {
+		if p.Version == 0 {
+			p.Version = 1
+		}
+		if _, err := stmt.Exec(p.Id, p.Name, p.Quantity, p.CodeValue, p.IsPublished, p.Expiration, p.Price, p.Version, deletedAtValue(p), aliasesValue(p.Aliases), p.Currency.OrBase(), p.Price.Float64()); err != nil {
+			return err
+		}
+	}
+	if err := trimHistory(tx, restoredHistory(products)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// trimHistory borra las entradas del historial para las que keep devuelve false
+func trimHistory(tx *sql.Tx, keep func(change domain.ProductChange) bool) error {
+	rows, err := tx.Query("SELECT id, product_id, op, version FROM product_history")
+	if err != nil {
+		return err
+	}
+	var drop []int
+	for rows.Next() {
+		var change domain.ProductChange
+		if err := rows.Scan(&change.ID, &change.ProductID, &change.Op, &change.Version); err != nil {
+			rows.Close()
+			return err
+		}
+		if !keep(change) {
+			drop = append(drop, change.ID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range drop {
+		if _, err := tx.Exec("DELETE FROM product_history WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAll devuelve todos los productos
+func (s sqliteOps) GetAll() ([]domain.Product, error) {
+	return s.queryProducts("SELECT " + productColumns + " FROM products WHERE deleted_at IS NULL ORDER BY id")
+}
+
+// GetByID devuelve un producto por su id
+func (s sqliteOps) GetByID(id int) (domain.Product, error) {
+	row := s.q.QueryRow("SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NULL", id)
+	p, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, errors.New("product not found")
+	}
+	return p, err
+}
+
+// usesCode es la condicion de un producto que usa ? como code_value o como alias
+const usesCode = "(code_value = ? OR EXISTS (SELECT 1 FROM json_each(aliases) WHERE value = ?))"
+
+// GetByCodeValue devuelve un producto por su code_value o, si ninguno lo
+// tiene, por alias
+func (s sqliteOps) GetByCodeValue(code string) (domain.Product, error) {
+	row := s.q.QueryRow(
+		"SELECT "+productColumns+" FROM products WHERE deleted_at IS NULL AND "+usesCode+" ORDER BY code_value != ?, id LIMIT 1",
+		code, code, code,
+	)
+	p, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, errors.New("product not found")
+	}
+	return p, err
+}
+
+// checkCode falla con ErrDuplicateCodeValue si un producto distinto de id usa
+// code como code_value o como alias
+func (s sqliteOps) checkCode(id int, code string) error {
+	var other int
+	err := s.q.QueryRow(
+		"SELECT id FROM products WHERE deleted_at IS NULL AND id != ? AND "+usesCode+" LIMIT 1",
+		id, code, code,
+	).Scan(&other)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s is used by product %d", ErrDuplicateCodeValue, code, other)
+}
+
+// Search devuelve los productos que cumplen los criterios, ordenados por id
+func (s sqliteOps) Search(criteria SearchCriteria) ([]domain.Product, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	args := []any{}
+	add := func(condition string, values ...any) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+	if criteria.CodePrefix != "" {
+		add("substr(code_value, 1, length(?)) = ?", criteria.CodePrefix, criteria.CodePrefix)
+	}
+	if criteria.PriceMin != nil {
+		add("price_minor >= ?", *criteria.PriceMin)
+	}
+	if criteria.PriceMax != nil {
+		add("price_minor <= ?", *criteria.PriceMax)
+	}
+	if criteria.QuantityMin != nil {
+		add("quantity >= ?", *criteria.QuantityMin)
+	}
+	if criteria.QuantityMax != nil {
+		add("quantity <= ?", *criteria.QuantityMax)
+	}
+	if criteria.IsPublished != nil {
+		add("is_published = ?", *criteria.IsPublished)
+	}
+	if criteria.ExpiresBefore != nil {
+		add("expiration < ?", *criteria.ExpiresBefore)
+	}
+	if criteria.ExpiresAfter != nil {
+		add("expiration > ?", *criteria.ExpiresAfter)
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+	products, err := s.queryProducts("SELECT "+productColumns+" FROM products"+where+" ORDER BY id", args...)
+	if err != nil || criteria.NameContains == "" {
+		return products, err
+	}
+	// lower() de SQLite solo convierte ASCII: el nombre se compara en Go,
+	// igual que en el store JSON
+	found := []domain.Product{}
+	for _, p := range products {
+		if criteria.matchName(p) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+// Create agrega un nuevo producto, sin alias
+func (s sqliteOps) Create(product domain.Product) (domain.Product, error) {
+	if err := s.checkCode(0, product.CodeValue); err != nil {
+		return domain.Product{}, err
+	}
+	res, err := s.q.Exec(
+		"INSERT INTO products (name, quantity, code_value, is_published, expiration, price_minor, currency, price) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		product.Name, product.Quantity, product.CodeValue, product.IsPublished, product.Expiration, product.Price, product.Currency.OrBase(), product.Price.Float64(),
+	)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return domain.Product{}, err
+	}
+	product.Id = int(id)
+	product.Version = 1
+	product.Aliases = nil
+	product.DeletedAt = nil
+	return product, nil
+}
+
+// Create agrega un producto en una transaccion, para que nadie tome su
+// code_value entre la verificacion y el insert
+func (s *sqliteStore) Create(product domain.Product) (domain.Product, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return domain.Product{}, err
+	}
+	defer tx.Rollback()
+	created, err := sqliteOps{q: tx}.Create(product)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	return created, tx.Commit()
+}
+
+// Update actualiza un producto si esta en la version product.Version (0 para
+// no verificarla) y le asigna la siguiente
+func (s sqliteOps) Update(product domain.Product) error {
+	if err := s.checkCode(product.Id, product.CodeValue); err != nil {
+		return err
+	}
+	res, err := s.q.Exec(
+		"UPDATE products SET name = ?, quantity = ?, code_value = ?, is_published = ?, expiration = ?, price_minor = ?, currency = ?, price = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)",
+		product.Name, product.Quantity, product.CodeValue, product.IsPublished, product.Expiration, product.Price, product.Currency.OrBase(), product.Price.Float64(), product.Id, product.Version, product.Version,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(res, errors.New("product not found")); err != nil {
+		// no se actualizo: o no existe o cambio de version
+		current, getErr := s.GetByID(product.Id)
+		if getErr != nil {
+			return err
+		}
+		if conflict := CheckVersion(current, product.Version); conflict != nil {
+			return conflict
+		}
+		return err
+	}
+	return nil
+}
+
+// Rename cambia el code_value de un producto y conserva el anterior como alias
+func (s sqliteOps) Rename(id int, code string) error {
+	current, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCode(id, code); err != nil {
+		return err
+	}
+	_, err = s.q.Exec(
+		"UPDATE products SET code_value = ?, aliases = ?, version = version + 1 WHERE id = ?",
+		code, aliasesValue(renameAliases(current, code)), id,
+	)
+	return err
+}
+
+// Delete manda un producto a la papelera
+func (s sqliteOps) Delete(id int) error {
+	res, err := s.q.Exec(
+		"UPDATE products SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now().UTC().Format(timeLayout), id,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, errors.New("product not found"))
+}
+
+// Buy descuenta la cantidad comprada si hay stock suficiente
+func (s sqliteOps) Buy(code string, quantity int) error {
+	p, err := s.GetByCodeValue(code)
+	if err != nil {
+		return errors.New("No se puede ejecutar la compra")
+	}
+	res, err := s.q.Exec(
+		"UPDATE products SET quantity = quantity - ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND quantity >= ?",
+		quantity, p.Id, quantity,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, errors.New("No se puede ejecutar la compra"))
+}
+
+// Trash devuelve los productos borrados, el mas reciente primero
+func (s *sqliteStore) Trash() ([]domain.Product, error) {
+	return s.queryProducts("SELECT " + productColumns + " FROM products WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id")
+}
+
+// GetDeleted devuelve un producto de la papelera
+func (s sqliteOps) GetDeleted(id int) (domain.Product, error) {
+	row := s.q.QueryRow("SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NOT NULL", id)
+	p, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, fmt.Errorf("%w: product %d is not in the trash", ErrNotFound, id)
+	}
+	return p, err
+}
+
+// Undelete devuelve un producto de la papelera al catalogo, si su code_value
+// no lo tomo otro producto
+func (s sqliteOps) Undelete(id int) (domain.Product, error) {
+	p, err := s.GetDeleted(id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	for _, code := range append([]string{p.CodeValue}, p.Aliases...) {
+		if err := s.checkCode(id, code); err != nil {
+			return domain.Product{}, err
+		}
+	}
+	if _, err := s.q.Exec("UPDATE products SET deleted_at = NULL, version = version + 1 WHERE id = ?", id); err != nil {
+		return domain.Product{}, err
+	}
+	p.DeletedAt = nil
+	p.Version++
+	return p, nil
+}
+
+// Undelete devuelve un producto de la papelera en una transaccion, para que
+// nadie tome su code_value entre la verificacion y el cambio
+func (s *sqliteStore) Undelete(id int) (domain.Product, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return domain.Product{}, err
+	}
+	defer tx.Rollback()
+	p, err := sqliteOps{q: tx}.Undelete(id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	return p, tx.Commit()
+}
+
+// AppendHistory agrega una entrada al historial del producto
+func (s sqliteOps) AppendHistory(change domain.ProductChange) error {
+	diff, err := json.Marshal(change.Diff)
+	if err != nil {
+		return err
+	}
+	_, err = s.q.Exec(
+		"INSERT INTO product_history (product_id, op, version, at, actor, diff) VALUES (?, ?, ?, ?, ?, ?)",
+		change.ProductID, change.Op, change.Version, change.At.UTC().Format(timeLayout), change.Actor, string(diff),
+	)
+	return err
+}
+
+// History devuelve una pagina del historial de un producto, la entrada mas
+// reciente primero
+func (s sqliteOps) History(productID, limit, offset int) (Page[domain.ProductChange], error) {
+	var total int
+	if err := s.q.QueryRow("SELECT COUNT(*) FROM product_history WHERE product_id = ?", productID).Scan(&total); err != nil {
+		return Page[domain.ProductChange]{}, err
+	}
+	if limit == 0 {
+		limit = -1
+	}
+	rows, err := s.q.Query(
+		"SELECT id, product_id, op, version, at, actor, diff FROM product_history WHERE product_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		productID, limit, offset,
+	)
+	if err != nil {
+		return Page[domain.ProductChange]{}, err
+	}
+	defer rows.Close()
+	changes := []domain.ProductChange{}
+	for rows.Next() {
+		var change domain.ProductChange
+		var at, diff string
+		if err := rows.Scan(&change.ID, &change.ProductID, &change.Op, &change.Version, &at, &change.Actor, &diff); err != nil {
+			return Page[domain.ProductChange]{}, err
+		}
+		if change.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return Page[domain.ProductChange]{}, fmt.Errorf("history %d: invalid at %q", change.ID, at)
+		}
+		if err := json.Unmarshal([]byte(diff), &change.Diff); err != nil {
+			return Page[domain.ProductChange]{}, fmt.Errorf("history %d: %w", change.ID, err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[domain.ProductChange]{}, err
+	}
+	return Page[domain.ProductChange]{Items: changes, Total: total}, nil
+}
+
+// Purge elimina definitivamente los productos borrados antes de before
+// y conserva su historial
+func (s *sqliteStore) Purge(before time.Time) (int, error) {
+	res, err := s.db.Exec("DELETE FROM products WHERE deleted_at IS NOT NULL AND deleted_at < ?", before.UTC().Format(timeLayout))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Begin abre una transaccion
+func (s *sqliteStore) Begin() (Tx, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{sqliteOps: sqliteOps{q: tx}, tx: tx}, nil
+}
+
+// Commit confirma la transaccion
+func (t *sqliteTx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback descarta la transaccion; despues de Commit no hace nada
+func (t *sqliteTx) Rollback() error {
+	if err := t.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+	return nil
+}
+
+// expectOneRow devuelve notFound si la sentencia no afecto ninguna fila
+func expectOneRow(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// List devuelve una pagina de productos filtrada y ordenada
+func (s sqliteOps) List(q Query) (Page[domain.Product], error) {
+	filters, err := q.bind()
+	if err != nil {
+		return Page[domain.Product]{}, err
+	}
+	where := " WHERE deleted_at IS NULL"
+	args := []any{}
+	for _, f := range filters {
+		where += " AND " + f.field.column + " = ?"
+		args = append(args, f.value)
+	}
+	var total int
+	if err := s.q.QueryRow("SELECT COUNT(*) FROM products"+where, args...).Scan(&total); err != nil {
+		return Page[domain.Product]{}, err
+	}
+	order := []string{}
+	for _, sf := range q.Sort {
+		column := productFields[sf.Field].column
+		if sf.Desc {
+			column += " DESC"
+		}
+		order = append(order, column)
+	}
+	order = append(order, "id")
+	limit := q.Limit
+	if limit == 0 {
+		limit = -1
+	}
+	products, err := s.queryProducts(
+		"SELECT "+productColumns+" FROM products"+where+" ORDER BY "+strings.Join(order, ", ")+" LIMIT ? OFFSET ?",
+		append(args, limit, q.Offset)...,
+	)
+	if err != nil {
+		return Page[domain.Product]{}, err
+	}
+	return Page[domain.Product]{Items: products, Total: total}, nil
+}
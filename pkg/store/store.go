@@ -0,0 +1,94 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// ErrNotFound indica que no existe un registro con el id pedido
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicateCodeValue indica que otro producto ya usa el code_value, como
+// code_value o como alias
+var ErrDuplicateCodeValue = errors.New("code value already exists")
+
+// ErrVersionConflict indica que el registro cambio desde la version esperada
+var ErrVersionConflict = errors.New("version conflict")
+
+// CheckVersion verifica que el producto este en la version esperada. Una
+// version esperada 0 significa sin condicion.
+func CheckVersion(current domain.Product, expected int) error {
+	if expected != 0 && expected != current.Version {
+		return fmt.Errorf("%w: product %d is at version %d, expected %d", ErrVersionConflict, current.Id, current.Version, expected)
+	}
+	return nil
+}
+
+// Store es el contrato generico de persistencia para registros identificados
+// por un id entero. Cualquier paquete puede implementarlo (backends
+// alternativos, fakes para tests).
+type Store[T any] interface {
+	GetAll() ([]T, error)
+	GetByID(id int) (T, error)
+	Create(item T) (T, error)
+	Update(item T) error
+	Delete(id int) error
+}
+
+// BatchUpdater es un store que puede reemplazar varios registros con una sola
+// escritura. Si alguno no existe no reemplaza ninguno.
+type BatchUpdater[T any] interface {
+	UpdateAll(items []T) error
+}
+
+// Trasher es un store con baja logica: Delete marca el registro con
+// deleted_at y lo pasa a una papelera, fuera de las lecturas normales, de la
+// que se puede recuperar hasta que se purga.
+type Trasher[T any] interface {
+	// Trash devuelve los registros borrados, el mas reciente primero
+	Trash() ([]T, error)
+	// Undelete devuelve un registro de la papelera a las lecturas normales
+	Undelete(id int) (T, error)
+	// Purge elimina definitivamente los registros borrados antes de before
+	Purge(before time.Time) (int, error)
+}
+
+// TrashStore es un Store con papelera
+type TrashStore[T any] interface {
+	Store[T]
+	Trasher[T]
+}
+
+// ProductStore agrega a Store las consultas propias de productos. Se puede
+// envolver con un Decorator.
+type ProductStore interface {
+	Store[domain.Product]
+	Trasher[domain.Product]
+	Search(criteria SearchCriteria) ([]domain.Product, error)
+	GetByCodeValue(code string) (domain.Product, error)
+	Buy(code string, quantity int) error
+	// History devuelve una pagina del historial de un producto, la entrada
+	// mas reciente primero. Limit 0 significa sin limite. Purge no borra el
+	// historial: queda como auditoria de los productos purgados.
+	History(productID, limit, offset int) (Page[domain.ProductChange], error)
+	List(q Query) (Page[domain.Product], error)
+	Begin() (Tx, error)
+}
+
+// renameAliases devuelve los alias de p despues de renombrarlo a code: el
+// code_value actual pasa a ser un alias y code deja de serlo
+func renameAliases(p domain.Product, code string) []string {
+	aliases := []string{}
+	for _, alias := range p.Aliases {
+		if alias != code && alias != p.CodeValue {
+			aliases = append(aliases, alias)
+		}
+	}
+	if p.CodeValue != code {
+		aliases = append(aliases, p.CodeValue)
+	}
+	return aliases
+}
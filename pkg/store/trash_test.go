@@ -0,0 +1,124 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// trashIDs devuelve los ids de la papelera, en el orden en que la lista Trash
+func trashIDs(t *testing.T, s ProductStore) []int {
+	t.Helper()
+	trash, err := s.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, p := range trash {
+		if p.DeletedAt == nil {
+			t.Fatalf("product %d is in the trash without deleted_at", p.Id)
+		}
+		ids = append(ids, p.Id)
+	}
+	return ids
+}
+
+func TestDeleteMovesToTrash(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		change(t, s, 2, "delete", func(tx Tx) error { return tx.Delete(2) })
+		change(t, s, 1, "delete", func(tx Tx) error { return tx.Delete(1) })
+
+		if ids := trashIDs(t, s); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+			t.Fatalf("trash: %v, want [1 2]", ids)
+		}
+		if _, err := s.GetByID(1); err == nil {
+			t.Fatal("a deleted product must not be found by id")
+		}
+		if _, err := s.GetByCodeValue("A1"); err == nil {
+			t.Fatal("a deleted product must not be found by code_value")
+		}
+		if all, err := s.GetAll(); err != nil || len(all) != 1 || all[0].Id != 3 {
+			t.Fatalf("GetAll after deleting: %v %v", codesOf(all), err)
+		}
+	})
+}
+
+func TestUndelete(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		if err := s.Delete(1); err != nil {
+			t.Fatal(err)
+		}
+		restored, err := s.Undelete(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.DeletedAt != nil || restored.Version != 2 {
+			t.Fatalf("restored product: %+v", restored)
+		}
+		if p, err := s.GetByCodeValue("A1"); err != nil || p.Id != 1 || p.Version != 2 {
+			t.Fatalf("restored product by code_value: %+v %v", p, err)
+		}
+		if ids := trashIDs(t, s); len(ids) != 0 {
+			t.Fatalf("trash after undelete: %v", ids)
+		}
+		if _, err := s.Undelete(2); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("undelete of a product outside the trash: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestUndeleteRefusesTakenCodeValue(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		if err := s.Delete(3); err != nil {
+			t.Fatal(err)
+		}
+		taker := domain.Product{Name: "New cake", Quantity: 1, CodeValue: "C3", Expiration: domain.NewDate(2030, time.May, 1), Price: 700, Currency: domain.ARS}
+		if _, err := s.Create(taker); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Undelete(3); !errors.Is(err, ErrDuplicateCodeValue) {
+			t.Fatalf("undelete with a taken code_value: got %v, want ErrDuplicateCodeValue", err)
+		}
+		if ids := trashIDs(t, s); len(ids) != 1 || ids[0] != 3 {
+			t.Fatalf("a refused undelete must leave the product in the trash: %v", ids)
+		}
+		if p, err := s.GetByCodeValue("C3"); err != nil || p.Name != "New cake" {
+			t.Fatalf("C3 after the refused undelete: %+v %v", p, err)
+		}
+	})
+}
+
+func TestPurgeKeepsHistory(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		change(t, s, 1, "update", func(tx Tx) error {
+			p, err := tx.GetByID(1)
+			if err != nil {
+				return err
+			}
+			p.Quantity = 4
+			return tx.Update(p)
+		})
+		change(t, s, 1, "delete", func(tx Tx) error { return tx.Delete(1) })
+		if err := s.Delete(2); err != nil {
+			t.Fatal(err)
+		}
+
+		if purged, err := s.Purge(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+			t.Fatalf("purge before the deletions: %d %v, want 0", purged, err)
+		}
+		if purged, err := s.Purge(time.Now().Add(time.Second)); err != nil || purged != 2 {
+			t.Fatalf("purge after the deletions: %d %v, want 2", purged, err)
+		}
+		if ids := trashIDs(t, s); len(ids) != 0 {
+			t.Fatalf("trash after purge: %v", ids)
+		}
+		if _, err := s.Undelete(1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("undelete of a purged product: got %v, want ErrNotFound", err)
+		}
+		if ops := opsOf(t, s, 1); len(ops) != 2 || ops[0] != "update" || ops[1] != "delete" {
+			t.Fatalf("history of a purged product: %v, want [update delete]", ops)
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// ErrTxDone indica que la transaccion ya se confirmo o se descarto
+var ErrTxDone = errors.New("transaction already committed or rolled back")
+
+// Tx es una transaccion sobre productos: las operaciones ven los cambios
+// previos de la misma transaccion y se confirman todas juntas con Commit o se
+// descartan con Rollback. Mientras esta abierta bloquea al resto de las
+// escrituras, por eso siempre debe terminarse; Rollback despues de Commit no
+// hace nada, asi que se puede usar con defer.
+type Tx interface {
+	GetByID(id int) (domain.Product, error)
+	GetByCodeValue(code string) (domain.Product, error)
+	Create(product domain.Product) (domain.Product, error)
+	Update(product domain.Product) error
+	// Rename cambia el code_value de un producto y conserva el anterior como
+	// alias
+	Rename(id int, code string) error
+	Delete(id int) error
+	// GetDeleted devuelve un producto de la papelera
+	GetDeleted(id int) (domain.Product, error)
+	Undelete(id int) (domain.Product, error)
+	Buy(code string, quantity int) error
+	// AppendHistory agrega una entrada al historial del producto; el store
+	// le asigna el id
+	AppendHistory(change domain.ProductChange) error
+	Commit() error
+	Rollback() error
+}
+
+// jsonTx aplica los cambios directamente sobre los indices del store, con el
+// lock de escritura tomado, y guarda como deshacerlos. Commit los registra en
+// el journal en una sola linea.
+type jsonTx struct {
+	s       *jsonStore
+	undo    []func()
+	entries []JournalEntry
+	done    bool
+}
+
+// Begin abre una transaccion tomando el lock de escritura del store
+func (s *jsonStore) Begin() (Tx, error) {
+	s.mu.Lock()
+	return &jsonTx{s: s}, nil
+}
+
+// inTx ejecuta fn en una transaccion y la confirma si no devolvio error
+func (s *jsonStore) inTx(fn func(tx *jsonTx) error) error {
+	s.mu.Lock()
+	tx := &jsonTx{s: s}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// record guarda la entrada del journal y como deshacer el cambio
+func (tx *jsonTx) record(op string, id int, undo func()) {
+	entry := JournalEntry{Op: op, V: ProductsSchemaVersion, At: time.Now().UTC(), ID: id}
+	if p, ok := tx.s.byID[id]; ok {
+		entry.Product = &p
+	} else if p, ok := tx.s.trash[id]; ok {
+		entry.Product = &p
+	}
+	tx.entries = append(tx.entries, entry)
+	tx.undo = append(tx.undo, undo)
+}
+
+// GetByID devuelve un producto por su id
+func (tx *jsonTx) GetByID(id int) (domain.Product, error) {
+	if tx.done {
+		return domain.Product{}, ErrTxDone
+	}
+	return tx.s.getByID(id)
+}
+
+// GetByCodeValue devuelve un producto por su code_value
+func (tx *jsonTx) GetByCodeValue(code string) (domain.Product, error) {
+	if tx.done {
+		return domain.Product{}, ErrTxDone
+	}
+	return tx.s.getByCodeValue(code)
+}
+
+// Create agrega un nuevo producto con el siguiente id de la secuencia. Un
+// producto nuevo no tiene alias: solo los deja un renombre.
+func (tx *jsonTx) Create(product domain.Product) (domain.Product, error) {
+	if tx.done {
+		return domain.Product{}, ErrTxDone
+	}
+	if err := tx.checkCode(0, product.CodeValue); err != nil {
+		return domain.Product{}, err
+	}
+	id, err := tx.s.seq.Next()
+	if err != nil {
+		return domain.Product{}, err
+	}
+	product.Id = id
+	product.Version = 1
+	product.Aliases = nil
+	product.DeletedAt = nil
+	tx.s.insert(product)
+	tx.record(OpCreate, id, func() { tx.s.remove(id) })
+	return product, nil
+}
+
+// Update actualiza un producto si esta en la version product.Version (0 para
+// no verificarla) y le asigna la siguiente
+func (tx *jsonTx) Update(product domain.Product) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	old, ok := tx.s.byID[product.Id]
+	if !ok {
+		return errors.New("product not found")
+	}
+	if err := CheckVersion(old, product.Version); err != nil {
+		return err
+	}
+	if product.CodeValue != old.CodeValue {
+		if err := tx.checkCode(product.Id, product.CodeValue); err != nil {
+			return err
+		}
+	}
+	product.Version = old.Version + 1
+	product.Aliases = old.Aliases
+	product.DeletedAt = nil
+	tx.s.replace(product)
+	tx.record(OpUpdate, product.Id, func() { tx.s.replace(old) })
+	return nil
+}
+
+// Rename cambia el code_value de un producto y conserva el anterior como alias
+func (tx *jsonTx) Rename(id int, code string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	old, ok := tx.s.byID[id]
+	if !ok {
+		return errors.New("product not found")
+	}
+	if err := tx.checkCode(id, code); err != nil {
+		return err
+	}
+	renamed := old
+	renamed.Aliases = renameAliases(old, code)
+	renamed.CodeValue = code
+	renamed.Version++
+	tx.s.replace(renamed)
+	tx.record(OpUpdate, id, func() { tx.s.replace(old) })
+	return nil
+}
+
+// checkCode falla con ErrDuplicateCodeValue si otro producto usa code como
+// code_value o como alias
+func (tx *jsonTx) checkCode(id int, code string) error {
+	if other, taken := tx.s.codeOwner(code); taken && other != id {
+		return fmt.Errorf("%w: %s is used by product %d", ErrDuplicateCodeValue, code, other)
+	}
+	return nil
+}
+
+// Delete manda un producto a la papelera
+func (tx *jsonTx) Delete(id int) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	old, ok := tx.s.byID[id]
+	if !ok {
+		return errors.New("product not found")
+	}
+	pos := tx.s.remove(id)
+	deleted := old
+	now := time.Now().UTC()
+	deleted.DeletedAt = &now
+	tx.s.trash[id] = deleted
+	tx.record(OpDelete, id, func() {
+		delete(tx.s.trash, id)
+		tx.s.insertAt(pos, old)
+	})
+	return nil
+}
+
+// GetDeleted devuelve un producto de la papelera
+func (tx *jsonTx) GetDeleted(id int) (domain.Product, error) {
+	if tx.done {
+		return domain.Product{}, ErrTxDone
+	}
+	p, ok := tx.s.trash[id]
+	if !ok {
+		return domain.Product{}, fmt.Errorf("%w: product %d is not in the trash", ErrNotFound, id)
+	}
+	return p, nil
+}
+
+// Undelete devuelve un producto de la papelera al final del catalogo, si su
+// code_value no lo tomo otro producto
+func (tx *jsonTx) Undelete(id int) (domain.Product, error) {
+	if tx.done {
+		return domain.Product{}, ErrTxDone
+	}
+	old, ok := tx.s.trash[id]
+	if !ok {
+		return domain.Product{}, fmt.Errorf("%w: product %d is not in the trash", ErrNotFound, id)
+	}
+	for _, code := range append([]string{old.CodeValue}, old.Aliases...) {
+		if err := tx.checkCode(id, code); err != nil {
+			return domain.Product{}, err
+		}
+	}
+	restored := old
+	restored.DeletedAt = nil
+	restored.Version++
+	delete(tx.s.trash, id)
+	tx.s.insert(restored)
+	tx.record(OpUndelete, id, func() {
+		tx.s.remove(id)
+		tx.s.trash[id] = old
+	})
+	return restored, nil
+}
+
+// AppendHistory agrega una entrada al historial con el siguiente id
+func (tx *jsonTx) AppendHistory(change domain.ProductChange) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	change.ID = tx.s.history.last + 1
+	tx.s.history.add(change)
+	tx.entries = append(tx.entries, JournalEntry{Op: OpHistory, At: time.Now().UTC(), ID: change.ProductID, Change: &change})
+	tx.undo = append(tx.undo, tx.s.history.undo)
+	return nil
+}
+
+// purge elimina definitivamente un producto de la papelera
+func (tx *jsonTx) purge(id int) {
+	old := tx.s.trash[id]
+	delete(tx.s.trash, id)
+	tx.record(OpPurge, id, func() { tx.s.trash[id] = old })
+}
+
+// Buy descuenta la cantidad comprada si hay stock suficiente
+func (tx *jsonTx) Buy(code string, quantity int) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	id, ok := tx.s.codeOwner(code)
+	if !ok || tx.s.byID[id].Quantity < quantity {
+		return errors.New("No se puede ejecutar la compra")
+	}
+	old := tx.s.byID[id]
+	updated := old
+	updated.Quantity -= quantity
+	updated.Version++
+	tx.s.replace(updated)
+	tx.record(OpBuy, id, func() { tx.s.replace(old) })
+	return nil
+}
+
+// Commit registra los cambios en el journal. Si la escritura falla los
+// cambios se deshacen. Un error al compactar no afecta a la transaccion, que
+// ya quedo en el journal: se reintenta en la proxima.
+func (tx *jsonTx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	var err error
+	switch len(tx.entries) {
+	case 0:
+	case 1:
+		err = tx.s.journal.append(tx.entries[0])
+	default:
+		err = tx.s.journal.append(JournalEntry{Op: OpTx, At: time.Now().UTC(), Entries: tx.entries})
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if tx.s.compactEvery > 0 && tx.s.journal.pending >= tx.s.compactEvery {
+		if err := tx.s.compact(); err != nil {
+			log.Printf("compacting %s journal (%d pending entries): %v", tx.s.pathToFile, tx.s.journal.pending, err)
+		}
+	}
+	tx.done = true
+	tx.s.mu.Unlock()
+	return nil
+}
+
+// Rollback deshace los cambios en orden inverso y libera el lock
+func (tx *jsonTx) Rollback() error {
+	if tx.done {
+		return nil
+	}
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.done = true
+	tx.s.mu.Unlock()
+	return nil
+}
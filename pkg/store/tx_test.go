@@ -0,0 +1,260 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mceciabate/web-server/internal/domain"
+)
+
+// seedProducts son los productos con los que arrancan los tests de los stores
+func seedProducts() []domain.Product {
+	return []domain.Product{
+		{Id: 1, Name: "Oil", Quantity: 10, CodeValue: "A1", IsPublished: true, Expiration: domain.NewDate(2030, time.January, 1), Price: 1000, Currency: domain.ARS, Version: 1},
+		{Id: 2, Name: "Wine", Quantity: 5, CodeValue: "B2", IsPublished: true, Expiration: domain.NewDate(2030, time.June, 1), Price: 2500, Currency: domain.ARS, Version: 1},
+		{Id: 3, Name: "Cake", Quantity: 8, CodeValue: "C3", IsPublished: false, Expiration: domain.NewDate(2030, time.March, 1), Price: 500, Currency: domain.ARS, Version: 1},
+	}
+}
+
+// eachBackend corre fn contra un store json y uno sqlite con seedProducts
+func eachBackend(t *testing.T, fn func(t *testing.T, s ProductStore)) {
+	backends := map[string]func(dir, seed string) (ProductStore, error){
+		"json": func(dir, seed string) (ProductStore, error) {
+			return NewStore(seed)
+		},
+		"sqlite": func(dir, seed string) (ProductStore, error) {
+			return NewSqliteStore(filepath.Join(dir, "products.db"), seed)
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			seed := filepath.Join(dir, "products.json")
+			data, err := encodeProducts(seedProducts())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(seed, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			s, err := open(dir, seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fn(t, s)
+		})
+	}
+}
+
+func TestCreateRejectsCodeUsedAsAlias(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		tx, err := s.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Rename(1, "A1-NEW"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, code := range []string{"A1", "A1-NEW", "B2"} {
+			_, err := s.Create(domain.Product{Name: "Other", Quantity: 1, CodeValue: code, Price: 100})
+			if !errors.Is(err, ErrDuplicateCodeValue) {
+				t.Errorf("create with code %s: got %v, want ErrDuplicateCodeValue", code, err)
+			}
+		}
+		p, err := s.GetByCodeValue("A1")
+		if err != nil || p.Id != 1 {
+			t.Fatalf("alias A1 must still resolve to product 1, got %d (%v)", p.Id, err)
+		}
+
+		created, err := s.Create(domain.Product{Name: "New", Quantity: 1, CodeValue: "D4", Price: 100, Aliases: []string{"B2", "X9"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(created.Aliases) != 0 {
+			t.Fatalf("created product kept client aliases %v", created.Aliases)
+		}
+		if _, err := s.GetByCodeValue("X9"); err == nil {
+			t.Fatal("client supplied alias X9 must not be indexed")
+		}
+		if p, err := s.GetByCodeValue("B2"); err != nil || p.Id != 2 {
+			t.Fatalf("B2 must still resolve to product 2, got %d (%v)", p.Id, err)
+		}
+	})
+}
+
+// state resume lo que una transaccion descartada no debe cambiar: los
+// productos y como se encuentran por id, code_value, alias y precio, la
+// papelera y el historial
+type state struct {
+	live    []domain.Product
+	byCode  map[string]int
+	byPrice []int
+	trash   []int
+	history map[int]int
+}
+
+func snapshotState(t *testing.T, s ProductStore) state {
+	t.Helper()
+	live, err := s.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := state{live: live, byCode: map[string]int{}, history: map[int]int{}}
+	for _, code := range []string{"A1", "B2", "C3", "B2-NEW", "D4"} {
+		if p, err := s.GetByCodeValue(code); err == nil {
+			st.byCode[code] = p.Id
+		}
+	}
+	min, max := domain.Amount(0), domain.Amount(3000)
+	found, err := s.Search(SearchCriteria{PriceMin: &min, PriceMax: &max})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range found {
+		st.byPrice = append(st.byPrice, p.Id)
+	}
+	trash, err := s.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range trash {
+		st.trash = append(st.trash, p.Id)
+	}
+	for _, p := range seedProducts() {
+		page, err := s.History(p.Id, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.history[p.Id] = page.Total
+	}
+	return st
+}
+
+func assertState(t *testing.T, got, want state) {
+	t.Helper()
+	if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", want) {
+		t.Fatalf("state after rollback:\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// failedTx hace varios cambios en una transaccion y termina con una
+// operacion que falla por conflicto de version
+func failedTx(t *testing.T, tx Tx) error {
+	t.Helper()
+	p, err := tx.GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Price = 9000
+	p.Name = "Changed"
+	if err := tx.Update(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rename(2, "B2-NEW"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete(3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Create(domain.Product{Name: "New", Quantity: 1, CodeValue: "D4", Price: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Buy("A1", 5); err != nil {
+		t.Fatal(err)
+	}
+	for id := 1; id <= 3; id++ {
+		if err := tx.AppendHistory(domain.ProductChange{ProductID: id, Op: "update", Version: 2, At: time.Now().UTC()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale, err := tx.GetByID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Version = 1
+	return tx.Update(stale)
+}
+
+func TestFailedTransactionRollsBack(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		// un producto en la papelera desde antes, para ver que undelete tambien se deshace
+		if err := s.Delete(3); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Undelete(3); err != nil {
+			t.Fatal(err)
+		}
+		before := snapshotState(t, s)
+
+		tx, err := s.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := failedTx(t, tx); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("last operation: got %v, want ErrVersionConflict", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		assertState(t, snapshotState(t, s), before)
+
+		// el store sigue aceptando escrituras y no quedo nada pendiente
+		if err := s.Buy("A1", 1); err != nil {
+			t.Fatal(err)
+		}
+		if p, _ := s.GetByID(1); p.Quantity != 9 || p.Version != 2 {
+			t.Fatalf("product 1 after a later buy: quantity %d version %d, want 9 and 2", p.Quantity, p.Version)
+		}
+	})
+}
+
+func TestTransactionUndoesTrashChanges(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s ProductStore) {
+		if err := s.Delete(2); err != nil {
+			t.Fatal(err)
+		}
+		before := snapshotState(t, s)
+
+		tx, err := s.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.Undelete(2); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Delete(1); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		assertState(t, snapshotState(t, s), before)
+	})
+}
+
+func TestJSONCommitFailureRollsBack(t *testing.T) {
+	s, _ := newJournaledStore(t)
+	before := snapshotState(t, s)
+
+	tx, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := failedTx(t, tx); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("last operation: got %v, want ErrVersionConflict", err)
+	}
+	// el journal cerrado hace fallar la escritura del commit
+	s.(*jsonStore).journal.file.Close()
+	if err := tx.Commit(); err == nil {
+		t.Fatal("commit with a closed journal must fail")
+	}
+	assertState(t, snapshotState(t, s), before)
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnonymousActor es el actor de los requests sin un token valido
+const AnonymousActor = "anonymous"
+
+// actorKey es la clave del contexto donde Authorized deja el actor
+const actorKey = "actor"
+
+// Authorized valida el header TOKEN contra la variable de entorno TOKEN; si
+// falta o no coincide escribe la respuesta 401 y devuelve false. Si es valido
+// deja en el contexto el actor que devuelve Actor.
+func Authorized(c *gin.Context) bool {
+	token := c.GetHeader("TOKEN")
+	if token == "" {
+		Failure(c, 401, errors.New("token not found"))
+		return false
+	}
+	if token != os.Getenv("TOKEN") {
+		Failure(c, 401, errors.New("invalid token"))
+		return false
+	}
+	c.Set(actorKey, tokenActor(token))
+	return true
+}
+
+// Actor devuelve a nombre de quien se hacen los cambios del request: el
+// token validado por Authorized, o AnonymousActor si no se valido ninguno
+func Actor(c *gin.Context) string {
+	if actor := c.GetString(actorKey); actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+// tokenActor identifica un token sin exponerlo: "token:" y el comienzo de su
+// sha256
+func tokenActor(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
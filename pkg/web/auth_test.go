@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthorized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("TOKEN", "secret")
+	cases := []struct {
+		token   string
+		ok      bool
+		message string
+	}{
+		{"", false, "token not found"},
+		{"other", false, "invalid token"},
+		{"secret", true, ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("GET", "/", nil)
+		if c.token != "" {
+			ctx.Request.Header.Set("TOKEN", c.token)
+		}
+		if got := Authorized(ctx); got != c.ok {
+			t.Fatalf("token %q: got %v, want %v", c.token, got, c.ok)
+		}
+		if c.ok {
+			continue
+		}
+		if w.Code != 401 || !strings.Contains(w.Body.String(), c.message) {
+			t.Fatalf("token %q: %d %s, want 401 with %q", c.token, w.Code, w.Body, c.message)
+		}
+	}
+}
+
+func TestActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("TOKEN", "secret")
+	actorFor := func(token string) string {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest("GET", "/", nil)
+		ctx.Request.Header.Set("TOKEN", token)
+		Authorized(ctx)
+		return Actor(ctx)
+	}
+
+	actor := actorFor("secret")
+	if !strings.HasPrefix(actor, "token:") || strings.Contains(actor, "secret") {
+		t.Fatalf("actor %q, want a token fingerprint", actor)
+	}
+	if again := actorFor("secret"); again != actor {
+		t.Fatalf("the same token gave %q and %q", actor, again)
+	}
+	if got := actorFor("other"); got != AnonymousActor {
+		t.Fatalf("invalid token: got %q, want %q", got, AnonymousActor)
+	}
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if got := Actor(ctx); got != AnonymousActor {
+		t.Fatalf("without Authorized: got %q, want %q", got, AnonymousActor)
+	}
+}
@@ -0,0 +1,36 @@
+package web
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag devuelve el ETag de una version de un recurso
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// SetETag agrega el header ETag con la version del recurso
+func SetETag(ctx *gin.Context, version int) {
+	ctx.Header("ETag", ETag(version))
+}
+
+// IfMatch devuelve la version pedida en el header If-Match. Sin header o con
+// * devuelve 0, que significa sin condicion. ok es false si el header no es
+// un ETag fuerte con una version, en cuyo caso no puede coincidir.
+func IfMatch(ctx *gin.Context) (version int, ok bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// IdempotencyKeyHeader es el header con el que el cliente identifica un
+// request que puede reintentar
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKey es el largo maximo de una clave
+const maxIdempotencyKey = 255
+
+// replayedHeaders son los headers de la respuesta que se guardan con ella
+var replayedHeaders = []string{"Content-Type", "ETag", "Location", "Deprecation", "Link"}
+
+// UnsafeMethods indica si el request puede cambiar el estado segun su metodo
+func UnsafeMethods(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// AllRequests aplica la idempotencia a cualquier request, para las rutas que
+// cambian el estado con un GET
+func AllRequests(r *http.Request) bool {
+	return true
+}
+
+// Idempotent hace que los requests con Idempotency-Key para los que applies
+// devuelve true se procesen una sola vez: un reintento con la misma clave y
+// el mismo request recibe la respuesta guardada, con el header
+// Idempotent-Replayed, y la misma clave con otro request se rechaza con 422.
+// Las claves son por token, y no se guardan las respuestas 401 ni 5xx, que
+// se pueden reintentar.
+func Idempotent(keys *store.IdempotencyStore, applies func(*http.Request) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !applies(c.Request) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			Failure(c, 400, errors.New("Idempotency-Key can't be longer than 255 characters"))
+			c.Abort()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			Failure(c, 400, errors.New("can't read request body"))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		key = hash(c.GetHeader("TOKEN")) + ":" + key
+		stored, err := keys.Begin(key, fingerprint(c.Request, body))
+		if errors.Is(err, store.ErrKeyMismatch) {
+			Failure(c, 422, err)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			Failure(c, 409, err)
+			c.Abort()
+			return
+		}
+		if stored != nil {
+			replay(c, stored)
+			return
+		}
+		completed := false
+		// si el handler entra en panico la clave se libera igual
+		defer func() {
+			if !completed {
+				keys.Abort(key)
+			}
+		}()
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		status := writer.Status()
+		if status == http.StatusUnauthorized || status >= 500 {
+			return
+		}
+		response := store.StoredResponse{Status: status, Header: map[string]string{}, Body: writer.body.Bytes()}
+		for _, name := range replayedHeaders {
+			if value := writer.Header().Get(name); value != "" {
+				response.Header[name] = value
+			}
+		}
+		if err := keys.Complete(key, response); err != nil {
+			c.Error(err)
+		}
+		completed = true
+	}
+}
+
+// replay escribe una respuesta guardada y corta la cadena de handlers
+func replay(c *gin.Context, stored *store.StoredResponse) {
+	for name, value := range stored.Header {
+		c.Header(name, value)
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(stored.Status)
+	c.Writer.Write(stored.Body)
+	c.Abort()
+}
+
+// fingerprint identifica un request por su metodo, url, condicion y cuerpo
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.RequestURI(), r.Header.Get("If-Match"), r.Header.Get("Content-Type")} {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hash devuelve un resumen de value, para no guardar el token
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// recordingWriter guarda una copia de lo que se escribe en la respuesta
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
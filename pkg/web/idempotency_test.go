@@ -0,0 +1,124 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mceciabate/web-server/pkg/store"
+)
+
+// newIdempotentServer arma un router con el middleware y un handler que
+// cuenta cuantas veces se ejecuto
+func newIdempotentServer(t *testing.T, retention time.Duration) (*gin.Engine, *int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	keys, err := store.NewIdempotencyStore("", retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	r := gin.New()
+	r.Use(Idempotent(keys, UnsafeMethods))
+	r.POST("/things", func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/things/"+strconv.Itoa(calls))
+		Success(c, 201, calls)
+	})
+	return r, &calls
+}
+
+func post(r http.Handler, token, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("TOKEN", token)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotentReplaysStoredResponse(t *testing.T) {
+	r, calls := newIdempotentServer(t, time.Hour)
+
+	first := post(r, "tok", "k1", `{"a":1}`)
+	second := post(r, "tok", "k1", `{"a":1}`)
+	if *calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", *calls)
+	}
+	if second.Code != 201 || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay: %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("replayed response must have the Idempotent-Replayed header")
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("first response must not be marked as replayed")
+	}
+	if got := second.Header().Get("Location"); got != "/things/1" {
+		t.Fatalf("replayed Location %q, want /things/1", got)
+	}
+
+	// sin clave cada request se procesa
+	post(r, "tok", "", `{"a":1}`)
+	if *calls != 2 {
+		t.Fatalf("request without key: handler ran %d times, want 2", *calls)
+	}
+}
+
+func TestIdempotentRejectsReusedKeyWithAnotherBody(t *testing.T) {
+	r, calls := newIdempotentServer(t, time.Hour)
+
+	post(r, "tok", "k1", `{"a":1}`)
+	w := post(r, "tok", "k1", `{"a":2}`)
+	if w.Code != 422 {
+		t.Fatalf("reused key with another body: status %d, want 422", w.Code)
+	}
+	if *calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", *calls)
+	}
+}
+
+func TestIdempotentKeysExpire(t *testing.T) {
+	r, calls := newIdempotentServer(t, 20*time.Millisecond)
+
+	post(r, "tok", "k1", `{"a":1}`)
+	time.Sleep(30 * time.Millisecond)
+	w := post(r, "tok", "k1", `{"a":2}`)
+	if w.Code != 201 || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expired key: status %d replayed %q, want a new 201", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+	if *calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", *calls)
+	}
+}
+
+func TestIdempotentKeysArePerToken(t *testing.T) {
+	r, calls := newIdempotentServer(t, time.Hour)
+
+	post(r, "tok-a", "k1", `{"a":1}`)
+	w := post(r, "tok-b", "k1", `{"a":2}`)
+	if w.Code != 201 || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("same key with another token: status %d replayed %q, want a new 201", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+	if *calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", *calls)
+	}
+}
+
+func TestIdempotentRejectsLongKey(t *testing.T) {
+	r, calls := newIdempotentServer(t, time.Hour)
+
+	if w := post(r, "tok", strings.Repeat("k", maxIdempotencyKey+1), `{}`); w.Code != 400 {
+		t.Fatalf("long key: status %d, want 400", w.Code)
+	}
+	if *calls != 0 {
+		t.Fatalf("handler ran %d times, want 0", *calls)
+	}
+}
@@ -14,6 +14,15 @@ type errorResponse struct {
 
 type response struct {
 	Data interface{} `json:"data"`
+	Meta interface{} `json:"meta,omitempty"`
+}
+
+// PageMeta describe la pagina devuelta en una respuesta paginada
+type PageMeta struct {
+	Total  int `json:"total"`
+	Count  int `json:"count"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
 }
 
 // Success escribe una respuesta exitosa
@@ -23,6 +32,14 @@ func Success(ctx *gin.Context, status int, data interface{}) {
 	})
 }
 
+// SuccessPage escribe una respuesta exitosa con los datos de paginacion
+func SuccessPage(ctx *gin.Context, status int, data interface{}, meta PageMeta) {
+	ctx.JSON(status, response{
+		Data: data,
+		Meta: meta,
+	})
+}
+
 // Failure escribe una respuesta fallida
 func Failure(ctx *gin.Context, status int, err error) {
 	ctx.JSON(status, errorResponse{